@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER audit_events_no_update ON audit_events;
+
+DROP FUNCTION audit_events_immutable();
+
+DROP TABLE audit_events;
+
+COMMIT;
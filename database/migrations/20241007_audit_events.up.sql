@@ -0,0 +1,32 @@
+BEGIN;
+
+CREATE TABLE audit_events (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "occurred_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "event_type" VARCHAR(50) NOT NULL,
+    "user_id" BIGINT,
+    "user_ref" VARCHAR(100),
+    "credential_id" bytea,
+    "ip_address" VARCHAR(64),
+    "user_agent" TEXT,
+    "tenant" VARCHAR(100),
+    "request_id" VARCHAR(100),
+    "reason" TEXT,
+    "details" JSON
+);
+
+CREATE INDEX audit_events_user_ref_idx ON audit_events ("user_ref", "occurred_at");
+CREATE INDEX audit_events_occurred_at_idx ON audit_events ("occurred_at");
+
+-- The audit log is append-only, reject any attempt to change history
+CREATE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE webhook_outbox;
+
+DROP TABLE webhook_subscriptions;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE webhook_subscriptions (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "url" TEXT NOT NULL,
+    "secret" VARCHAR(255) NOT NULL,
+    "event_types" JSON NOT NULL DEFAULT '[]',
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE webhook_outbox (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "subscription_id" BIGINT NOT NULL REFERENCES webhook_subscriptions("_id") ON DELETE CASCADE,
+    "event_type" VARCHAR(50) NOT NULL,
+    "payload" JSON NOT NULL,
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending',
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_error" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "delivered_at" TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_outbox_due_idx ON webhook_outbox ("status", "next_attempt_at");
+
+COMMIT;
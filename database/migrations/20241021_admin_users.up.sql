@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Allowlist of people who may sign in to the admin service with their passkeys.
+-- Keyed by the webauthn user reference so an admin can be allowed before registering a passkey.
+CREATE TABLE admin_users (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "user_ref" VARCHAR(100) NOT NULL UNIQUE,
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Roles granted to administrators per tenant, '*' grants the role for every tenant
+CREATE TABLE admin_roles (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "admin_user_id" BIGINT NOT NULL REFERENCES admin_users("_id") ON DELETE CASCADE,
+    "tenant" VARCHAR(100) NOT NULL,
+    "role" VARCHAR(25) NOT NULL CHECK ("role" IN ('viewer', 'support', 'security-admin', 'owner')),
+    "granted_by" VARCHAR(100),
+    "granted_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE ("admin_user_id", "tenant")
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE user_provisioning;
+
+DROP TABLE api_keys;
+
+COMMIT;
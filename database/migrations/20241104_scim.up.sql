@@ -0,0 +1,21 @@
+BEGIN;
+
+-- Bearer tokens for machine clients such as SCIM provisioning, only the SHA-256 of the token is stored
+CREATE TABLE api_keys (
+    "_id" BIGSERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    "scope" VARCHAR(25) NOT NULL,
+    "key_hash" bytea NOT NULL UNIQUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_used_at" TIMESTAMPTZ,
+    "revoked_at" TIMESTAMPTZ
+);
+
+-- Lifecycle of users driven by a provisioning system, users without a row are active
+CREATE TABLE user_provisioning (
+    "user_id" BIGINT PRIMARY KEY REFERENCES webauthn_users("_id") ON DELETE CASCADE,
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX webauthn_credentials_aaguid_idx;
+
+ALTER TABLE webauthn_credentials DROP COLUMN "aaguid";
+
+COMMIT;
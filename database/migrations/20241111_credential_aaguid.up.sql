@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Promote the authenticator model out of the authenticator JSON so credentials can be found by it
+ALTER TABLE webauthn_credentials ADD COLUMN "aaguid" bytea;
+
+UPDATE webauthn_credentials
+SET aaguid = decode(authenticator->>'AAGUID', 'base64')
+WHERE authenticator->>'AAGUID' IS NOT NULL;
+
+CREATE INDEX webauthn_credentials_aaguid_idx ON webauthn_credentials ("aaguid");
+
+COMMIT;
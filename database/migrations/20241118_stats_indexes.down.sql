@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX audit_events_event_type_idx;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- The admin statistics aggregate the audit log by event type over a time window
+CREATE INDEX audit_events_event_type_idx ON audit_events ("event_type", "occurred_at");
+
+COMMIT;
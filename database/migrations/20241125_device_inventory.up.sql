@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Serial numbers of the company-issued authenticators each tenant accepts with enterprise attestation
+CREATE TABLE device_inventory (
+    "tenant" VARCHAR(100) NOT NULL,
+    "serial" VARCHAR(100) NOT NULL,
+    "label" VARCHAR(200) NOT NULL DEFAULT '',
+    "uploaded_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("tenant", "serial")
+);
+
+COMMIT;
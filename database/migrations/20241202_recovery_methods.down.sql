@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE user_recovery_methods;
+
+COMMIT;
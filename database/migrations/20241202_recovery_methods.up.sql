@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Account recovery methods the relying party has set up for a user, e.g. a verified email or recovery codes.
+-- Users may only remove their last passkey when they have one.
+CREATE TABLE user_recovery_methods (
+    "user_id" BIGINT NOT NULL REFERENCES webauthn_users("_id") ON DELETE CASCADE,
+    "method" VARCHAR(50) NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("user_id", "method")
+);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE admin_credentials;
+
+COMMIT;
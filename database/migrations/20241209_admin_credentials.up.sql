@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Passkeys an administrator may sign in to the admin service with. Anyone can register a passkey for any user
+-- reference on the auth server, so only credentials enrolled by a signed in admin or an operator are listed.
+CREATE TABLE admin_credentials (
+    "admin_user_id" BIGINT NOT NULL REFERENCES admin_users("_id") ON DELETE CASCADE,
+    "credential_id" bytea NOT NULL REFERENCES webauthn_credentials("credential_id") ON DELETE CASCADE,
+    "enrolled_by" VARCHAR(100),
+    "enrolled_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("admin_user_id", "credential_id")
+);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- The tenant a user registered with, the auth server applies that tenant's policy to every ceremony of the user
+-- whichever tenant a request claims to come from. Users from before tenants were bound get one on their next ceremony.
+CREATE TABLE user_tenants (
+    "user_id" BIGINT PRIMARY KEY REFERENCES webauthn_users("_id") ON DELETE CASCADE,
+    "tenant" VARCHAR(100) NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX user_tenants_tenant_idx ON user_tenants ("tenant");
+
+COMMIT;
@@ -0,0 +1,61 @@
+-- name: GetActiveAdminUser :one
+SELECT *
+FROM admin_users
+WHERE user_ref = $1
+AND active = TRUE;
+
+-- name: UpsertAdminUser :one
+INSERT INTO admin_users (
+    "user_ref", "active"
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_ref)
+DO UPDATE SET active = EXCLUDED.active
+RETURNING *;
+
+-- name: ListAdminRoles :many
+SELECT admin_roles.*
+FROM admin_roles
+INNER JOIN admin_users ON admin_roles.admin_user_id = admin_users._id
+WHERE admin_users.user_ref = $1
+AND admin_users.active = TRUE
+ORDER BY admin_roles.tenant;
+
+-- name: UpsertAdminRole :one
+INSERT INTO admin_roles (
+    "admin_user_id", "tenant", "role", "granted_by"
+)
+SELECT _id, sqlc.arg('tenant'), sqlc.arg('role'), sqlc.arg('granted_by')
+FROM admin_users
+WHERE user_ref = sqlc.arg('user_ref')
+ON CONFLICT (admin_user_id, tenant)
+DO UPDATE SET "role" = EXCLUDED.role, granted_by = EXCLUDED.granted_by, granted_at = NOW()
+RETURNING *;
+
+-- name: DeleteAdminRole :execrows
+DELETE FROM admin_roles
+USING admin_users
+WHERE admin_roles.admin_user_id = admin_users._id
+AND admin_users.user_ref = $1
+AND admin_roles.tenant = $2;
+
+-- name: ListAdminCredentialIDs :many
+SELECT admin_credentials.credential_id
+FROM admin_credentials
+INNER JOIN admin_users ON admin_credentials.admin_user_id = admin_users._id
+WHERE admin_users.user_ref = $1
+AND admin_users.active = TRUE;
+
+-- name: UpsertAdminCredential :execrows
+INSERT INTO admin_credentials (
+    "admin_user_id", "credential_id", "enrolled_by"
+)
+SELECT admin_users._id, webauthn_credentials.credential_id, sqlc.arg('enrolled_by')
+FROM admin_users
+INNER JOIN webauthn_users ON webauthn_users.ref_id = admin_users.user_ref
+INNER JOIN webauthn_credentials ON webauthn_credentials.user_id = webauthn_users._id
+WHERE admin_users.user_ref = sqlc.arg('user_ref')
+AND webauthn_credentials.credential_id = sqlc.arg('credential_id')
+ON CONFLICT (admin_user_id, credential_id)
+DO UPDATE SET enrolled_by = EXCLUDED.enrolled_by, enrolled_at = NOW();
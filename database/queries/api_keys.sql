@@ -0,0 +1,25 @@
+-- name: InsertApiKey :one
+INSERT INTO api_keys (
+    "name", "scope", "key_hash"
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: GetActiveApiKey :one
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE key_hash = $1
+AND "scope" = $2
+AND revoked_at IS NULL
+RETURNING *;
+
+-- name: ListApiKeys :many
+SELECT *
+FROM api_keys
+ORDER BY _id;
+
+-- name: RevokeApiKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE _id = $1
+AND revoked_at IS NULL;
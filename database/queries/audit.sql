@@ -0,0 +1,17 @@
+-- name: InsertAuditEvent :one
+INSERT INTO audit_events (
+    "event_type", "user_id", "user_ref", "credential_id", "ip_address", "user_agent", "tenant", "request_id", "reason", "details"
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg('user_ref')::VARCHAR IS NULL OR user_ref = sqlc.narg('user_ref'))
+AND (sqlc.narg('tenant')::VARCHAR IS NULL OR tenant = sqlc.narg('tenant'))
+AND occurred_at >= sqlc.arg('from_time')
+AND occurred_at < sqlc.arg('to_time')
+AND _id > sqlc.arg('after_id')
+ORDER BY _id
+LIMIT sqlc.arg('row_limit');
@@ -15,31 +15,56 @@ ON CONFLICT (ref_id)
 DO UPDATE set ref_id = EXCLUDED.ref_id
 RETURNING *;
 
--- name: ListUsers :many
+-- name: SearchUsers :many
 SELECT *
-FROM webauthn_users;
+FROM webauthn_users
+WHERE (
+    sqlc.narg('query')::VARCHAR IS NULL
+    OR ref_id ILIKE '%' || sqlc.narg('query') || '%' ESCAPE '\'
+    OR "name" ILIKE '%' || sqlc.narg('query') || '%' ESCAPE '\'
+    OR display_name ILIKE '%' || sqlc.narg('query') || '%' ESCAPE '\'
+)
+AND _id > sqlc.arg('after_id')
+ORDER BY _id
+LIMIT sqlc.arg('row_limit');
 
 -- name: GetUserByID :one
 SELECT *
 FROM webauthn_users
 WHERE _id = $1;
 
+-- name: LockUser :one
+SELECT _id
+FROM webauthn_users
+WHERE _id = $1
+FOR UPDATE;
+
 -- name: GetUserByRef :one
 SELECT *
 FROM webauthn_users
 WHERE ref_id = $1;
 
+-- name: ListUsersByName :many
+SELECT *
+FROM webauthn_users
+WHERE "name" = $1
+ORDER BY _id;
+
 -- name: UpdateUser :one
 UPDATE webauthn_users
 SET "name" = $2, display_name = $3
 WHERE ref_id = $1
 RETURNING *;
 
+-- name: DeleteUser :execrows
+DELETE FROM webauthn_users
+WHERE ref_id = $1;
+
 -- name: InsertCredential :one
 INSERT INTO webauthn_credentials (
-    "credential_id", "user_id", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta"
+    "credential_id", "user_id", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta", "aaguid"
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 ) RETURNING *;
 
 -- name: ListAllCredentialsByUser :many
@@ -55,6 +80,13 @@ WHERE user_id = $1
 AND meta->>'status' = 'active'
 ORDER BY credential_id;
 
+-- name: ListUnrevokedCredentialsByUser :many
+SELECT *
+FROM webauthn_credentials
+WHERE user_id = $1
+AND meta->>'status' <> 'revoked'
+ORDER BY credential_id;
+
 -- name: GetCredential :one
 SELECT sqlc.embed(webauthn_credentials), sqlc.embed(webauthn_users)
 FROM webauthn_credentials
@@ -66,3 +98,51 @@ UPDATE webauthn_credentials
 SET use_counter = use_counter + 1
 WHERE credential_id = $1
 RETURNING use_counter;
+
+-- name: SetCredentialUseCounter :exec
+UPDATE webauthn_credentials
+SET use_counter = $2
+WHERE credential_id = $1;
+
+-- name: ReplaceCredential :one
+UPDATE webauthn_credentials
+SET public_key = $2, attestation_type = $3, transport = $4, flags = $5, authenticator = $6, attestation = $7, meta = $8, aaguid = $9, use_counter = GREATEST(use_counter, $10)
+WHERE credential_id = $1
+RETURNING *;
+
+-- name: UpdateCredentialMeta :one
+UPDATE webauthn_credentials
+SET meta = $2
+WHERE credential_id = $1
+RETURNING *;
+
+-- name: CountUnrevokedCredentialsByAaguid :one
+SELECT COUNT(*) AS credential_count, COUNT(DISTINCT webauthn_credentials.user_id) AS user_count
+FROM webauthn_credentials
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_credentials.user_id
+WHERE aaguid = sqlc.arg('aaguid')
+AND meta->>'status' <> 'revoked'
+AND (sqlc.narg('tenant')::VARCHAR IS NULL OR COALESCE(user_tenants.tenant, '') = sqlc.narg('tenant'));
+
+-- name: ListActiveCredentials :many
+SELECT sqlc.embed(webauthn_credentials), sqlc.embed(webauthn_users)
+FROM webauthn_credentials
+INNER JOIN webauthn_users ON webauthn_credentials.user_id = webauthn_users._id
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_users._id
+WHERE meta->>'status' = 'active'
+AND COALESCE(user_tenants.tenant, '') = sqlc.arg('tenant')
+AND credential_id > sqlc.arg('after_id')
+ORDER BY credential_id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListUnrevokedCredentialsByAaguid :many
+SELECT sqlc.embed(webauthn_credentials), sqlc.embed(webauthn_users)
+FROM webauthn_credentials
+INNER JOIN webauthn_users ON webauthn_credentials.user_id = webauthn_users._id
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_users._id
+WHERE aaguid = sqlc.arg('aaguid')
+AND meta->>'status' <> 'revoked'
+AND (sqlc.narg('tenant')::VARCHAR IS NULL OR COALESCE(user_tenants.tenant, '') = sqlc.narg('tenant'))
+AND credential_id > sqlc.arg('after_id')
+ORDER BY credential_id
+LIMIT sqlc.arg('row_limit');
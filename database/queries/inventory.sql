@@ -0,0 +1,23 @@
+-- name: DeleteTenantInventory :execrows
+DELETE FROM device_inventory
+WHERE tenant = $1;
+
+-- name: InsertInventoryDevice :exec
+INSERT INTO device_inventory (
+    "tenant", "serial", "label"
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (tenant, serial)
+DO UPDATE SET label = EXCLUDED.label;
+
+-- name: GetInventoryDevice :one
+SELECT *
+FROM device_inventory
+WHERE tenant = $1
+AND serial = $2;
+
+-- name: GetTenantInventorySummary :one
+SELECT COUNT(*) AS device_count, MAX(uploaded_at)::TIMESTAMPTZ AS uploaded_at
+FROM device_inventory
+WHERE tenant = $1;
@@ -0,0 +1,14 @@
+-- name: GetUserProvisioning :one
+SELECT *
+FROM user_provisioning
+WHERE user_id = $1;
+
+-- name: UpsertUserProvisioning :one
+INSERT INTO user_provisioning (
+    "user_id", "active"
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id)
+DO UPDATE SET active = EXCLUDED.active, updated_at = NOW()
+RETURNING *;
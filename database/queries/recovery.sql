@@ -0,0 +1,17 @@
+-- name: ListUserRecoveryMethods :many
+SELECT method
+FROM user_recovery_methods
+WHERE user_id = $1
+ORDER BY method;
+
+-- name: DeleteUserRecoveryMethods :exec
+DELETE FROM user_recovery_methods
+WHERE user_id = $1;
+
+-- name: InsertUserRecoveryMethod :exec
+INSERT INTO user_recovery_methods (
+    "user_id", "method"
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id, method) DO NOTHING;
@@ -0,0 +1,69 @@
+-- name: CountAuditEventsPerDay :many
+SELECT date_trunc('day', occurred_at)::DATE AS "day", event_type, COUNT(*) AS total
+FROM audit_events
+WHERE event_type = ANY(sqlc.arg('event_types')::VARCHAR[])
+AND occurred_at >= sqlc.arg('from_time')
+AND occurred_at < sqlc.arg('to_time')
+GROUP BY "day", event_type
+ORDER BY "day", event_type;
+
+-- name: CountActiveUsers :one
+SELECT COUNT(DISTINCT user_ref) AS total
+FROM audit_events
+WHERE event_type = 'authentication.succeeded'
+AND occurred_at >= sqlc.arg('from_time')
+AND occurred_at < sqlc.arg('to_time');
+
+-- name: CountFailuresByReason :many
+SELECT event_type, split_part(COALESCE(reason, ''), ':', 1)::TEXT AS reason, COUNT(*) AS total
+FROM audit_events
+WHERE event_type IN ('registration.failed', 'authentication.failed')
+AND occurred_at >= sqlc.arg('from_time')
+AND occurred_at < sqlc.arg('to_time')
+GROUP BY 1, 2
+ORDER BY total DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: CountUsersByCredentialCount :many
+SELECT credential_count, COUNT(*) AS total
+FROM (
+    SELECT webauthn_users._id, COUNT(webauthn_credentials.credential_id) AS credential_count
+    FROM webauthn_users
+    LEFT JOIN webauthn_credentials ON webauthn_credentials.user_id = webauthn_users._id
+        AND webauthn_credentials.meta->>'status' = 'active'
+    GROUP BY webauthn_users._id
+) AS per_user
+GROUP BY credential_count
+ORDER BY credential_count;
+
+-- name: CountCredentialsByAttestationType :many
+SELECT COALESCE(attestation_type, '')::VARCHAR AS attestation_type, COUNT(*) AS total
+FROM webauthn_credentials
+WHERE meta->>'status' = 'active'
+GROUP BY 1
+ORDER BY total DESC;
+
+-- name: CountCredentialsByTransport :many
+SELECT transport_name::VARCHAR AS transport, COUNT(*) AS total
+FROM webauthn_credentials, json_array_elements_text(transport) AS transport_name
+WHERE meta->>'status' = 'active'
+GROUP BY transport_name
+ORDER BY total DESC;
+
+-- name: CountCredentialsByAaguid :many
+SELECT aaguid, COUNT(*) AS total
+FROM webauthn_credentials
+WHERE meta->>'status' = 'active'
+GROUP BY aaguid
+ORDER BY total DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: CountCredentialsByBackupState :many
+SELECT
+    COALESCE((flags->>'backupEligible')::BOOLEAN, FALSE)::BOOLEAN AS backup_eligible,
+    COALESCE((flags->>'backupState')::BOOLEAN, FALSE)::BOOLEAN AS backup_state,
+    COUNT(*) AS total
+FROM webauthn_credentials
+WHERE meta->>'status' = 'active'
+GROUP BY 1, 2
+ORDER BY 1, 2;
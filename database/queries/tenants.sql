@@ -0,0 +1,21 @@
+-- name: BindUserTenant :one
+INSERT INTO user_tenants (
+    "user_id", "tenant"
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id)
+DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING tenant;
+
+-- name: GetUserTenant :one
+SELECT tenant FROM user_tenants WHERE user_id = $1;
+
+-- name: SetUserTenant :exec
+INSERT INTO user_tenants (
+    "user_id", "tenant"
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id)
+DO UPDATE SET tenant = EXCLUDED.tenant;
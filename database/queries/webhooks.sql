@@ -0,0 +1,69 @@
+-- name: InsertWebhookSubscription :one
+INSERT INTO webhook_subscriptions (
+    "url", "secret", "event_types"
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+ORDER BY _id;
+
+-- name: UpdateWebhookSubscriptionSecret :one
+UPDATE webhook_subscriptions
+SET secret = $2
+WHERE _id = $1
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE _id = $1;
+
+-- name: EnqueueWebhookEvent :exec
+INSERT INTO webhook_outbox (
+    "subscription_id", "event_type", "payload"
+)
+SELECT _id, sqlc.arg('event_type'), sqlc.arg('payload')
+FROM webhook_subscriptions
+WHERE active
+AND (json_array_length(event_types) = 0 OR jsonb_exists(event_types::jsonb, sqlc.arg('event_type')));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_outbox o
+SET next_attempt_at = sqlc.arg('lease_until')
+FROM webhook_subscriptions s
+WHERE o.subscription_id = s._id
+AND o._id IN (
+    SELECT _id
+    FROM webhook_outbox
+    WHERE status = 'pending'
+    AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING o._id, o.event_type, o.payload, o.attempts, s.url, s.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_outbox
+SET status = 'delivered', attempts = attempts + 1, delivered_at = NOW(), last_error = NULL
+WHERE _id = $1;
+
+-- name: MarkWebhookFailed :exec
+UPDATE webhook_outbox
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_error = $4
+WHERE _id = $1;
+
+-- name: ListDeadWebhookDeliveries :many
+SELECT *
+FROM webhook_outbox
+WHERE status = 'dead'
+ORDER BY _id
+LIMIT $1;
+
+-- name: ReplayWebhookDelivery :execrows
+UPDATE webhook_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL
+WHERE _id = $1
+AND status = 'dead';
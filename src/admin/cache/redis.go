@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"blacksmithlabs.dev/k8s-webauthn/admin/config"
+)
+
+var lock = &sync.Mutex{}
+var client *redis.Client
+
+type CacheClient = redis.Client
+
+const Nil = redis.Nil
+
+var NewScript = redis.NewScript
+
+func ConnectCache() *CacheClient {
+	if client == nil {
+		lock.Lock()
+		defer lock.Unlock()
+		if client == nil {
+			// Connect to the cache
+			client = redis.NewClient(&redis.Options{
+				Addr:     config.GetRedisHost(),
+				Password: config.GetRedisPassword(),
+				PoolSize: config.GetRedisPoolSize(),
+				DB:       0,
+			})
+		}
+	}
+	return client
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,11 @@ const defaultSessionTimeout = 3600
 const defaultRedisPoolSize = 10
 const defaultRedisHost = "localhost:6379"
 const defaultAppPort = "8081"
+const defaultReauthIdleTimeout = 300
+const defaultStatsCacheTTL = 300
+const defaultEventChannel = "webauthn:live-events"
+const defaultEventStream = "webauthn:events"
+const defaultEventStreamMaxLen = 100000
 
 var (
 	// Session cache info
@@ -23,6 +29,19 @@ var (
 	postgresUrl = os.Getenv("POSTGRES_URL")
 	// Application Config info
 	appPort = os.Getenv("APP_PORT")
+	// Webauthn Config info
+	rpDisplayName = os.Getenv("RP_DISPLAY_NAME")
+	rpID          = os.Getenv("RP_ID")
+	rpOrigins     = os.Getenv("RP_ORIGINS")
+	// Admin login info
+	reauthIdleTimeout = os.Getenv("REAUTH_IDLE_TIMEOUT")
+	// Reporting info
+	statsCacheTTL = os.Getenv("STATS_CACHE_TTL")
+	// Event stream info, revocations made by admins are published next to the ceremony events
+	eventStream       = os.Getenv("EVENT_STREAM")
+	eventStreamMaxLen = os.Getenv("EVENT_STREAM_MAX_LEN")
+	// Live event feed info
+	eventChannel = os.Getenv("EVENT_CHANNEL")
 )
 
 func GetRedisPoolSize() int {
@@ -81,3 +100,79 @@ func GetAppPort() string {
 
 	return appPort
 }
+
+func GetRPDisplayName() string {
+	return rpDisplayName
+}
+
+func GetRPID() string {
+	return rpID
+}
+
+func GetRPOrigins() []string {
+	if rpOrigins == "" {
+		return []string{}
+	}
+	return strings.Split(rpOrigins, ",")
+}
+
+// GetReauthIdleTimeout is how long after the passkey ceremony destructive actions require a fresh one
+func GetReauthIdleTimeout() time.Duration {
+	if reauthIdleTimeout != "" {
+		if value, err := strconv.Atoi(reauthIdleTimeout); err != nil {
+			fmt.Println("Failed to parse REAUTH_IDLE_TIMEOUT", err)
+		} else if value < 1 {
+			fmt.Println("REAUTH_IDLE_TIMEOUT must be greater than 0")
+		} else {
+			return time.Duration(value) * time.Second
+		}
+	}
+
+	return defaultReauthIdleTimeout * time.Second
+}
+
+// GetStatsCacheTTL is how long computed statistics are served from the cache before the aggregates run again
+func GetStatsCacheTTL() time.Duration {
+	if statsCacheTTL != "" {
+		if value, err := strconv.Atoi(statsCacheTTL); err != nil {
+			fmt.Println("Failed to parse STATS_CACHE_TTL", err)
+		} else if value < 1 {
+			fmt.Println("STATS_CACHE_TTL must be greater than 0")
+		} else {
+			return time.Duration(value) * time.Second
+		}
+	}
+
+	return defaultStatsCacheTTL * time.Second
+}
+
+func GetEventStream() string {
+	if eventStream == "" {
+		return defaultEventStream
+	}
+
+	return eventStream
+}
+
+func GetEventStreamMaxLen() int64 {
+	if eventStreamMaxLen != "" {
+		if value, err := strconv.ParseInt(eventStreamMaxLen, 10, 64); err != nil {
+			fmt.Println("Failed to parse EVENT_STREAM_MAX_LEN", err)
+		} else if value < 1 {
+			fmt.Println("EVENT_STREAM_MAX_LEN must be greater than 0")
+		} else {
+			return value
+		}
+	}
+
+	return defaultEventStreamMaxLen
+}
+
+// GetEventChannel is the pub/sub channel the auth servers broadcast ceremony events on
+func GetEventChannel() string {
+	if eventChannel == "" {
+		return defaultEventChannel
+	}
+
+	return eventChannel
+}
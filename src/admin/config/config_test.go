@@ -1,6 +1,7 @@
 package config
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -133,3 +134,110 @@ func TestGetAppPort(t *testing.T) {
 		t.Errorf("GetAppPort() = %v, want %v", v, "8080")
 	}
 }
+
+func TestGetRPOrigins(t *testing.T) {
+	curRPOrigins := rpOrigins
+	defer func() {
+		rpOrigins = curRPOrigins
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: []string{},
+		},
+		{
+			name:     "Single value",
+			input:    "http://localhost:8080",
+			expected: []string{"http://localhost:8080"},
+		},
+		{
+			name:     "Multiple values",
+			input:    "http://localhost:8080,http://localhost:8081",
+			expected: []string{"http://localhost:8080", "http://localhost:8081"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpOrigins = tt.input
+			if v := GetRPOrigins(); !reflect.DeepEqual(v, tt.expected) {
+				t.Errorf("GetRPOrigins() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetReauthIdleTimeout(t *testing.T) {
+	curReauthIdleTimeout := reauthIdleTimeout
+	defer func() {
+		reauthIdleTimeout = curReauthIdleTimeout
+	}()
+
+	// Test case 1 default
+	reauthIdleTimeout = ""
+	if v := GetReauthIdleTimeout(); v != defaultReauthIdleTimeout*time.Second {
+		t.Errorf("GetReauthIdleTimeout() = %v, want %v", v, defaultReauthIdleTimeout*time.Second)
+	}
+
+	// Test case 2 value
+	reauthIdleTimeout = "60"
+	if v := GetReauthIdleTimeout(); v != 60*time.Second {
+		t.Errorf("GetReauthIdleTimeout() = %v, want %v", v, 60*time.Second)
+	}
+
+	// Test case 3 invalid falls back to default
+	reauthIdleTimeout = "0"
+	if v := GetReauthIdleTimeout(); v != defaultReauthIdleTimeout*time.Second {
+		t.Errorf("GetReauthIdleTimeout() = %v, want %v", v, defaultReauthIdleTimeout*time.Second)
+	}
+}
+
+func TestGetStatsCacheTTL(t *testing.T) {
+	curStatsCacheTTL := statsCacheTTL
+	defer func() {
+		statsCacheTTL = curStatsCacheTTL
+	}()
+
+	// Test case 1 default
+	statsCacheTTL = ""
+	if v := GetStatsCacheTTL(); v != defaultStatsCacheTTL*time.Second {
+		t.Errorf("GetStatsCacheTTL() = %v, want %v", v, defaultStatsCacheTTL*time.Second)
+	}
+
+	// Test case 2 value
+	statsCacheTTL = "60"
+	if v := GetStatsCacheTTL(); v != 60*time.Second {
+		t.Errorf("GetStatsCacheTTL() = %v, want %v", v, 60*time.Second)
+	}
+
+	// Test case 3 invalid falls back to default
+	statsCacheTTL = "abc"
+	if v := GetStatsCacheTTL(); v != defaultStatsCacheTTL*time.Second {
+		t.Errorf("GetStatsCacheTTL() = %v, want %v", v, defaultStatsCacheTTL*time.Second)
+	}
+}
+
+func TestGetEventChannel(t *testing.T) {
+	curEventChannel := eventChannel
+	defer func() {
+		eventChannel = curEventChannel
+	}()
+
+	// Test case 1 default
+	eventChannel = ""
+	if v := GetEventChannel(); v != defaultEventChannel {
+		t.Errorf("GetEventChannel() = %v, want %v", v, defaultEventChannel)
+	}
+
+	// Test case 2 value
+	eventChannel = "custom:live"
+	if v := GetEventChannel(); v != "custom:live" {
+		t.Errorf("GetEventChannel() = %v, want %v", v, "custom:live")
+	}
+}
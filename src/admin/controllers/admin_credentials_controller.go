@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const enrollRequestKey = "enrollRequest"
+
+// EnrollRequest is the in-flight registration of another admin passkey, kept in the session between the begin and finish calls
+type EnrollRequest struct {
+	RequestID   string
+	UserID      int64
+	SessionData webauthn.SessionData
+}
+
+// POST /session/credentials/ end point to start registering another passkey the signed in administrator may sign in with
+func BeginEnrollAdminCredential(c *gin.Context) {
+	admin := getAdminSession(c)
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserWithCredentialsByID(admin.UserID, true)
+	if err != nil {
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+
+	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
+	exclusions := utils.Map(user.WebAuthnCredentials(), func(credential webauthn.Credential) protocol.CredentialDescriptor {
+		return credential.Descriptor()
+	})
+	options, sessionData, err := webAuthn.BeginRegistration(user,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{UserVerification: protocol.VerificationRequired}),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		abortWithError(c, internalError("Failed to create registration options", err))
+		return
+	}
+
+	requestId := uuid.New().String()
+
+	session := getSession(c)
+	session.Set(enrollRequestKey, EnrollRequest{RequestID: requestId, UserID: user.ID, SessionData: *sessionData})
+	if err := session.Save(); err != nil {
+		abortWithError(c, internalError("Failed to save request data", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StartRegistrationResponse{
+		RequestID: requestId,
+		Options:   *options,
+	})
+}
+
+// PUT /session/credentials/:requestId end point to finish registering the passkey and enroll it for the admin service
+func FinishEnrollAdminCredential(c *gin.Context) {
+	requestId := c.Param("requestId")
+	admin := getAdminSession(c)
+
+	session := getSession(c)
+	enrollRequest, ok := session.Get(enrollRequestKey).(EnrollRequest)
+	if !ok || enrollRequest.RequestID != requestId || enrollRequest.UserID != admin.UserID {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorRequestNotFound, "Request not found", fmt.Errorf("request(%v) not in session", requestId)))
+		return
+	}
+
+	var requestPayload dto.FinishRegistrationRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	parsedCredential, err := requestPayload.Credential.Parse()
+	if err != nil {
+		abortWithError(c, utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Failed to parse credential", err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserByID(admin.UserID)
+	if err != nil {
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+
+	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
+	credential, err := webAuthn.CreateCredential(user, enrollRequest.SessionData, parsedCredential)
+	if err != nil {
+		appErr := utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorRegistrationFailed, "Failed to finish registration", err)
+		recordAuditEvent(c, audit_service.Event{
+			Type:      audit_service.EventRegistrationFailed,
+			UserID:    user.ID,
+			UserRef:   user.RefID,
+			RequestID: requestId,
+			Reason:    string(appErr.Code) + ": " + err.Error(),
+		})
+		abortWithError(c, appErr)
+		return
+	}
+
+	if err := service.InsertCredential(user, credential, credential_service.CredentialMeta{}); err != nil {
+		abortWithError(c, internalError("Failed to save credential", err))
+		return
+	}
+
+	adminService, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+	if err := adminService.EnrollCredential(admin.UserRef, credential.ID, admin.UserRef); err != nil {
+		abortWithError(c, internalError("Failed to enroll credential", err))
+		return
+	}
+
+	session.Delete(enrollRequestKey)
+	if err := session.Save(); err != nil {
+		logger.Warn("Failed to clear enroll request", "error", err, "requestId", requestId)
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventCredentialRegistered,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credential.ID,
+		RequestID:    requestId,
+		Details:      map[string]any{"attestationType": credential.AttestationType, "action": "admin_credential.enrolled"},
+	})
+
+	c.JSON(http.StatusOK, dto.FinishRegistrationResponse{
+		RequestID:  requestId,
+		Credential: dto.CredentialResponseFromWebauthn(credential),
+	})
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/k8s-webauthn/admin/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const adminSessionKey = "admin"
+
+var reauthIdleTimeout = config.GetReauthIdleTimeout()
+
+// AdminSession is what is kept in the session store once an administrator has signed in with their passkey
+type AdminSession struct {
+	UserID          int64     `json:"-"`
+	UserRef         string    `json:"userId"`
+	UserName        string    `json:"userName"`
+	AuthenticatedAt time.Time `json:"authenticatedAt"`
+	LastSeenAt      time.Time `json:"lastSeenAt"`
+	// ReauthRequired is set once the passkey ceremony is too long ago and only cleared by a new one
+	ReauthRequired bool `json:"reauthRequired"`
+}
+
+func getAdminSession(c *gin.Context) *AdminSession {
+	if admin, ok := c.Get(adminSessionKey); ok {
+		return admin.(*AdminSession)
+	}
+	return nil
+}
+
+// RequireAdmin only lets requests through from administrators who signed in with a passkey
+func RequireAdmin(c *gin.Context) {
+	session := getSession(c)
+	admin, ok := session.Get(adminSessionKey).(AdminSession)
+	if !ok {
+		abortWithError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorUnauthorized, "Sign in required", fmt.Errorf("no admin session")))
+		return
+	}
+
+	// Measured from the ceremony, otherwise an admin who keeps making requests would never have to repeat it
+	now := time.Now()
+	if now.Sub(admin.AuthenticatedAt) > reauthIdleTimeout {
+		admin.ReauthRequired = true
+	}
+	admin.LastSeenAt = now
+	session.Set(adminSessionKey, admin)
+	if err := session.Save(); err != nil {
+		abortWithError(c, internalError("Failed to save session", err))
+		return
+	}
+
+	c.Set(adminSessionKey, &admin)
+	c.Next()
+}
+
+// RequireRecentAuth guards destructive actions, admins who signed in too long ago must repeat the passkey ceremony first
+func RequireRecentAuth(c *gin.Context) {
+	admin := getAdminSession(c)
+	if admin == nil || admin.ReauthRequired {
+		abortWithError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorReauthenticationRequired, "Sign in again to continue", fmt.Errorf("admin signed in longer than %v ago", reauthIdleTimeout)))
+		return
+	}
+	c.Next()
+}
+
+// RequireJSON rejects state changing requests without a JSON body. Forms and fetches without a CORS preflight can only
+// send form or plain text bodies, and the admin service answers no preflight, so this keeps other sites from using the
+// session cookie of a signed in admin. The JSON lines of an import count as JSON.
+func RequireJSON(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		if !strings.Contains(c.ContentType(), "json") {
+			abortWithError(c, utils.NewError(http.StatusUnsupportedMediaType, dto.ErrorInvalidRequest, "Content-Type must be application/json", fmt.Errorf("%v %v sent as %q", c.Request.Method, c.Request.URL.Path, c.ContentType())))
+			return
+		}
+	}
+	c.Next()
+}
+
+// GET /session end point to get the signed in administrator and their roles
+func GetAdminSession(c *gin.Context) {
+	admin := getAdminSession(c)
+
+	service, err := admin_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return
+	}
+	roles, err := service.ListRoles(admin.UserRef)
+	if err != nil {
+		abortWithError(c, internalError("Failed to load admin roles", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"admin": admin, "roles": roles})
+}
+
+const adminRolesKey = "adminRoles"
+
+// getAdminRoles loads the roles of the signed in admin once per request.
+// Roles are read from the database every request so revoking one takes effect immediately.
+func getAdminRoles(c *gin.Context) (admin_service.Roles, error) {
+	if roles, ok := c.Get(adminRolesKey); ok {
+		return roles.(admin_service.Roles), nil
+	}
+
+	admin := getAdminSession(c)
+	if admin == nil {
+		return nil, fmt.Errorf("no admin session")
+	}
+
+	service, err := admin_service.New(c)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := service.GetRoles(admin.UserRef)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set(adminRolesKey, roles)
+	return roles, nil
+}
+
+// requirePermission aborts the request unless the signed in admin has the permission for the tenant
+func requirePermission(c *gin.Context, tenant string, permission admin_service.Permission) bool {
+	roles, err := getAdminRoles(c)
+	if err != nil {
+		abortWithError(c, internalError("Failed to load admin roles", err))
+		return false
+	}
+	if !roles.Can(tenant, permission) {
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Missing permission "+string(permission), fmt.Errorf("admin lacks %v for tenant(%v)", permission, tenant)))
+		return false
+	}
+	return true
+}
+
+// TenantSource picks the tenant a route's permission is checked for, it aborts the request itself when it can not
+type TenantSource func(c *gin.Context) (string, bool)
+
+// RequirePermission only lets requests through from admins who have the permission for the tenant of the request.
+// Every admin route checks its permission with it, or with RequireGlobalPermission, so no handler can go without one.
+func RequirePermission(permission admin_service.Permission, source TenantSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, ok := source(c)
+		if !ok || !requirePermission(c, tenant, permission) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireGlobalPermission only lets requests through from admins who have the permission for every tenant.
+// Users and credentials are shared by all tenants, so a grant for one tenant must not reach them.
+func RequireGlobalPermission(permission admin_service.Permission) gin.HandlerFunc {
+	return RequirePermission(permission, func(c *gin.Context) (string, bool) {
+		return admin_service.AllTenants, true
+	})
+}
+
+// TenantParam checks the permission for the tenant named in the path
+func TenantParam(name string) TenantSource {
+	return func(c *gin.Context) (string, bool) {
+		return c.Param(name), true
+	}
+}
+
+// TenantQuery checks the permission for the tenant of the query parameter, "" being the default tenant, and for every
+// tenant when the parameter is left out
+func TenantQuery(name string) TenantSource {
+	return func(c *gin.Context) (string, bool) {
+		return c.DefaultQuery(name, admin_service.AllTenants), true
+	}
+}
+
+// TenantFilter checks the permission for the tenant a listing is filtered by, an empty filter lists every tenant
+func TenantFilter(name string) TenantSource {
+	return func(c *gin.Context) (string, bool) {
+		if tenant := c.Query(name); tenant != "" {
+			return tenant, true
+		}
+		return admin_service.AllTenants, true
+	}
+}
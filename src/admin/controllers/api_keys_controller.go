@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	api_key_service "blacksmithlabs.dev/webauthn-k8s/shared/services/api_key"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func getApiKeyService(c *gin.Context) (*api_key_service.ApiKeyService, bool) {
+	service, err := api_key_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
+	}
+	return service, true
+}
+
+// POST /api-keys end point to issue a bearer token for a machine client, the token is only returned once
+func CreateApiKey(c *gin.Context) {
+	var requestPayload dto.CreateApiKeyRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getApiKeyService(c)
+	if !ok {
+		return
+	}
+
+	token, key, err := service.Create(requestPayload.Name, api_key_service.Scope(requestPayload.Scope))
+	if err != nil {
+		abortWithError(c, internalError("Failed to create API key", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "api_key.created", "apiKeyId": key.ID, "scope": key.Scope},
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"key": key, "token": token})
+}
+
+// GET /api-keys end point to list the API keys without their tokens
+func ListApiKeys(c *gin.Context) {
+	service, ok := getApiKeyService(c)
+	if !ok {
+		return
+	}
+
+	keys, err := service.List()
+	if err != nil {
+		abortWithError(c, internalError("Failed to list API keys", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// DELETE /api-keys/:keyId end point to revoke an API key
+func RevokeApiKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("keyId"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid keyId", err))
+		return
+	}
+
+	service, ok := getApiKeyService(c)
+	if !ok {
+		return
+	}
+
+	if err := service.Revoke(id); err == pgx.ErrNoRows {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNotFound, "API key not found", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to revoke API key", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "api_key.revoked", "apiKeyId": id},
+	})
+
+	c.Status(http.StatusNoContent)
+}
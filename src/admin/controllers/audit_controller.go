@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GET /audit/events end point to export the audit log, filtered by user, tenant and time range.
+// Without a tenant only admins with a role for every tenant may read it.
+func ListAuditEvents(c *gin.Context) {
+	tenant := c.Query("tenant")
+
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid from time, expected RFC3339", err))
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid to time, expected RFC3339", err))
+		return
+	}
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid after cursor", err))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid limit", err))
+		return
+	}
+
+	service, err := audit_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return
+	}
+
+	events, err := service.List(audit_service.Filter{
+		UserRef: c.Query("user"),
+		Tenant:  tenant,
+		From:    from,
+		To:      to,
+		AfterID: afterID,
+		Limit:   limit,
+	})
+	if err != nil {
+		abortWithError(c, internalError("Failed to list audit events", err))
+		return
+	}
+
+	response := gin.H{"events": events}
+	if len(events) > 0 {
+		response["next"] = events[len(events)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
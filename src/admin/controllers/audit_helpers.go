@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	api_key_service "blacksmithlabs.dev/webauthn-k8s/shared/services/api_key"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+// auditRequest holds the request details recorded with every audit event, taken from the request up front so work
+// that outlives the request can still record events
+type auditRequest struct {
+	ipAddress string
+	userAgent string
+	tenant    string
+	requestID string
+	actor     string
+}
+
+func newAuditRequest(c *gin.Context) auditRequest {
+	request := auditRequest{
+		ipAddress: c.ClientIP(),
+		userAgent: c.Request.UserAgent(),
+		tenant:    c.GetHeader("X-Tenant-ID"),
+		requestID: c.GetHeader("X-Request-ID"),
+	}
+	if admin := getAdminSession(c); admin != nil {
+		request.actor = admin.UserRef
+	} else if key, ok := c.Get(apiKeyKey); ok {
+		request.actor = "api-key:" + key.(*api_key_service.ApiKey).Name
+	}
+	return request
+}
+
+// record fills in the request details for the event and appends it to the audit log.
+// Failing to audit never fails the request, but it is logged so it can be alerted on.
+func (r auditRequest) record(ctx context.Context, event audit_service.Event) {
+	event.IPAddress = r.ipAddress
+	event.UserAgent = r.userAgent
+	if event.Tenant == "" {
+		event.Tenant = r.tenant
+	}
+	if event.RequestID == "" {
+		event.RequestID = r.requestID
+	}
+	if event.Details == nil {
+		event.Details = map[string]any{}
+	}
+	if r.actor != "" {
+		event.Details["actor"] = r.actor
+	}
+
+	service, err := audit_service.New(ctx)
+	if err != nil {
+		logger.Error("Failed to get audit service", "error", err, "event", event.Type)
+		return
+	}
+	if err := service.Record(&event); err != nil {
+		logger.Error("Failed to record audit event", "error", err, "event", event.Type)
+	}
+}
+
+// recordAuditEvent fills in the request details for the event and appends it to the audit log
+func recordAuditEvent(c *gin.Context, event audit_service.Event) {
+	newAuditRequest(c).record(c, event)
+}
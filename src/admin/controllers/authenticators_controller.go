@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/k8s-webauthn/admin/cache"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+// Finished revocations can be looked up for a week
+const revocationJobTTL = 7 * 24 * time.Hour
+
+// A revocation holds the lock of its authenticator model while it runs, the lock expires on its own should the
+// service stop before the revocation finishes
+const revocationLockTTL = 10 * time.Minute
+
+const revocationJobContextKey = "revocationJob"
+
+// The AAGUID is taken in its usual UUID form, as vendors publish it, and stored as the raw 16 bytes.
+// The zero AAGUID is what authenticators without attestation and software authenticators report, it names no model.
+func getAaguidParam(c *gin.Context) (uuid.UUID, bool) {
+	aaguid, err := uuid.Parse(c.Param("aaguid"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid aaguid, expected a UUID", err))
+		return uuid.Nil, false
+	}
+	if aaguid == uuid.Nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "The zero aaguid names no authenticator model", fmt.Errorf("zero aaguid")))
+		return uuid.Nil, false
+	}
+	return aaguid, true
+}
+
+func revocationJobKey(jobID string) string {
+	return "admin:revocation:" + jobID
+}
+
+// The revocations running for a model are held in one hash by tenant, a revocation for every tenant holds the * field
+func revocationLockKey(aaguid uuid.UUID) string {
+	return "admin:revocation:lock:" + aaguid.String()
+}
+
+// lockRevocation takes the tenant's lock of the model unless a revocation already runs for the tenant, for every tenant,
+// or, for a revocation of every tenant, for any tenant at all. Both would revoke the same credentials twice.
+var lockRevocation = cache.NewScript(`
+if redis.call("HEXISTS", KEYS[1], "*") == 1 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+	return 0
+end
+if ARGV[1] == "*" and redis.call("HLEN", KEYS[1]) > 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+func unlockRevocation(ctx context.Context, aaguid uuid.UUID, tenant string) {
+	cache.ConnectCache().HDel(ctx, revocationLockKey(aaguid), tenant)
+}
+
+func saveRevocationJob(ctx context.Context, job *dto.RevokeAuthenticatorResponse) error {
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return cache.ConnectCache().Set(ctx, revocationJobKey(job.JobID), encoded, revocationJobTTL).Err()
+}
+
+func authenticatorCredentialResponse(credential *credential_service.CredentialModel) dto.AuthenticatorCredentialResponse {
+	return dto.AuthenticatorCredentialResponse{
+		User:       userResponse(&credential.User.Value),
+		Credential: credentialDetailResponse(*credential),
+	}
+}
+
+// GET /authenticators/:aaguid/credentials end point to preview the users and credentials revoking an authenticator model
+// would affect, among the users of the tenant query parameter or of every tenant without it
+func PreviewAuthenticatorRevocation(c *gin.Context) {
+	aaguid, ok := getAaguidParam(c)
+	if !ok {
+		return
+	}
+	tenant := c.DefaultQuery("tenant", admin_service.AllTenants)
+	afterID, err := base64.RawURLEncoding.DecodeString(c.Query("after"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid after cursor, expected base64url", err))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid limit", err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	usage, err := service.CountCredentialsByAaguid(aaguid[:], tenant)
+	if err != nil {
+		abortWithError(c, internalError("Failed to count credentials", err))
+		return
+	}
+	affected, err := service.ListCredentialsByAaguid(aaguid[:], tenant, afterID, limit)
+	if err != nil {
+		abortWithError(c, internalError("Failed to list credentials", err))
+		return
+	}
+
+	response := dto.AuthenticatorPreviewResponse{
+		AAGUID:      aaguid.String(),
+		Tenant:      tenant,
+		Credentials: usage.Credentials,
+		Users:       usage.Users,
+		Items:       utils.Map(affected, authenticatorCredentialResponse),
+	}
+	if len(affected) > 0 {
+		response.Next = affected[len(affected)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// revokeTenant is the tenant whose users a revocation reaches, every tenant when the request names none
+func revokeTenant(requestPayload *dto.RevokeAuthenticatorRequest) string {
+	if requestPayload.Tenant != nil {
+		return *requestPayload.Tenant
+	}
+	return admin_service.AllTenants
+}
+
+// RevokeAuthenticatorTenant checks the permission for the tenant a revocation request reaches
+func RevokeAuthenticatorTenant(c *gin.Context) (string, bool) {
+	var requestPayload dto.RevokeAuthenticatorRequest
+	if err := c.ShouldBindBodyWith(&requestPayload, binding.JSON); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return "", false
+	}
+	return revokeTenant(&requestPayload), true
+}
+
+// POST /authenticators/:aaguid/revoke end point to revoke every credential of an authenticator model. The credentials
+// are revoked a batch at a time in the background, the response is the job to follow with GetAuthenticatorRevocation.
+func RevokeAuthenticator(c *gin.Context) {
+	aaguid, ok := getAaguidParam(c)
+	if !ok {
+		return
+	}
+
+	// Read again from the body RevokeAuthenticatorTenant checked the permission for
+	var requestPayload dto.RevokeAuthenticatorRequest
+	if err := c.ShouldBindBodyWith(&requestPayload, binding.JSON); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+	tenant := revokeTenant(&requestPayload)
+
+	// The batches run after the response is sent, so they must not use the request's context
+	service, err := credential_service.New(context.Background())
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return
+	}
+	service = withEvents(c, service)
+
+	job := &dto.RevokeAuthenticatorResponse{
+		JobID:     uuid.New().String(),
+		AAGUID:    aaguid.String(),
+		Tenant:    tenant,
+		Status:    dto.RevocationRunning,
+		StartedAt: time.Now(),
+	}
+
+	locked, err := lockRevocation.Run(c, cache.ConnectCache(), []string{revocationLockKey(aaguid)}, tenant, job.JobID, revocationLockTTL.Milliseconds()).Bool()
+	if err != nil {
+		abortWithError(c, internalError("Failed to lock the authenticator model", err))
+		return
+	}
+	if !locked {
+		abortWithError(c, utils.NewError(http.StatusConflict, dto.ErrorConflict, "The authenticator model is already being revoked for the tenant", fmt.Errorf("revocation of aaguid(%v) for tenant(%v) in progress", job.AAGUID, tenant)))
+		return
+	}
+	if err := saveRevocationJob(c, job); err != nil {
+		unlockRevocation(c, aaguid, tenant)
+		abortWithError(c, internalError("Failed to save revocation job", err))
+		return
+	}
+
+	go runAuthenticatorRevocation(service, newAuditRequest(c), aaguid, job, requestPayload)
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// runAuthenticatorRevocation revokes the batches one after the other and saves the job's progress after each of them
+func runAuthenticatorRevocation(service *credential_service.CredentialService, auditor auditRequest, aaguid uuid.UUID, job *dto.RevokeAuthenticatorResponse, request dto.RevokeAuthenticatorRequest) {
+	ctx := context.Background()
+	defer unlockRevocation(ctx, aaguid, job.Tenant)
+
+	eventTenant := job.Tenant
+	if eventTenant == admin_service.AllTenants {
+		eventTenant = ""
+	}
+
+	users := map[int64]bool{}
+	var batchErr error
+	for {
+		revoked, err := service.RevokeCredentialBatchByAaguid(aaguid[:], job.Tenant, request.BatchSize, request.Reason, request.Notify)
+		if err != nil {
+			batchErr = err
+			break
+		}
+		if len(revoked) == 0 {
+			break
+		}
+
+		job.Batches++
+		job.Revoked += len(revoked)
+		for _, credential := range revoked {
+			users[credential.User.Value.ID] = true
+			auditor.record(ctx, audit_service.Event{
+				Type:         audit_service.EventCredentialStatusChanged,
+				Tenant:       eventTenant,
+				UserID:       credential.User.Value.ID,
+				UserRef:      credential.User.Value.RefID,
+				CredentialID: credential.ID,
+				Reason:       request.Reason,
+				Details:      map[string]any{"status": credential_service.CredentialStatusRevoked, "aaguid": job.AAGUID, "jobId": job.JobID},
+			})
+		}
+		job.Users = len(users)
+
+		if err := saveRevocationJob(ctx, job); err != nil {
+			logger.Warn("Failed to save revocation progress", "error", err, "jobId", job.JobID)
+		}
+		cache.ConnectCache().Expire(ctx, revocationLockKey(aaguid), revocationLockTTL)
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = dto.RevocationCompleted
+	if batchErr != nil {
+		logger.Error("Failed to revoke authenticator", "error", batchErr, "jobId", job.JobID, "revoked", job.Revoked)
+		job.Status = dto.RevocationFailed
+		job.Error = "Failed to revoke credentials after " + strconv.Itoa(job.Revoked) + " were revoked"
+	}
+	if err := saveRevocationJob(ctx, job); err != nil {
+		logger.Error("Failed to save revocation job", "error", err, "jobId", job.JobID)
+	}
+
+	// The summary is recorded even when a batch failed, the batches before it are already committed
+	details := map[string]any{
+		"action":  "authenticator.revoked",
+		"aaguid":  job.AAGUID,
+		"tenant":  job.Tenant,
+		"jobId":   job.JobID,
+		"revoked": job.Revoked,
+		"users":   job.Users,
+		"batches": job.Batches,
+		"notify":  request.Notify,
+	}
+	if batchErr != nil {
+		details["error"] = batchErr.Error()
+	}
+	auditor.record(ctx, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Tenant:  eventTenant,
+		Reason:  request.Reason,
+		Details: details,
+	})
+}
+
+// RevocationJobTenant checks the permission for the tenant a revocation job runs for, the job is kept for the handler
+func RevocationJobTenant(c *gin.Context) (string, bool) {
+	jobID := c.Param("jobId")
+
+	cached, err := cache.ConnectCache().Get(c, revocationJobKey(jobID)).Bytes()
+	if err == cache.Nil {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNotFound, "Revocation not found", fmt.Errorf("revocation job(%v) not found", jobID)))
+		return "", false
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to load revocation job", err))
+		return "", false
+	}
+	var job dto.RevokeAuthenticatorResponse
+	if err := json.Unmarshal(cached, &job); err != nil {
+		abortWithError(c, internalError("Failed to load revocation job", err))
+		return "", false
+	}
+
+	c.Set(revocationJobContextKey, &job)
+	return job.Tenant, true
+}
+
+// GET /authenticators/revocations/:jobId end point to follow a revocation started with RevokeAuthenticator
+func GetAuthenticatorRevocation(c *gin.Context) {
+	c.JSON(http.StatusOK, c.MustGet(revocationJobContextKey).(*dto.RevokeAuthenticatorResponse))
+}
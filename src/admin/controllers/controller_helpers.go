@@ -7,7 +7,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"blacksmithlabs.dev/k8s-webauthn/admin/config"
-	"blacksmithlabs.dev/k8s-webauthn/admin/utils"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 )
 
 var sessionTimeout = config.GetSessionTimeout()
@@ -19,8 +20,39 @@ func getSession(c *gin.Context) sessions.Session {
 	session.Options(sessions.Options{
 		Path:     "/",
 		MaxAge:   int(sessionTimeout.Seconds()),
-		SameSite: http.SameSiteNoneMode,
+		SameSite: http.SameSiteStrictMode,
 		Secure:   true,
 	})
 	return session
 }
+
+// abortWithError logs the full error and responds with an RFC 7807 problem carrying only its code and safe message.
+// The admin API shares the error shape of the auth server so tooling can handle both the same way.
+func abortWithError(c *gin.Context, err error) {
+	appErr := utils.AsAppError(err)
+
+	logArgs := []any{"code", appErr.Code, "status", appErr.Status, "path", c.FullPath(), "error", appErr.Err}
+	if appErr.Status >= http.StatusInternalServerError {
+		logger.Error(appErr.Message, logArgs...)
+	} else {
+		logger.Warn(appErr.Message, logArgs...)
+	}
+
+	problem := appErr.Problem()
+	problem.Instance = c.Request.URL.Path
+	c.Header("Content-Type", dto.ProblemContentType)
+	c.AbortWithStatusJSON(appErr.Status, problem)
+}
+
+func invalidRequestFormat(err error) *utils.AppError {
+	return utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid request format", err)
+}
+
+// invalidRequestPayload includes the validation message since it is written by us and safe to return
+func invalidRequestPayload(err error) *utils.AppError {
+	return utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid request payload: "+err.Error(), err)
+}
+
+func internalError(message string, err error) *utils.AppError {
+	return utils.NewError(http.StatusInternalServerError, dto.ErrorInternal, message, err)
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
+)
+
+// withEvents has the service publish the events of the changes admins make, such as revocations, to the stream
+// consumers the same way the auth server publishes ceremony events
+func withEvents(c *gin.Context, service *credential_service.CredentialService) *credential_service.CredentialService {
+	if publisher, ok := c.Get("events"); ok {
+		service.WithEvents(publisher.(event_publisher.EventPublisher), c.GetHeader("X-Tenant-ID"))
+	}
+	return service
+}
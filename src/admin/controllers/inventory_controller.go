@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	inventory_service "blacksmithlabs.dev/webauthn-k8s/shared/services/inventory"
+)
+
+func getInventoryService(c *gin.Context) (*inventory_service.InventoryService, bool) {
+	service, err := inventory_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
+	}
+	return service, true
+}
+
+// GET /inventory/:tenant end point to get the size of a tenant's device inventory for enterprise attestation
+func GetInventory(c *gin.Context) {
+	tenant := c.Param("tenant")
+
+	service, ok := getInventoryService(c)
+	if !ok {
+		return
+	}
+
+	response, err := service.Summary(tenant)
+	if err != nil {
+		abortWithError(c, internalError("Failed to get inventory", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /inventory/:tenant end point to replace a tenant's device inventory with the serial numbers of its company-issued keys
+func UploadInventory(c *gin.Context) {
+	tenant := c.Param("tenant")
+
+	var requestPayload dto.UploadInventoryRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getInventoryService(c)
+	if !ok {
+		return
+	}
+
+	response, err := service.Replace(tenant, requestPayload.Devices)
+	if err != nil {
+		abortWithError(c, internalError("Failed to upload inventory", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Tenant:  tenant,
+		Details: map[string]any{"action": "inventory.uploaded", "devices": response.Devices},
+	})
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/k8s-webauthn/admin/cache"
+	"blacksmithlabs.dev/k8s-webauthn/admin/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+// Proxies and load balancers close connections that stay quiet for too long
+const liveEventsHeartbeat = 15 * time.Second
+
+var eventChannel = config.GetEventChannel()
+
+// liveEventFilter selects the events a viewer is allowed to and asked to see, empty fields match everything
+type liveEventFilter struct {
+	userRef string
+	tenant  string
+}
+
+func (f liveEventFilter) matches(envelope *dto.EventEnvelope) bool {
+	if f.tenant != "" && envelope.Tenant != f.tenant {
+		return false
+	}
+	if f.userRef != "" && envelope.UserID() != f.userRef {
+		return false
+	}
+	return true
+}
+
+func liveEventTenant(c *gin.Context) string {
+	return c.DefaultQuery("tenant", c.GetHeader("X-Tenant-ID"))
+}
+
+// LiveEventTenant checks the permission for the tenant the events are filtered by, without one only admins with a role
+// for every tenant may watch
+func LiveEventTenant(c *gin.Context) (string, bool) {
+	if tenant := liveEventTenant(c); tenant != "" {
+		return tenant, true
+	}
+	return admin_service.AllTenants, true
+}
+
+// GET /admin/events end point to stream ceremony events as they happen, filtered by user and tenant, as Server-Sent Events
+func StreamLiveEvents(c *gin.Context) {
+	filter := liveEventFilter{
+		userRef: c.Query("user"),
+		tenant:  liveEventTenant(c),
+	}
+	pubsub := cache.ConnectCache().Subscribe(c.Request.Context(), eventChannel)
+	defer pubsub.Close()
+	// Wait for the subscription so events are not missed between the response starting and the subscription landing
+	if _, err := pubsub.Receive(c.Request.Context()); err != nil {
+		abortWithError(c, internalError("Failed to subscribe to events", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserRef: filter.userRef,
+		Tenant:  filter.tenant,
+		Details: map[string]any{"action": "events.watched"},
+	})
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	messages := pubsub.Channel()
+	heartbeat := time.NewTicker(liveEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// SSE comment lines are ignored by clients and only keep the connection open
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case message, ok := <-messages:
+			if !ok {
+				return false
+			}
+			var envelope dto.EventEnvelope
+			if err := json.Unmarshal([]byte(message.Payload), &envelope); err != nil {
+				logger.Warn("Failed to unmarshal live event", "error", err)
+				return true
+			}
+			if filter.matches(&envelope) {
+				c.SSEvent(string(envelope.Type), envelope)
+			}
+			return true
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	gorilla "github.com/gorilla/sessions"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const loginRequestKey = "loginRequest"
+
+// LoginRequest is the in-flight login ceremony, kept in the session between the begin and finish calls
+type LoginRequest struct {
+	RequestID   string
+	UserID      int64
+	SessionData webauthn.SessionData
+}
+
+// onlyEnrolledCredentials leaves the user with just the passkeys enrolled for the admin service.
+// Anyone can register a passkey for any user reference on the auth server, so those must not sign anyone in here.
+func onlyEnrolledCredentials(adminService *admin_service.AdminService, user *credential_service.UserModel) error {
+	ids, err := adminService.ListCredentialIDs(user.RefID)
+	if err != nil {
+		return err
+	}
+
+	enrolled := []credential_service.CredentialModel{}
+	for _, credential := range user.Credentials.Value {
+		for _, id := range ids {
+			if bytes.Equal(id, credential.ID) {
+				enrolled = append(enrolled, credential)
+				break
+			}
+		}
+	}
+	user.Credentials.Value = enrolled
+	return nil
+}
+
+// regenerateSession throws away the session the login ceremony ran in and starts one with a new identifier,
+// so an identifier someone planted in the browser before the login is worthless afterwards
+func regenerateSession(c *gin.Context) (sessions.Session, error) {
+	session := getSession(c)
+	store, ok := session.(interface{ Session() *gorilla.Session })
+	if !ok {
+		return nil, fmt.Errorf("session store can not regenerate sessions")
+	}
+
+	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
+	if err := session.Save(); err != nil {
+		return nil, err
+	}
+	// The store only hands out a new identifier to sessions that have none
+	store.Session().ID = ""
+	return getSession(c), nil
+}
+
+// POST /login/ end point to start the passkey ceremony for an administrator
+func BeginLogin(c *gin.Context) {
+	var requestPayload dto.StartAuthenticationRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	adminService, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+	allowed, err := adminService.IsAllowed(requestPayload.User.UserID)
+	if err != nil {
+		abortWithError(c, internalError("Failed to check admin allowlist", err))
+		return
+	}
+	if !allowed {
+		recordAuditEvent(c, audit_service.Event{
+			Type:    audit_service.EventAdminLoginFailed,
+			UserRef: requestPayload.User.UserID,
+			Reason:  "not an administrator",
+		})
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Not an administrator", fmt.Errorf("user(%v) is not on the admin allowlist", requestPayload.User.UserID)))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserWithCredentialsByRef(requestPayload.User.UserID, false)
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to get user"))
+		return
+	}
+	if err := onlyEnrolledCredentials(adminService, user); err != nil {
+		abortWithError(c, internalError("Failed to get admin credentials", err))
+		return
+	}
+	if !user.Credentials.Loaded || len(user.Credentials.Value) == 0 {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNoCredentials, "No passkeys enrolled for the admin service", fmt.Errorf("no enrolled credentials for user(%v)", user.ID)))
+		return
+	}
+
+	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
+	options, sessionData, err := webAuthn.BeginLogin(user, webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		abortWithError(c, internalError("Failed to create authentication options", err))
+		return
+	}
+
+	requestId := uuid.New().String()
+
+	session := getSession(c)
+	session.Set(loginRequestKey, LoginRequest{RequestID: requestId, UserID: user.ID, SessionData: *sessionData})
+	if err := session.Save(); err != nil {
+		abortWithError(c, internalError("Failed to save request data", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StartAuthenticationResponse{
+		RequestID: requestId,
+		Options:   *options,
+	})
+}
+
+// PUT /login/:requestId end point to finish the passkey ceremony and sign the administrator in
+func FinishLogin(c *gin.Context) {
+	requestId := c.Param("requestId")
+
+	session := getSession(c)
+	loginRequest, ok := session.Get(loginRequestKey).(LoginRequest)
+	if !ok || loginRequest.RequestID != requestId {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorRequestNotFound, "Request not found", fmt.Errorf("request(%v) not in session", requestId)))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserWithCredentialsByID(loginRequest.UserID, false)
+	if err != nil {
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+	adminService, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+	// A passkey that is not enrolled fails the validation as if it was not the user's at all
+	if err := onlyEnrolledCredentials(adminService, user); err != nil {
+		abortWithError(c, internalError("Failed to get admin credentials", err))
+		return
+	}
+
+	var requestPayload dto.FinishAuthenticationRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+
+	parsedAssertion, err := requestPayload.Assertion.Parse()
+	if err != nil {
+		abortWithError(c, utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Failed to parse assertion", err))
+		return
+	}
+
+	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
+	credential, err := webAuthn.ValidateLogin(user, loginRequest.SessionData, parsedAssertion)
+	if err != nil {
+		appErr := utils.NewWebauthnError(http.StatusUnauthorized, dto.ErrorAuthenticationFailed, "Failed to validate login", err)
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventAdminLoginFailed,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: parsedAssertion.RawID,
+			RequestID:    requestId,
+			Reason:       string(appErr.Code) + ": " + err.Error(),
+		})
+		abortWithError(c, appErr)
+		return
+	}
+
+	if _, err := service.IncrementCredentialUseCounter(credential.ID); err != nil {
+		abortWithError(c, internalError("Failed to update credential", err))
+		return
+	}
+
+	now := time.Now()
+	admin := AdminSession{
+		UserID:          user.ID,
+		UserRef:         user.RefID,
+		UserName:        user.Name,
+		AuthenticatedAt: now,
+		LastSeenAt:      now,
+	}
+	session, err = regenerateSession(c)
+	if err != nil {
+		abortWithError(c, internalError("Failed to regenerate session", err))
+		return
+	}
+	session.Set(adminSessionKey, admin)
+	if err := session.Save(); err != nil {
+		abortWithError(c, internalError("Failed to save session", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventAdminLogin,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credential.ID,
+		RequestID:    requestId,
+	})
+
+	c.JSON(http.StatusOK, admin)
+}
+
+// POST /logout end point to sign the administrator out
+func Logout(c *gin.Context) {
+	session := getSession(c)
+	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
+	if err := session.Save(); err != nil {
+		abortWithError(c, internalError("Failed to clear session", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
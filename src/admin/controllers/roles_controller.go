@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func getAdminService(c *gin.Context) (*admin_service.AdminService, bool) {
+	service, err := admin_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
+	}
+	return service, true
+}
+
+// Nobody changes their own roles, so an owner can not lock themselves out or quietly escalate
+func forbidSelfRoleChange(c *gin.Context, userId string) bool {
+	if admin := getAdminSession(c); admin != nil && admin.UserRef == userId {
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "You can not change your own roles", fmt.Errorf("admin(%v) tried to change their own roles", userId)))
+		return true
+	}
+	return false
+}
+
+func previousRole(service *admin_service.AdminService, userId string, tenant string) admin_service.Role {
+	roles, err := service.GetRoles(userId)
+	if err != nil {
+		logger.Warn("Failed to get previous role", "error", err, "userId", userId)
+		return ""
+	}
+	return roles[tenant]
+}
+
+// GET /admins/:userId/roles end point to list the roles of an administrator
+func ListAdminRoles(c *gin.Context) {
+	service, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+
+	roles, err := service.ListRoles(c.Param("userId"))
+	if err != nil {
+		abortWithError(c, internalError("Failed to list roles", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// PUT /admins/:userId/roles/:tenant end point to grant an administrator a role for a tenant, use * for every tenant
+func SetAdminRole(c *gin.Context) {
+	userId := c.Param("userId")
+	tenant := c.Param("tenant")
+
+	if forbidSelfRoleChange(c, userId) {
+		return
+	}
+
+	var requestPayload dto.SetAdminRoleRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+
+	previous := previousRole(service, userId, tenant)
+	assignment, err := service.SetRole(userId, tenant, admin_service.Role(requestPayload.Role), getAdminSession(c).UserRef)
+	if err == pgx.ErrNoRows {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User is not an administrator", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to set role", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminRoleChanged,
+		UserRef: userId,
+		Tenant:  tenant,
+		Details: map[string]any{"role": assignment.Role, "previousRole": previous},
+	})
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+// DELETE /admins/:userId/roles/:tenant end point to take away the role an administrator has for a tenant
+func RemoveAdminRole(c *gin.Context) {
+	userId := c.Param("userId")
+	tenant := c.Param("tenant")
+
+	if forbidSelfRoleChange(c, userId) {
+		return
+	}
+
+	service, ok := getAdminService(c)
+	if !ok {
+		return
+	}
+
+	previous := previousRole(service, userId, tenant)
+	if err := service.RemoveRole(userId, tenant); err == pgx.ErrNoRows {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNotFound, "Role not found", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to remove role", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminRoleChanged,
+		UserRef: userId,
+		Tenant:  tenant,
+		Details: map[string]any{"role": nil, "previousRole": previous},
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	api_key_service "blacksmithlabs.dev/webauthn-k8s/shared/services/api_key"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const apiKeyKey = "apiKey"
+
+const scimUsersPath = "/scim/v2/Users/"
+
+const defaultScimCount = 100
+
+// Only equality filters on the identifying attributes are supported, e.g. userName eq "jdoe"
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*(userName|externalId)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// abortWithScimError logs the full error and responds in the SCIM error format, which provisioning clients expect
+// instead of the problem responses used by the rest of the admin API
+func abortWithScimError(c *gin.Context, err *utils.AppError, scimType string) {
+	logArgs := []any{"code", err.Code, "status", err.Status, "path", c.FullPath(), "error", err.Err}
+	if err.Status >= http.StatusInternalServerError {
+		logger.Error(err.Message, logArgs...)
+	} else {
+		logger.Warn(err.Message, logArgs...)
+	}
+
+	c.Header("Content-Type", dto.ScimContentType)
+	c.AbortWithStatusJSON(err.Status, dto.NewScimError(err.Status, scimType, err.Message))
+}
+
+func scimJSON(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", dto.ScimContentType)
+	c.JSON(status, body)
+}
+
+// RequireScimToken only lets requests through with a bearer token issued for the scim scope
+func RequireScimToken(c *gin.Context) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		abortWithScimError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorUnauthorized, "Bearer token required", fmt.Errorf("no bearer token")), "")
+		return
+	}
+
+	service, err := api_key_service.New(c)
+	if err != nil {
+		abortWithScimError(c, internalError("Database error", err), "")
+		return
+	}
+	key, err := service.Verify(token, api_key_service.ScopeScim)
+	if err == pgx.ErrNoRows {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		abortWithScimError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorUnauthorized, "Invalid bearer token", err), "")
+		return
+	} else if err != nil {
+		abortWithScimError(c, internalError("Failed to verify bearer token", err), "")
+		return
+	}
+
+	c.Set(apiKeyKey, key)
+	c.Next()
+}
+
+func scimUser(user *credential_service.UserModel, active bool) dto.ScimUser {
+	return dto.ScimUser{
+		Schemas:     []string{dto.ScimUserSchema},
+		ID:          user.RefID,
+		ExternalID:  user.RefID,
+		UserName:    user.Name,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &dto.ScimMeta{
+			ResourceType: "User",
+			Location:     scimUsersPath + user.RefID,
+		},
+	}
+}
+
+func loadScimUser(c *gin.Context, service *credential_service.CredentialService, user *credential_service.UserModel) (dto.ScimUser, bool) {
+	active, err := service.IsUserActive(user)
+	if err != nil {
+		abortWithScimError(c, internalError("Failed to get provisioning status", err), "")
+		return dto.ScimUser{}, false
+	}
+	return scimUser(user, active), true
+}
+
+func getScimCredentialService(c *gin.Context) (*credential_service.CredentialService, bool) {
+	service, err := credential_service.New(c)
+	if err != nil {
+		abortWithScimError(c, internalError("Database error", err), "")
+		return nil, false
+	}
+	return withEvents(c, service), true
+}
+
+func getScimUserByRef(c *gin.Context, service *credential_service.CredentialService) (*credential_service.UserModel, bool) {
+	user, err := service.GetUserByRef(c.Param("userId"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithScimError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", err), "")
+		return nil, false
+	} else if err != nil {
+		abortWithScimError(c, internalError("Failed to get user", err), "")
+		return nil, false
+	}
+	return user, true
+}
+
+// setScimUserActive applies a provisioning change, deprovisioning revokes every credential of the user
+func setScimUserActive(c *gin.Context, service *credential_service.CredentialService, user *credential_service.UserModel, active bool) bool {
+	revoked, err := service.SetUserActive(user, active)
+	if err != nil {
+		abortWithScimError(c, internalError("Failed to update provisioning status", err), "")
+		return false
+	}
+
+	action := "scim.user_activated"
+	if !active {
+		action = "scim.user_deprovisioned"
+	}
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserID:  user.ID,
+		UserRef: user.RefID,
+		Details: map[string]any{
+			"action":             action,
+			"revokedCredentials": utils.Map(revoked, func(id []byte) protocol.URLEncodedBase64 { return id }),
+		},
+	})
+	return true
+}
+
+// POST /scim/v2/Users end point to provision a user, externalId becomes the user reference and defaults to userName
+func CreateScimUser(c *gin.Context) {
+	var requestPayload dto.ScimUser
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithScimError(c, invalidRequestFormat(err), "invalidSyntax")
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithScimError(c, invalidRequestPayload(err), "invalidValue")
+		return
+	}
+
+	ref := requestPayload.ExternalID
+	if ref == "" {
+		ref = requestPayload.UserName
+	}
+
+	service, ok := getScimCredentialService(c)
+	if !ok {
+		return
+	}
+
+	if _, err := service.GetUserByRef(ref); err == nil {
+		abortWithScimError(c, utils.NewError(http.StatusConflict, dto.ErrorInvalidRequest, "User already exists", fmt.Errorf("user(%v) already exists", ref)), "uniqueness")
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		abortWithScimError(c, internalError("Failed to get user", err), "")
+		return
+	}
+
+	user, err := service.UpsertUser(dto.RegistrationUserInfo{
+		UserID:      ref,
+		UserName:    requestPayload.UserName,
+		DisplayName: requestPayload.DisplayName,
+	})
+	if err != nil {
+		abortWithScimError(c, internalError("Failed to create user", err), "")
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserID:  user.ID,
+		UserRef: user.RefID,
+		Details: map[string]any{"action": "scim.user_created"},
+	})
+
+	active := requestPayload.Active == nil || *requestPayload.Active
+	if !setScimUserActive(c, service, user, active) {
+		return
+	}
+
+	c.Header("Location", scimUsersPath+user.RefID)
+	scimJSON(c, http.StatusCreated, scimUser(user, active))
+}
+
+// GET /scim/v2/Users/:userId end point to get a provisioned user
+func GetScimUser(c *gin.Context) {
+	service, ok := getScimCredentialService(c)
+	if !ok {
+		return
+	}
+	user, ok := getScimUserByRef(c, service)
+	if !ok {
+		return
+	}
+
+	resource, ok := loadScimUser(c, service, user)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, resource)
+}
+
+// GET /scim/v2/Users end point to find users, supports `userName eq` and `externalId eq` filters
+func ListScimUsers(c *gin.Context) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultScimCount)))
+	if err != nil || count < 0 {
+		count = defaultScimCount
+	}
+
+	service, ok := getScimCredentialService(c)
+	if !ok {
+		return
+	}
+
+	var users []*credential_service.UserModel
+	if filter := c.Query("filter"); filter != "" {
+		match := scimFilterPattern.FindStringSubmatch(filter)
+		if match == nil {
+			abortWithScimError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Only userName eq and externalId eq filters are supported", fmt.Errorf("unsupported filter %q", filter)), "invalidFilter")
+			return
+		}
+		value := strings.ReplaceAll(match[2], `\"`, `"`)
+
+		if strings.EqualFold(match[1], "externalId") {
+			user, err := service.GetUserByRef(value)
+			if err == nil {
+				users = append(users, user)
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				abortWithScimError(c, internalError("Failed to get user", err), "")
+				return
+			}
+		} else if users, err = service.ListUsersByName(value); err != nil {
+			abortWithScimError(c, internalError("Failed to find users", err), "")
+			return
+		}
+	} else if users, err = service.SearchUsers("", 0, startIndex-1+count); err != nil {
+		abortWithScimError(c, internalError("Failed to list users", err), "")
+		return
+	}
+
+	total := len(users)
+	page := users[min(startIndex-1, total):min(startIndex-1+count, total)]
+
+	resources := make([]dto.ScimUser, 0, len(page))
+	for _, user := range page {
+		resource, ok := loadScimUser(c, service, user)
+		if !ok {
+			return
+		}
+		resources = append(resources, resource)
+	}
+
+	scimJSON(c, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{dto.ScimListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// applyScimPatchValue copies the supported attributes of a patch value onto the user
+func applyScimPatchValue(path string, value json.RawMessage, patched *dto.ScimUser) error {
+	switch strings.ToLower(path) {
+	case "":
+		var attributes dto.ScimUser
+		if err := json.Unmarshal(value, &attributes); err != nil {
+			return err
+		}
+		if attributes.ExternalID != "" && attributes.ExternalID != patched.ExternalID {
+			return fmt.Errorf("externalId can not be changed")
+		}
+		if attributes.UserName != "" {
+			patched.UserName = attributes.UserName
+		}
+		if attributes.DisplayName != "" {
+			patched.DisplayName = attributes.DisplayName
+		}
+		if attributes.Active != nil {
+			patched.Active = attributes.Active
+		}
+		return nil
+	case "username":
+		return json.Unmarshal(value, &patched.UserName)
+	case "displayname":
+		return json.Unmarshal(value, &patched.DisplayName)
+	case "active":
+		// Some clients send booleans as strings
+		var active bool
+		if err := json.Unmarshal(value, &active); err != nil {
+			var text string
+			if json.Unmarshal(value, &text) != nil {
+				return err
+			}
+			if active, err = strconv.ParseBool(text); err != nil {
+				return err
+			}
+		}
+		patched.Active = &active
+		return nil
+	default:
+		return fmt.Errorf("unsupported path %q", path)
+	}
+}
+
+// PATCH /scim/v2/Users/:userId end point to update or (de)activate a provisioned user
+func PatchScimUser(c *gin.Context) {
+	var requestPayload dto.ScimPatchRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithScimError(c, invalidRequestFormat(err), "invalidSyntax")
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithScimError(c, invalidRequestPayload(err), "invalidValue")
+		return
+	}
+
+	service, ok := getScimCredentialService(c)
+	if !ok {
+		return
+	}
+	user, ok := getScimUserByRef(c, service)
+	if !ok {
+		return
+	}
+	current, ok := loadScimUser(c, service, user)
+	if !ok {
+		return
+	}
+
+	patched := current
+	for _, operation := range requestPayload.Operations {
+		if err := applyScimPatchValue(operation.Path, operation.Value, &patched); err != nil {
+			abortWithScimError(c, invalidRequestPayload(err), "invalidValue")
+			return
+		}
+	}
+	if err := patched.Validate(); err != nil {
+		abortWithScimError(c, invalidRequestPayload(err), "invalidValue")
+		return
+	}
+
+	if patched.UserName != current.UserName || patched.DisplayName != current.DisplayName {
+		updated, err := service.UpdateUser(user.RefID, patched.UserName, patched.DisplayName)
+		if err != nil {
+			abortWithScimError(c, internalError("Failed to update user", err), "")
+			return
+		}
+		user = updated
+
+		recordAuditEvent(c, audit_service.Event{
+			Type:    audit_service.EventAdminAction,
+			UserID:  user.ID,
+			UserRef: user.RefID,
+			Details: map[string]any{"action": "scim.user_updated"},
+		})
+	}
+
+	if *patched.Active != *current.Active {
+		if !setScimUserActive(c, service, user, *patched.Active) {
+			return
+		}
+	}
+
+	scimJSON(c, http.StatusOK, scimUser(user, *patched.Active))
+}
+
+// DELETE /scim/v2/Users/:userId end point to deprovision a user. Their credentials are revoked rather than deleted
+// so the records stay available for audit, the user is reported as inactive afterwards.
+func DeleteScimUser(c *gin.Context) {
+	service, ok := getScimCredentialService(c)
+	if !ok {
+		return
+	}
+	user, ok := getScimUserByRef(c, service)
+	if !ok {
+		return
+	}
+
+	if !setScimUserActive(c, service, user, false) {
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
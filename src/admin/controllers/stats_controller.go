@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/k8s-webauthn/admin/cache"
+	"blacksmithlabs.dev/k8s-webauthn/admin/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	stats_service "blacksmithlabs.dev/webauthn-k8s/shared/services/stats"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const statsDayFormat = "2006-01-02"
+const defaultStatsDays = 30
+const maxStatsDays = 366
+
+var statsCacheTTL = config.GetStatsCacheTTL()
+
+// parseStatsWindow reads the from and to days, both inclusive, and returns the window as [from, to)
+func parseStatsWindow(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("to"); value != "" {
+		day, err := time.Parse(statsDayFormat, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be a YYYY-MM-DD date")
+		}
+		to = day
+	}
+	to = to.AddDate(0, 0, 1)
+
+	from := to.AddDate(0, 0, -defaultStatsDays)
+	if value := c.Query("from"); value != "" {
+		day, err := time.Parse(statsDayFormat, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+		from = day
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) > maxStatsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("the window can be at most %v days", maxStatsDays)
+	}
+	return from, to, nil
+}
+
+// The aggregates scan the whole credential table, so reports are shared between admins through the cache
+func getStatsReport(c *gin.Context, from time.Time, to time.Time) (*dto.StatsReport, error) {
+	key := fmt.Sprintf("admin:stats:%v:%v", from.Format(statsDayFormat), to.Format(statsDayFormat))
+	client := cache.ConnectCache()
+
+	if cached, err := client.Get(c, key).Bytes(); err == nil {
+		var report dto.StatsReport
+		if err := json.Unmarshal(cached, &report); err == nil {
+			return &report, nil
+		}
+		logger.Warn("Failed to unmarshal cached stats", "error", err, "key", key)
+	} else if err != cache.Nil {
+		logger.Warn("Failed to read stats from cache", "error", err, "key", key)
+	}
+
+	service, err := stats_service.New(c)
+	if err != nil {
+		return nil, err
+	}
+	report, err := service.Report(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if encoded, err := json.Marshal(report); err != nil {
+		logger.Warn("Failed to marshal stats", "error", err)
+	} else if err := client.Set(c, key, encoded, statsCacheTTL).Err(); err != nil {
+		logger.Warn("Failed to cache stats", "error", err, "key", key)
+	}
+
+	return report, nil
+}
+
+// GET /stats end point to report passkey adoption between the from and to days, as JSON or as CSV with format=csv
+func GetStats(c *gin.Context) {
+	from, to, err := parseStatsWindow(c)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, err.Error(), err))
+		return
+	}
+
+	report, err := getStatsReport(c, from, to)
+	if err != nil {
+		abortWithError(c, internalError("Failed to compute stats", err))
+		return
+	}
+
+	if c.Query("format") == "csv" || strings.Contains(c.GetHeader("Accept"), "text/csv") {
+		filename := fmt.Sprintf("webauthn-stats-%v-%v.csv", from.Format(statsDayFormat), to.AddDate(0, 0, -1).Format(statsDayFormat))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+		if err := stats_service.WriteCSV(c.Writer, report); err != nil {
+			logger.Error("Failed to write stats csv", "error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+// Larger restores should stream JSON lines through the CLI instead of going through the API
+const maxImportBytes = 64 * 1024 * 1024
+
+func exportContentType(format dto.ExportFormat) string {
+	if format == dto.ExportFormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// GET /users/export end point to download every user and credential, as a JSON document or as JSON lines with format=jsonl
+func ExportUsers(c *gin.Context) {
+	format, err := dto.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, err.Error(), err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	filename := "webauthn-users-" + time.Now().UTC().Format("20060102-150405") + "." + string(format)
+	c.Header("Content-Type", exportContentType(format))
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Status(http.StatusOK)
+
+	summary, err := service.Export(c.Writer, format)
+	if err != nil {
+		// The response has already started, the truncated file will not read back as a valid export
+		logger.Error("Failed to export users", "error", err)
+		recordAuditEvent(c, audit_service.Event{
+			Type:    audit_service.EventAdminAction,
+			Details: map[string]any{"action": "users.exported", "format": format, "error": err.Error()},
+		})
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "users.exported", "format": format, "users": summary.Users, "credentials": summary.Credentials},
+	})
+}
+
+// abortWithImportError reports why nothing was imported
+func abortWithImportError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		abortWithError(c, utils.NewError(http.StatusRequestEntityTooLarge, dto.ErrorInvalidRequest, "The import is too large, use the CLI for large imports", err))
+	case errors.Is(err, credential_service.ErrInvalidImport):
+		abortWithError(c, invalidRequestPayload(err))
+	case errors.Is(err, credential_service.ErrImportConflict):
+		abortWithError(c, utils.NewError(http.StatusConflict, dto.ErrorConflict, "Nothing was imported: "+err.Error(), err))
+	default:
+		abortWithError(c, internalError("Failed to import users", err))
+	}
+}
+
+// POST /users/import end point to restore users and credentials from an export.
+// Existing records are skipped, overwritten or fail the whole import depending on the mode.
+// The users are bound to the tenant query parameter when there is one, to the tenant they were exported from otherwise.
+func ImportUsers(c *gin.Context) {
+	mode, err := dto.ParseImportConflictMode(c.Query("mode"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, err.Error(), err))
+		return
+	}
+	formatValue := c.Query("format")
+	if contentType := c.ContentType(); formatValue == "" && (strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl")) {
+		formatValue = string(dto.ExportFormatJSONL)
+	}
+	format, err := dto.ParseExportFormat(formatValue)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, err.Error(), err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	var tenant *string
+	if value, ok := c.GetQuery("tenant"); ok {
+		tenant = &value
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	response, err := service.Import(body, format, mode, tenant)
+	if err != nil {
+		abortWithImportError(c, err)
+		return
+	}
+
+	details := map[string]any{
+		"action":      "users.imported",
+		"mode":        mode,
+		"users":       response.Users,
+		"credentials": response.Credentials,
+	}
+	if tenant != nil {
+		details["tenant"] = *tenant
+	}
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: details,
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /users/import-u2f end point to import legacy U2F registrations as JSON lines, tied to the AppID they were
+// registered under. Existing credentials are skipped, overwritten or fail the whole import depending on the mode.
+func ImportU2FUsers(c *gin.Context) {
+	appID := c.Query("appId")
+	if appID == "" {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "appId is required", fmt.Errorf("missing appId")))
+		return
+	}
+	mode, err := dto.ParseImportConflictMode(c.Query("mode"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, err.Error(), err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	response, err := service.ImportU2F(body, appID, mode)
+	if err != nil {
+		abortWithImportError(c, err)
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type: audit_service.EventAdminAction,
+		Details: map[string]any{
+			"action":      "users.u2f_imported",
+			"appId":       appID,
+			"mode":        mode,
+			"users":       response.Users,
+			"credentials": response.Credentials,
+		},
+	})
+
+	c.JSON(http.StatusOK, response)
+}
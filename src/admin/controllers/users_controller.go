@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func userResponse(user *credential_service.UserModel) dto.UserResponse {
+	return dto.UserResponse{
+		ID:          user.ID,
+		UserID:      user.RefID,
+		UserName:    user.Name,
+		DisplayName: user.DisplayName,
+	}
+}
+
+func credentialDetailResponse(credential credential_service.CredentialModel) dto.CredentialDetailResponse {
+	return dto.CredentialDetailResponse{
+		ID:              credential.ID,
+		Status:          string(credential.Meta.Status),
+		Nickname:        credential.Meta.Nickname,
+		DisabledReason:  credential.Meta.DisabledReason,
+		Serial:          credential.Meta.Serial,
+		AttestationType: credential.AttestationType,
+		Transports:      credential.Transport,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		CloneWarning:    credential.Authenticator.CloneWarning,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Authenticator:   credential.AuthenticatorInfo(),
+		PRF:             credential.PRFStatus(),
+		Discoverable:    credential.Meta.Discoverable,
+		LargeBlob:       credential.LargeBlobStatus(),
+	}
+}
+
+func getCredentialService(c *gin.Context) (*credential_service.CredentialService, bool) {
+	service, err := credential_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
+	}
+	return withEvents(c, service), true
+}
+
+func userNotFoundOr(err error, message string) *utils.AppError {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", err)
+	}
+	return internalError(message, err)
+}
+
+// GET /users end point to search users by reference, name or display name, a page at a time
+func SearchUsers(c *gin.Context) {
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid after cursor", err))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid limit", err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	users, err := service.SearchUsers(c.Query("q"), afterID, limit)
+	if err != nil {
+		abortWithError(c, internalError("Failed to search users", err))
+		return
+	}
+
+	response := dto.UserListResponse{Users: utils.Map(users, userResponse)}
+	if len(users) > 0 {
+		response.Next = users[len(users)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /users/:userId end point to view a user along with all of their credentials, whatever their status
+func GetUser(c *gin.Context) {
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	user, err := service.GetUserWithCredentialsByRef(c.Param("userId"), true)
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to get user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.UserDetailResponse{
+		UserResponse: userResponse(user),
+		Credentials:  utils.Map(user.Credentials.Value, credentialDetailResponse),
+	})
+}
+
+// PATCH /users/:userId end point to rename a user
+func UpdateUser(c *gin.Context) {
+	var requestPayload dto.UpdateUserRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	user, err := service.UpdateUser(c.Param("userId"), requestPayload.UserName, requestPayload.DisplayName)
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to update user"))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserID:  user.ID,
+		UserRef: user.RefID,
+		Details: map[string]any{"action": "user.renamed"},
+	})
+
+	c.JSON(http.StatusOK, userResponse(user))
+}
+
+// DELETE /users/:userId end point to delete a user and all of their credentials
+func DeleteUser(c *gin.Context) {
+	userId := c.Param("userId")
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	if err := service.DeleteUser(userId); err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to delete user"))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserRef: userId,
+		Details: map[string]any{"action": "user.deleted"},
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// RequireCredentialStatusPermission lets support staff only disable credentials, any other change of status is for
+// security admins
+func RequireCredentialStatusPermission(c *gin.Context) {
+	var requestPayload dto.UpdateCredentialStatusRequest
+	if err := c.ShouldBindBodyWith(&requestPayload, binding.JSON); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	permission := admin_service.PermissionManageCredentials
+	if requestPayload.Status == string(credential_service.CredentialStatusDisabled) {
+		permission = admin_service.PermissionDisableCredentials
+	}
+	if !requirePermission(c, admin_service.AllTenants, permission) {
+		return
+	}
+	c.Next()
+}
+
+// PUT /users/:userId/credentials/:credentialId/status end point to activate, disable or revoke a credential
+func UpdateCredentialStatus(c *gin.Context) {
+	credentialID, err := base64.RawURLEncoding.DecodeString(c.Param("credentialId"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid credentialId, expected base64url", err))
+		return
+	}
+
+	// Read again from the body RequireCredentialStatusPermission checked
+	var requestPayload dto.UpdateCredentialStatusRequest
+	if err := c.ShouldBindBodyWith(&requestPayload, binding.JSON); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	user, err := service.GetUserByRef(c.Param("userId"))
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to get user"))
+		return
+	}
+
+	credential, err := service.UpdateCredentialStatus(user, credentialID, credential_service.CredentialStatus(requestPayload.Status))
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorCredentialNotFound, "Credential not found", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to update credential status", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventCredentialStatusChanged,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credentialID,
+		Reason:       requestPayload.Reason,
+		Details:      map[string]any{"status": requestPayload.Status},
+	})
+
+	c.JSON(http.StatusOK, credentialDetailResponse(*credential))
+}
+
+// GET /users/:userId/recovery-methods end point to view the account recovery methods of a user
+func GetRecoveryMethods(c *gin.Context) {
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	user, err := service.GetUserByRef(c.Param("userId"))
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to get user"))
+		return
+	}
+
+	methods, err := service.RecoveryMethods(user)
+	if err != nil {
+		abortWithError(c, internalError("Failed to get recovery methods", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryMethodsResponse{Methods: methods})
+}
+
+// PUT /users/:userId/recovery-methods end point to replace the account recovery methods the relying party has set up
+// for a user, which let the user remove their last passkey
+func SetRecoveryMethods(c *gin.Context) {
+	var requestPayload dto.RecoveryMethodsRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getCredentialService(c)
+	if !ok {
+		return
+	}
+
+	user, err := service.GetUserByRef(c.Param("userId"))
+	if err != nil {
+		abortWithError(c, userNotFoundOr(err, "Failed to get user"))
+		return
+	}
+
+	methods, err := service.SetRecoveryMethods(user, requestPayload.Methods)
+	if err != nil {
+		abortWithError(c, internalError("Failed to set recovery methods", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		UserID:  user.ID,
+		UserRef: user.RefID,
+		Details: map[string]any{"action": "recovery_methods.updated", "methods": methods},
+	})
+
+	c.JSON(http.StatusOK, dto.RecoveryMethodsResponse{Methods: methods})
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	webhook_service "blacksmithlabs.dev/webauthn-k8s/shared/services/webhook"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func getWebhookService(c *gin.Context) (*webhook_service.WebhookService, bool) {
+	service, err := webhook_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
+	}
+	return service, true
+}
+
+func getIdParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid "+name, err))
+		return 0, false
+	}
+	return id, true
+}
+
+// POST /webhooks/ end point to subscribe a URL to credential and authentication events
+func CreateWebhookSubscription(c *gin.Context) {
+	var requestPayload dto.CreateWebhookSubscriptionRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, ok := getWebhookService(c)
+	if !ok {
+		return
+	}
+
+	if err := webhook_service.CheckURL(c, requestPayload.URL); errors.Is(err, webhook_service.ErrBlockedURL) {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to check webhook url", err))
+		return
+	}
+
+	subscription, err := service.CreateSubscription(requestPayload)
+	if err != nil {
+		abortWithError(c, internalError("Failed to create webhook subscription", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "webhook.created", "subscriptionId": subscription.ID, "url": subscription.URL},
+	})
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// GET /webhooks/ end point to list the webhook subscriptions
+func ListWebhookSubscriptions(c *gin.Context) {
+	service, ok := getWebhookService(c)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := service.ListSubscriptions()
+	if err != nil {
+		abortWithError(c, internalError("Failed to list webhook subscriptions", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subscriptions})
+}
+
+// DELETE /webhooks/:subscriptionId end point to remove a webhook subscription
+func DeleteWebhookSubscription(c *gin.Context) {
+	id, ok := getIdParam(c, "subscriptionId")
+	if !ok {
+		return
+	}
+	service, ok := getWebhookService(c)
+	if !ok {
+		return
+	}
+
+	found, err := service.DeleteSubscription(id)
+	if err != nil {
+		abortWithError(c, internalError("Failed to delete webhook subscription", err))
+		return
+	} else if !found {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNotFound, "Subscription not found", fmt.Errorf("subscription(%v) not found", id)))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "webhook.deleted", "subscriptionId": id},
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /webhooks/dead-letters end point to list deliveries that ran out of retries
+func ListWebhookDeadLetters(c *gin.Context) {
+	service, ok := getWebhookService(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := service.ListDeadLetters()
+	if err != nil {
+		abortWithError(c, internalError("Failed to list webhook dead letters", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// POST /webhooks/deliveries/:deliveryId/replay end point to retry a dead delivery
+func ReplayWebhookDelivery(c *gin.Context) {
+	id, ok := getIdParam(c, "deliveryId")
+	if !ok {
+		return
+	}
+	service, ok := getWebhookService(c)
+	if !ok {
+		return
+	}
+
+	found, err := service.Replay(id)
+	if err != nil {
+		abortWithError(c, internalError("Failed to replay webhook delivery", err))
+		return
+	} else if !found {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNotFound, "Dead delivery not found", fmt.Errorf("dead delivery(%v) not found", id)))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "webhook.replayed", "deliveryId": id},
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued for replay", "deliveryId": id})
+}
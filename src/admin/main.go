@@ -1,22 +1,67 @@
 package main
 
 import (
+	"encoding/gob"
 	"fmt"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 
+	"blacksmithlabs.dev/k8s-webauthn/admin/cache"
 	"blacksmithlabs.dev/k8s-webauthn/admin/config"
+	"blacksmithlabs.dev/k8s-webauthn/admin/controllers"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
 )
 
 var (
+	webAuthn       *webauthn.WebAuthn
+	err            error
 	sessionTimeout = config.GetSessionTimeout()
 )
 
 func main() {
+	// Initialize code dependencies
+	gob.Register(controllers.AdminSession{})
+	gob.Register(controllers.LoginRequest{})
+	gob.Register(controllers.EnrollRequest{})
+
+	// Initialize WebAuthn, admins sign in with the same ceremony as everyone else
+	timeoutConfig := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    sessionTimeout,
+		TimeoutUVD: sessionTimeout,
+	}
+	wconfig := &webauthn.Config{
+		RPDisplayName: config.GetRPDisplayName(),
+		RPID:          config.GetRPID(),
+		RPOrigins:     config.GetRPOrigins(),
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeoutConfig,
+			Registration: timeoutConfig,
+		},
+	}
+
+	if webAuthn, err = webauthn.New(wconfig); err != nil {
+		panic(fmt.Errorf("failed to create WebAuthn handler: %w", err))
+	}
+
+	database.Configure(config.GetPostgresUrl())
+
 	// Initialize Gin
 	engine := gin.Default()
+	// Bind the WebAuthn instance and the event publisher to the context
+	var publisher event_publisher.EventPublisher = event_publisher.MultiPublisher{
+		event_publisher.NewRedisStreamPublisher(cache.ConnectCache(), config.GetEventStream(), config.GetEventStreamMaxLen()),
+		event_publisher.NewRedisPubSubPublisher(cache.ConnectCache(), config.GetEventChannel()),
+	}
+	engine.Use(func(ctx *gin.Context) {
+		ctx.Set("webauthn", webAuthn)
+		ctx.Set("events", publisher)
+	})
 
 	// Enable CORS
 	// if origins := config.GetRPOrigins(); len(origins) > 0 {
@@ -43,7 +88,57 @@ func main() {
 	engine.GET("/_health", (func(ctx *gin.Context) {
 		ctx.JSON(200, gin.H{"status": "ok"})
 	}))
+	// The routes signed in with the session cookie only accept JSON bodies, so other sites can not post to them
+	browser := engine.Group("/", controllers.RequireJSON)
+	browser.POST("/login/", controllers.BeginLogin)
+	browser.PUT("/login/:requestId", controllers.FinishLogin)
+	browser.POST("/logout", controllers.Logout)
+
+	admin := browser.Group("/", controllers.RequireAdmin)
+	admin.GET("/session", controllers.GetAdminSession)
+	admin.GET("/users", controllers.RequireGlobalPermission(admin_service.PermissionViewUsers), controllers.SearchUsers)
+	admin.GET("/users/export", controllers.RequireGlobalPermission(admin_service.PermissionExportUsers), controllers.ExportUsers)
+	admin.GET("/users/:userId", controllers.RequireGlobalPermission(admin_service.PermissionViewUsers), controllers.GetUser)
+	admin.PATCH("/users/:userId", controllers.RequireGlobalPermission(admin_service.PermissionEditUsers), controllers.UpdateUser)
+	admin.GET("/users/:userId/recovery-methods", controllers.RequireGlobalPermission(admin_service.PermissionViewUsers), controllers.GetRecoveryMethods)
+	admin.PUT("/users/:userId/recovery-methods", controllers.RequireGlobalPermission(admin_service.PermissionEditUsers), controllers.SetRecoveryMethods)
+	admin.GET("/authenticators/:aaguid/credentials", controllers.RequirePermission(admin_service.PermissionViewUsers, controllers.TenantQuery("tenant")), controllers.PreviewAuthenticatorRevocation)
+	admin.GET("/authenticators/revocations/:jobId", controllers.RequirePermission(admin_service.PermissionViewUsers, controllers.RevocationJobTenant), controllers.GetAuthenticatorRevocation)
+	admin.GET("/admins/:userId/roles", controllers.RequireGlobalPermission(admin_service.PermissionManageRoles), controllers.ListAdminRoles)
+	admin.GET("/inventory/:tenant", controllers.RequirePermission(admin_service.PermissionManageTenantPolicy, controllers.TenantParam("tenant")), controllers.GetInventory)
+	admin.GET("/stats", controllers.RequireGlobalPermission(admin_service.PermissionViewStats), controllers.GetStats)
+	admin.GET("/audit/events", controllers.RequirePermission(admin_service.PermissionViewAudit, controllers.TenantFilter("tenant")), controllers.ListAuditEvents)
+	admin.GET("/admin/events", controllers.RequirePermission(admin_service.PermissionViewUsers, controllers.LiveEventTenant), controllers.StreamLiveEvents)
+	admin.GET("/api-keys", controllers.RequireGlobalPermission(admin_service.PermissionManageApiKeys), controllers.ListApiKeys)
+	admin.GET("/webhooks/", controllers.RequireGlobalPermission(admin_service.PermissionManageWebhooks), controllers.ListWebhookSubscriptions)
+	admin.GET("/webhooks/dead-letters", controllers.RequireGlobalPermission(admin_service.PermissionManageWebhooks), controllers.ListWebhookDeadLetters)
+
+	// Destructive actions need a recent passkey ceremony
+	destructive := admin.Group("/", controllers.RequireRecentAuth)
+	destructive.POST("/session/credentials/", controllers.BeginEnrollAdminCredential)
+	destructive.PUT("/session/credentials/:requestId", controllers.FinishEnrollAdminCredential)
+	destructive.POST("/users/import", controllers.RequireGlobalPermission(admin_service.PermissionImportUsers), controllers.ImportUsers)
+	destructive.POST("/users/import-u2f", controllers.RequireGlobalPermission(admin_service.PermissionImportUsers), controllers.ImportU2FUsers)
+	destructive.DELETE("/users/:userId", controllers.RequireGlobalPermission(admin_service.PermissionDeleteUsers), controllers.DeleteUser)
+	destructive.PUT("/users/:userId/credentials/:credentialId/status", controllers.RequireCredentialStatusPermission, controllers.UpdateCredentialStatus)
+	destructive.POST("/authenticators/:aaguid/revoke", controllers.RequirePermission(admin_service.PermissionManageCredentials, controllers.RevokeAuthenticatorTenant), controllers.RevokeAuthenticator)
+	destructive.PUT("/admins/:userId/roles/:tenant", controllers.RequirePermission(admin_service.PermissionManageRoles, controllers.TenantParam("tenant")), controllers.SetAdminRole)
+	destructive.DELETE("/admins/:userId/roles/:tenant", controllers.RequirePermission(admin_service.PermissionManageRoles, controllers.TenantParam("tenant")), controllers.RemoveAdminRole)
+	destructive.PUT("/inventory/:tenant", controllers.RequirePermission(admin_service.PermissionManageTenantPolicy, controllers.TenantParam("tenant")), controllers.UploadInventory)
+	destructive.POST("/api-keys", controllers.RequireGlobalPermission(admin_service.PermissionManageApiKeys), controllers.CreateApiKey)
+	destructive.DELETE("/api-keys/:keyId", controllers.RequireGlobalPermission(admin_service.PermissionManageApiKeys), controllers.RevokeApiKey)
+	destructive.POST("/webhooks/", controllers.RequireGlobalPermission(admin_service.PermissionManageWebhooks), controllers.CreateWebhookSubscription)
+	destructive.DELETE("/webhooks/:subscriptionId", controllers.RequireGlobalPermission(admin_service.PermissionManageWebhooks), controllers.DeleteWebhookSubscription)
+	destructive.POST("/webhooks/deliveries/:deliveryId/replay", controllers.RequireGlobalPermission(admin_service.PermissionManageWebhooks), controllers.ReplayWebhookDelivery)
+
+	// SCIM provisioning is driven by the HR system with a bearer token instead of an admin session
+	scim := engine.Group("/scim/v2", controllers.RequireScimToken)
+	scim.POST("/Users", controllers.CreateScimUser)
+	scim.GET("/Users", controllers.ListScimUsers)
+	scim.GET("/Users/:userId", controllers.GetScimUser)
+	scim.PATCH("/Users/:userId", controllers.PatchScimUser)
+	scim.DELETE("/Users/:userId", controllers.DeleteScimUser)
 
 	// Run Gin
-	engine.Run(":8080")
+	engine.Run(":" + config.GetAppPort())
 }
@@ -85,16 +85,16 @@ func TestGetRedisPassword(t *testing.T) {
 	}
 }
 
-func TestGetSessionSecret(t *testing.T) {
-	curSessionSecret := sessionSecret
+func TestGetTokenSigningKeyPath(t *testing.T) {
+	curTokenSigningKeyPath := tokenSigningKeyPath
 	defer func() {
-		sessionSecret = curSessionSecret
+		tokenSigningKeyPath = curTokenSigningKeyPath
 	}()
 
 	// Test case
-	sessionSecret = "secret"
-	if v := GetSessionSecret(); string(v) != "secret" {
-		t.Errorf("GetSessionSecret() = %v, want %v", v, "secret")
+	tokenSigningKeyPath = "/etc/webauthn/token/signing-key.pem"
+	if v := GetTokenSigningKeyPath(); v != "/etc/webauthn/token/signing-key.pem" {
+		t.Errorf("GetTokenSigningKeyPath() = %v, want %v", v, "/etc/webauthn/token/signing-key.pem")
 	}
 }
 
@@ -252,3 +252,348 @@ func TestGetRPOrigins(t *testing.T) {
 		})
 	}
 }
+
+func TestGetWebhookPollInterval(t *testing.T) {
+	curWebhookPollInterval := webhookPollInterval
+	defer func() {
+		webhookPollInterval = curWebhookPollInterval
+	}()
+
+	defaultInterval := defaultWebhookPollInterval * time.Second
+
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultInterval,
+		},
+		{
+			name:     "Value",
+			input:    "30",
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "Invalid integer",
+			input:    "invalid",
+			expected: defaultInterval,
+		},
+		{
+			name:     "Zero",
+			input:    "0",
+			expected: defaultInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookPollInterval = tt.input
+			if v := GetWebhookPollInterval(); v != tt.expected {
+				t.Errorf("GetWebhookPollInterval() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetSessionTokenTTL(t *testing.T) {
+	curSessionTokenTTL := sessionTokenTTL
+	defer func() {
+		sessionTokenTTL = curSessionTokenTTL
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultSessionTokenTTL * time.Second,
+		},
+		{
+			name:     "Value",
+			input:    "600",
+			expected: 600 * time.Second,
+		},
+		{
+			name:     "Invalid integer",
+			input:    "hour",
+			expected: defaultSessionTokenTTL * time.Second,
+		},
+		{
+			name:     "Zero",
+			input:    "0",
+			expected: defaultSessionTokenTTL * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionTokenTTL = tt.input
+			if v := GetSessionTokenTTL(); v != tt.expected {
+				t.Errorf("GetSessionTokenTTL() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetConfirmationTTL(t *testing.T) {
+	curConfirmationTTL := confirmationTTL
+	defer func() {
+		confirmationTTL = curConfirmationTTL
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultConfirmationTTL * time.Second,
+		},
+		{
+			name:     "Value",
+			input:    "60",
+			expected: 60 * time.Second,
+		},
+		{
+			name:     "Invalid integer",
+			input:    "minute",
+			expected: defaultConfirmationTTL * time.Second,
+		},
+		{
+			name:     "Zero",
+			input:    "0",
+			expected: defaultConfirmationTTL * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmationTTL = tt.input
+			if v := GetConfirmationTTL(); v != tt.expected {
+				t.Errorf("GetConfirmationTTL() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetWebhookMaxAttempts(t *testing.T) {
+	curWebhookMaxAttempts := webhookMaxAttempts
+	defer func() {
+		webhookMaxAttempts = curWebhookMaxAttempts
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultWebhookMaxAttempts,
+		},
+		{
+			name:     "Value",
+			input:    "3",
+			expected: 3,
+		},
+		{
+			name:     "Negative integer",
+			input:    "-1",
+			expected: defaultWebhookMaxAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookMaxAttempts = tt.input
+			if v := GetWebhookMaxAttempts(); v != tt.expected {
+				t.Errorf("GetWebhookMaxAttempts() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetEventStream(t *testing.T) {
+	curEventStream := eventStream
+	defer func() {
+		eventStream = curEventStream
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultEventStream,
+		},
+		{
+			name:     "Value",
+			input:    "custom:events",
+			expected: "custom:events",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventStream = tt.input
+			if v := GetEventStream(); v != tt.expected {
+				t.Errorf("GetEventStream() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetEventStreamMaxLen(t *testing.T) {
+	curEventStreamMaxLen := eventStreamMaxLen
+	defer func() {
+		eventStreamMaxLen = curEventStreamMaxLen
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultEventStreamMaxLen,
+		},
+		{
+			name:     "Value",
+			input:    "500",
+			expected: 500,
+		},
+		{
+			name:     "Invalid integer",
+			input:    "invalid",
+			expected: defaultEventStreamMaxLen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventStreamMaxLen = tt.input
+			if v := GetEventStreamMaxLen(); v != tt.expected {
+				t.Errorf("GetEventStreamMaxLen() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetEventChannel(t *testing.T) {
+	curEventChannel := eventChannel
+	defer func() {
+		eventChannel = curEventChannel
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: defaultEventChannel,
+		},
+		{
+			name:     "Value",
+			input:    "custom:live",
+			expected: "custom:live",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventChannel = tt.input
+			if v := GetEventChannel(); v != tt.expected {
+				t.Errorf("GetEventChannel() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetMDSBlobPath(t *testing.T) {
+	curMDSBlobPath := mdsBlobPath
+	defer func() {
+		mdsBlobPath = curMDSBlobPath
+	}()
+
+	// Test case
+	mdsBlobPath = "/etc/webauthn/mds/blob.jwt"
+	if v := GetMDSBlobPath(); v != "/etc/webauthn/mds/blob.jwt" {
+		t.Errorf("GetMDSBlobPath() = %v, want %v", v, "/etc/webauthn/mds/blob.jwt")
+	}
+}
+
+func TestGetMDSRootCertPath(t *testing.T) {
+	curMDSRootCertPath := mdsRootCertPath
+	defer func() {
+		mdsRootCertPath = curMDSRootCertPath
+	}()
+
+	// Test case
+	mdsRootCertPath = "/etc/webauthn/mds/root.pem"
+	if v := GetMDSRootCertPath(); v != "/etc/webauthn/mds/root.pem" {
+		t.Errorf("GetMDSRootCertPath() = %v, want %v", v, "/etc/webauthn/mds/root.pem")
+	}
+}
+
+func TestGetMDSTenants(t *testing.T) {
+	curMDSTenants := mdsTenants
+	defer func() {
+		mdsTenants = curMDSTenants
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Default",
+			input:    "",
+			expected: []string{},
+		},
+		{
+			name:     "Multiple values",
+			input:    "bank,insurer",
+			expected: []string{"bank", "insurer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mdsTenants = tt.input
+			if v := GetMDSTenants(); !reflect.DeepEqual(v, tt.expected) {
+				t.Errorf("GetMDSTenants() = %v, want %v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetPolicyPath(t *testing.T) {
+	curPolicyPath := policyPath
+	defer func() {
+		policyPath = curPolicyPath
+	}()
+
+	// Test case
+	policyPath = "/etc/webauthn/policy.yaml"
+	if v := GetPolicyPath(); v != "/etc/webauthn/policy.yaml" {
+		t.Errorf("GetPolicyPath() = %v, want %v", v, "/etc/webauthn/policy.yaml")
+	}
+}
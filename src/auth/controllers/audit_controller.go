@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+// recordAuditEvent fills in the request details for the event and appends it to the audit log.
+// Failing to audit never fails the request, but it is logged so it can be alerted on.
+func recordAuditEvent(c *gin.Context, event audit_service.Event) {
+	event.IPAddress = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	if event.Tenant == "" {
+		event.Tenant = getTenant(c)
+	}
+	if event.RequestID == "" {
+		event.RequestID = c.GetHeader("X-Request-ID")
+	}
+
+	service, err := audit_service.New(c)
+	if err != nil {
+		logger.Error("Failed to get audit service", "error", err, "event", event.Type)
+		return
+	}
+	if err := service.Record(&event); err != nil {
+		logger.Error("Failed to record audit event", "error", err, "event", event.Type)
+	}
+}
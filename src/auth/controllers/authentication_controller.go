@@ -1,12 +1,19 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
-	credential_service "blacksmithlabs.dev/webauthn-k8s/auth/services/credential"
 	"blacksmithlabs.dev/webauthn-k8s/auth/services/request_cache"
+	session_service "blacksmithlabs.dev/webauthn-k8s/auth/services/session"
 	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	stepup_service "blacksmithlabs.dev/webauthn-k8s/shared/services/stepup"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 )
@@ -15,49 +22,114 @@ import (
 func BeginAuthentication(c *gin.Context) {
 	var requestPayload dto.StartAuthenticationRequest
 	if err := c.BindJSON(&requestPayload); err != nil {
-		logger.Error("Invalid request format", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request format"})
+		abortWithError(c, invalidRequestFormat(err))
 		return
 	}
 	if err := requestPayload.Validate(); err != nil {
-		logger.Error("Invalid request payload", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request payload"})
+		abortWithError(c, invalidRequestPayload(err))
 		return
 	}
 
+	beginAuthentication(c, requestPayload.User, requestPayload.Extensions, nil)
+}
+
+// POST /authentication/step-up/ end point to start an authentication that confirms a transaction
+func BeginStepUp(c *gin.Context) {
+	var requestPayload dto.StartStepUpRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+	if signingKey == nil {
+		abortWithError(c, internalError("Step-up is not configured", fmt.Errorf("TOKEN_SIGNING_KEY_PATH is not set")))
+		return
+	}
+
+	transaction, err := stepup_service.NewTransaction(requestPayload.Transaction)
+	if err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	beginAuthentication(c, requestPayload.User, dto.AuthenticationExtensions{}, transaction)
+}
+
+// beginAuthentication starts the ceremony for the user. With a transaction the challenge is derived from it and user
+// verification is required, so only FinishStepUp can complete the request.
+func beginAuthentication(c *gin.Context, userInfo dto.AuthenticationUserInfo, extensionInputs dto.AuthenticationExtensions, transaction *stepup_service.Transaction) {
 	service, err := credential_service.New(c)
 	if err != nil {
-		logger.Error("Failed to get credentials service", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Database error"})
+		abortWithError(c, internalError("Database error", err))
 		return
 	}
-	user, err := service.GetUserWithCredentialsByRef(requestPayload.User.UserID, false)
+	user, err := service.GetUserWithCredentialsByRef(userInfo.UserID, false)
 	if err != nil {
-		logger.Error("Failed to get user", "error", err)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error(), "message": "User not found"})
+		recordAuditEvent(c, audit_service.Event{
+			Type:    audit_service.EventAuthenticationFailed,
+			UserRef: userInfo.UserID,
+			Reason:  string(dto.ErrorUserNotFound) + ": user not found",
+		})
+		publishEvent(c, dto.AuthenticationFailedEvent{
+			UserID: userInfo.UserID,
+			Reason: "user not found",
+		})
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", err))
+		return
+	}
+	if !checkUserTenant(c, service, user) {
 		return
 	}
 	if !user.Credentials.Loaded || len(user.Credentials.Value) == 0 {
-		logger.Error("User has no credentials", "user", user)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No credentials found", "message": "User has no credentials"})
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorNoCredentials, "User has no credentials", fmt.Errorf("no active credentials for user(%v)", user.ID)))
 		return
 	}
 
 	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
-	options, sessionData, err := webAuthn.BeginLogin(user)
+	loginOptions := getPolicy(c).LoginOptions()
+	credentials := user.Credentials.Value
+	if largeBlob := extensionInputs.LargeBlob; largeBlob != nil && largeBlob.Write != nil {
+		// Clients only write a large blob when a single credential is allowed
+		credential, err := credential_service.LargeBlobCredential(credentials, largeBlob.CredentialID)
+		if err != nil {
+			abortWithError(c, invalidRequestPayload(err))
+			return
+		}
+		credentials = []credential_service.CredentialModel{*credential}
+		loginOptions = append(loginOptions, webauthn.WithAllowedCredentials([]protocol.CredentialDescriptor{credential.Descriptor()}))
+	}
+	// Set before the AppID, which adds to the extensions instead of replacing them
+	if extensions := credential_service.AuthenticationExtensions(credentials, extensionInputs); extensions != nil {
+		loginOptions = append(loginOptions, webauthn.WithAssertionExtensions(extensions))
+	}
+	if appID := user.LegacyAppID(); appID != "" {
+		// Keys imported from U2F sign with the AppID they were registered under, the session keeps the extension so ValidateLogin accepts it
+		loginOptions = append(loginOptions, webauthn.WithAppIdExtension(appID))
+	}
+	if transaction != nil {
+		loginOptions = append(loginOptions, webauthn.WithUserVerification(protocol.VerificationRequired))
+	}
+	options, sessionData, err := webAuthn.BeginLogin(user, loginOptions...)
 	if err != nil {
-		logger.Error("Failed to create authentication options", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to create authentication options"})
+		abortWithError(c, internalError("Failed to create authentication options", err))
 		return
 	}
+	if transaction != nil {
+		// The authenticator signs over the transaction instead of a random challenge
+		challenge := transaction.Challenge()
+		options.Response.Challenge = challenge
+		sessionData.Challenge = challenge.String()
+	}
 
 	requestId := uuid.New().String()
 
 	cache := request_cache.New(c)
-	requestInfo := request_cache.RequestInfo{UserId: user.ID, SessionData: sessionData}
+	requestInfo := request_cache.RequestInfo{UserId: user.ID, SessionData: sessionData, Transaction: transaction}
 	if err := cache.SetRequestCache(requestId, &requestInfo); err != nil {
-		logger.Error("Failed to save request data to cache", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to save request data to cache"})
+		abortWithError(c, internalError("Failed to save request data", err))
 		return
 	}
 
@@ -72,33 +144,98 @@ func FinishAuthentication(c *gin.Context) {
 	requestId := c.Param("requestId")
 	logger.Info("Finish authentication", "requestId", requestId)
 
-	cache := request_cache.New(c)
-	requestInfo, err := cache.GetRequestCache(requestId)
-	if err == cache.Nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Request Not Found", "requestId": requestId})
+	authentication, ok := finishAuthentication(c, requestId, false)
+	if !ok {
 		return
-	} else if err != nil {
-		logger.Error("Failed to get request data from cache", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to get request data from cache"})
+	}
+
+	response := gin.H{
+		"message":    "Successfully authenticated",
+		"credential": authentication.credential,
+		"useCount":   authentication.useCount,
+		// The largeBlob read or write results, the prf output was stripped when parsing
+		"clientExtensionResults": authentication.clientExtensionResults,
+	}
+	// Sessions are only issued when there is a key to sign them with
+	if signingKey != nil {
+		session, err := session_service.Issue(signingKey, tokenAudience(c), getTenant(c), authentication.user.RefID, authentication.credential.ID, authentication.credential.Flags.UserVerified, time.Now(), sessionTokenTTL)
+		if err != nil {
+			abortWithError(c, internalError("Failed to issue session", err))
+			return
+		}
+		response["session"] = session
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /authentication/step-up/:requestId end point to finish a step-up and sign the confirmation of its transaction
+func FinishStepUp(c *gin.Context) {
+	requestId := c.Param("requestId")
+	logger.Info("Finish step-up", "requestId", requestId)
+
+	authentication, ok := finishAuthentication(c, requestId, true)
+	if !ok {
+		return
+	}
+
+	response, err := authentication.transaction.Confirm(signingKey, tokenAudience(c), getTenant(c), requestId, authentication.user.RefID, authentication.credential.ID, authentication.credential.Flags.UserVerified, time.Now(), confirmationTTL)
+	if err != nil {
+		abortWithError(c, internalError("Failed to confirm transaction", err))
 		return
 	}
 
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventTransactionConfirmed,
+		UserID:       authentication.user.ID,
+		UserRef:      authentication.user.RefID,
+		CredentialID: authentication.credential.ID,
+		RequestID:    requestId,
+		Details:      map[string]any{"transactionHash": response.Claims.TransactionHash.String()},
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// finishedAuthentication is what a completed ceremony hands to the end point that finished it
+type finishedAuthentication struct {
+	user                   *credential_service.UserModel
+	credential             *webauthn.Credential
+	useCount               int32
+	transaction            *stepup_service.Transaction
+	clientExtensionResults protocol.AuthenticationExtensionsClientOutputs
+}
+
+// finishAuthentication validates the assertion of the request, step-up requests can only be finished as such
+func finishAuthentication(c *gin.Context, requestId string, stepUp bool) (*finishedAuthentication, bool) {
+	// The request is used up by the first attempt to finish it, whatever its outcome
+	cache := request_cache.New(c)
+	requestInfo, err := cache.ConsumeRequestCache(requestId)
+	if err == cache.Nil || (err == nil && (requestInfo.Transaction != nil) != stepUp) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorRequestNotFound, "Request not found", fmt.Errorf("request(%v) not in cache", requestId)))
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to get request data", err))
+		return nil, false
+	}
+
 	userId := requestInfo.UserId
 	sessionData := requestInfo.SessionData
 
 	// Get the user for this credential
 	service, err := credential_service.New(c)
 	if err != nil {
-		logger.Error("Failed to get credentials service", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Database error"})
-		return
+		abortWithError(c, internalError("Database error", err))
+		return nil, false
 	}
 
 	user, err := service.GetUserWithCredentialsByID(userId, false)
 	if err != nil {
-		logger.Error("Failed to get user", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "User lookup failed"})
-		return
+		abortWithError(c, internalError("User lookup failed", err))
+		return nil, false
+	}
+	if !checkUserTenant(c, service, user) {
+		return nil, false
 	}
 
 	logger.Info("Loaded user for request", "user", user)
@@ -106,36 +243,107 @@ func FinishAuthentication(c *gin.Context) {
 	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
 	var requestPayload dto.FinishAuthenticationRequest
 	if err := c.BindJSON(&requestPayload); err != nil {
-		logger.Error("Invalid request format", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request format"})
-		return
+		abortWithError(c, invalidRequestFormat(err))
+		return nil, false
 	}
 
-	logger.Info("Preparing to parse assertion", "assertion", requestPayload.Assertion)
-
 	parsedAssertion, err := requestPayload.Assertion.Parse()
 	if err != nil {
-		logger.Error("Failed to parse assertion", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err, "message": "Failed to parse assertion"})
-		return
+		abortWithError(c, utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Failed to parse assertion", err))
+		return nil, false
 	}
+	credential_service.StripPRFResults(parsedAssertion.ClientExtensionResults)
 
 	credential, err := webAuthn.ValidateLogin(user, *sessionData, parsedAssertion)
 	if err != nil {
-		logger.Error("Failed to validate login", "error", err)
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err, "message": "Failed to validate login"})
-		return
+		appErr := utils.NewWebauthnError(http.StatusUnauthorized, dto.ErrorAuthenticationFailed, "Failed to validate login", err)
+		if appErr.Code == dto.ErrorCredentialNotFound {
+			// Only active credentials are offered to the ceremony, tell the client if the one used was disabled or revoked
+			stored, lookupErr := service.GetCredentialByID(parsedAssertion.RawID)
+			if lookupErr == nil && stored.User.Value.ID == user.ID && stored.Meta.Status != credential_service.CredentialStatusActive {
+				appErr = utils.NewError(http.StatusForbidden, dto.ErrorCredentialRevoked, "Credential is "+string(stored.Meta.Status), err)
+			}
+		}
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventAuthenticationFailed,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: parsedAssertion.RawID,
+			RequestID:    requestId,
+			Reason:       string(appErr.Code) + ": " + err.Error(),
+		})
+		publishEvent(c, dto.AuthenticationFailedEvent{
+			UserID:       user.RefID,
+			CredentialID: parsedAssertion.RawID,
+			Reason:       string(appErr.Code),
+		})
+		abortWithError(c, appErr)
+		return nil, false
+	}
+
+	// Policies tightened after registration also apply to existing credentials
+	if err := getPolicy(c).CheckAuthentication(credential); err != nil {
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventAuthenticationFailed,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: credential.ID,
+			RequestID:    requestId,
+			Reason:       string(dto.ErrorPolicyViolation) + ": " + err.Error(),
+		})
+		publishEvent(c, dto.AuthenticationFailedEvent{
+			UserID:       user.RefID,
+			CredentialID: credential.ID,
+			Reason:       string(dto.ErrorPolicyViolation),
+		})
+		abortWithError(c, policyViolation(err))
+		return nil, false
+	}
+
+	if credential.Authenticator.CloneWarning {
+		logger.Warn("Credential sign count indicates a possible clone", "credentialId", credential.ID)
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventCloneWarning,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: credential.ID,
+			RequestID:    requestId,
+			Reason:       "sign count did not increase",
+		})
 	}
 
 	count, err := service.IncrementCredentialUseCounter(credential.ID)
 	if err != nil {
-		logger.Error("Failed to increment credential use counter", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to increment credential use counter"})
-		return
+		abortWithError(c, internalError("Failed to update credential", err))
+		return nil, false
 	}
+	if count == 1 {
+		if err := service.NotifyNewAuthenticatorLogin(user, credential.ID); err != nil {
+			logger.Error("Failed to notify new authenticator login", "error", err)
+		}
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventAuthenticationSucceeded,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credential.ID,
+		RequestID:    requestId,
+	})
 
-	// Clear request cache since request is finished
-	cache.DeleteRequestCache(requestId)
+	publishEvent(c, dto.AuthenticationSucceededEvent{
+		UserID:       user.RefID,
+		CredentialID: credential.ID,
+		UseCount:     count,
+		UserVerified: credential.Flags.UserVerified,
+		CloneWarning: credential.Authenticator.CloneWarning,
+	})
 
-	c.JSON(http.StatusOK, gin.H{"message": "Successfully authenticated", "credential": credential, "useCount": count})
+	return &finishedAuthentication{
+		user:                   user,
+		credential:             credential,
+		useCount:               count,
+		transaction:            requestInfo.Transaction,
+		clientExtensionResults: parsedAssertion.ClientExtensionResults,
+	}, true
 }
@@ -1,7 +1,57 @@
 package controllers
 
 import (
-	"blacksmithlabs.dev/webauthn-k8s/auth/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	policy_service "blacksmithlabs.dev/webauthn-k8s/shared/services/policy"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 )
 
 var logger = utils.GetLogger()
+
+// abortWithError logs the full error and responds with an RFC 7807 problem carrying only its code and safe message.
+// Every controller reports errors through here so clients can rely on the response shape.
+func abortWithError(c *gin.Context, err error) {
+	appErr := utils.AsAppError(err)
+
+	logArgs := []any{"code", appErr.Code, "status", appErr.Status, "path", c.FullPath(), "error", appErr.Err}
+	if appErr.Status >= http.StatusInternalServerError {
+		logger.Error(appErr.Message, logArgs...)
+	} else {
+		logger.Warn(appErr.Message, logArgs...)
+	}
+
+	problem := appErr.Problem()
+	problem.Instance = c.Request.URL.Path
+	c.Header("Content-Type", dto.ProblemContentType)
+	c.AbortWithStatusJSON(appErr.Status, problem)
+}
+
+func invalidRequestFormat(err error) *utils.AppError {
+	return utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid request format", err)
+}
+
+// invalidRequestPayload includes the validation message since it is written by us and safe to return
+func invalidRequestPayload(err error) *utils.AppError {
+	return utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid request payload: "+err.Error(), err)
+}
+
+func internalError(message string, err error) *utils.AppError {
+	return utils.NewError(http.StatusInternalServerError, dto.ErrorInternal, message, err)
+}
+
+// policyViolation includes the violated rule since the policy messages are written by us and safe to return
+func policyViolation(err error) *utils.AppError {
+	return utils.NewError(http.StatusForbidden, dto.ErrorPolicyViolation, "Policy violation: "+err.Error(), err)
+}
+
+// getPolicy returns the authenticator policy of the request's tenant, one that accepts everything when none is bound
+func getPolicy(c *gin.Context) *policy_service.Policy {
+	if policy, ok := c.Get("policy"); ok {
+		return policy.(*policy_service.Policy)
+	}
+	return &policy_service.Policy{}
+}
@@ -1,65 +1,126 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
-	credential_service "blacksmithlabs.dev/webauthn-k8s/auth/services/credential"
 	"blacksmithlabs.dev/webauthn-k8s/auth/services/request_cache"
 	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	inventory_service "blacksmithlabs.dev/webauthn-k8s/shared/services/inventory"
+	metadata_service "blacksmithlabs.dev/webauthn-k8s/shared/services/metadata"
+	policy_service "blacksmithlabs.dev/webauthn-k8s/shared/services/policy"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 )
 
 // POST /credentials/ end point to handle getting the params for creating a credential
 func BeginCreateCredential(c *gin.Context) {
 	var requestPayload dto.StartRegistrationRequest
 	if err := c.BindJSON(&requestPayload); err != nil {
-		logger.Error("Invalid request format", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request format"})
+		abortWithError(c, invalidRequestFormat(err))
 		return
 	}
 	if err := requestPayload.Validate(); err != nil {
-		logger.Error("Invalid request payload", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request payload"})
+		abortWithError(c, invalidRequestPayload(err))
 		return
 	}
 
-	// Upsert the user for this credential
 	service, err := credential_service.New(c)
 	if err != nil {
-		logger.Error("Failed to get credentials service", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Database error"})
+		abortWithError(c, internalError("Database error", err))
 		return
 	}
 
-	user, err := service.UpsertUser(requestPayload.User)
+	// Adding a passkey to an account that already has one is a sensitive operation, disabled credentials count as
+	// they can be enabled again. The name and display name of an existing user are kept, whatever the request says.
+	existing, err := service.GetUserByRef(requestPayload.User.UserID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+	if err == nil {
+		protected, err := service.HasUnrevokedCredentials(existing)
+		if err != nil {
+			abortWithError(c, internalError("Credential lookup failed", err))
+			return
+		}
+		if protected && !requireRecentAuth(c, existing.RefID) {
+			return
+		}
+	}
+
+	beginCreateCredential(c, service, requestPayload.User, requestPayload.Extensions)
+}
+
+// checkUserActive refuses users deprovisioned through SCIM
+func checkUserActive(c *gin.Context, service *credential_service.CredentialService, user *credential_service.UserModel) bool {
+	active, err := service.IsUserActive(user)
+	if err != nil {
+		abortWithError(c, internalError("Failed to get provisioning status", err))
+		return false
+	}
+	if !active {
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "User has been deprovisioned", fmt.Errorf("user(%v) is deprovisioned", user.ID)))
+		return false
+	}
+	return true
+}
+
+// beginCreateCredential upserts the user and starts the registration ceremony of a new credential for them
+func beginCreateCredential(c *gin.Context, service *credential_service.CredentialService, userInfo dto.RegistrationUserInfo, extensionInputs dto.RegistrationExtensions) {
+	user, err := service.UpsertUser(userInfo)
 	if err != nil {
-		logger.Error("Failed to upsert user", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "User creation failed"})
+		abortWithError(c, internalError("User creation failed", err))
 		return
 	} else {
 		logger.Info("User upserted", "user", user)
 	}
 
+	// Users deprovisioned through SCIM may not register new credentials
+	if !checkUserActive(c, service, user) {
+		return
+	}
+	if !checkUserTenant(c, service, user) {
+		return
+	}
+
+	policy := getPolicy(c)
+	if !checkCredentialCount(c, service, user, policy) {
+		return
+	}
+
 	logger.Info("Creating credential for user", "userId", user.ID, "refId", user.RefID)
 
 	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
-	options, sessionData, err := webAuthn.BeginRegistration(user)
+	registrationOptions := policy.RegistrationOptions(webAuthn.Config.RPID, webAuthn.Config.AuthenticatorSelection)
+	var prfSalt []byte
+	if extensionInputs.PRF != nil {
+		if prfSalt, err = credential_service.NewPRFSalt(); err != nil {
+			abortWithError(c, internalError("Failed to create registration options", err))
+			return
+		}
+	}
+	registrationOptions = append(registrationOptions, webauthn.WithExtensions(credential_service.RegistrationExtensions(extensionInputs, prfSalt)))
+	options, sessionData, err := webAuthn.BeginRegistration(user, registrationOptions...)
 	if err != nil {
-		logger.Error("Failed to create registration options", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to create registration options"})
+		abortWithError(c, internalError("Failed to create registration options", err))
 		return
 	}
 
 	requestId := uuid.New().String()
 
 	cache := request_cache.New(c)
-	requestInfo := request_cache.RequestInfo{UserId: user.ID, SessionData: sessionData}
+	requestInfo := request_cache.RequestInfo{UserId: user.ID, SessionData: sessionData, PRFSalt: prfSalt, Extensions: &extensionInputs}
 	if err := cache.SetRequestCache(requestId, &requestInfo); err != nil {
-		logger.Error("Failed to save request data to cache", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to save request data to cache"})
+		abortWithError(c, internalError("Failed to save request data", err))
 		return
 	}
 
@@ -77,11 +138,10 @@ func FinishCreateCredential(c *gin.Context) {
 	cache := request_cache.New(c)
 	requestInfo, err := cache.GetRequestCache(requestId)
 	if err == cache.Nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Request Not Found", "requestId": requestId})
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorRequestNotFound, "Request not found", fmt.Errorf("request(%v) not in cache", requestId)))
 		return
 	} else if err != nil {
-		logger.Error("Failed to get request data from cache", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err, "message": "Failed to get request data from cache"})
+		abortWithError(c, internalError("Failed to get request data", err))
 		return
 	}
 
@@ -91,15 +151,16 @@ func FinishCreateCredential(c *gin.Context) {
 	// Get the user for this credential
 	service, err := credential_service.New(c)
 	if err != nil {
-		logger.Error("Failed to get credentials service", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Database error"})
+		abortWithError(c, internalError("Database error", err))
 		return
 	}
 
 	user, err := service.GetUserByID(userId)
 	if err != nil {
-		logger.Error("Failed to get user", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "User lookup failed"})
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+	if !checkUserTenant(c, service, user) {
 		return
 	}
 
@@ -107,42 +168,179 @@ func FinishCreateCredential(c *gin.Context) {
 
 	var requestPayload dto.FinishRegistrationRequest
 	if err := c.BindJSON(&requestPayload); err != nil {
-		logger.Error("Invalid request format", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid request format"})
+		abortWithError(c, invalidRequestFormat(err))
 		return
 	}
 	parsedCredential, err := requestPayload.Credential.Parse()
 	if err != nil {
-		logger.Error("Failed to parse credential", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err, "message": "Failed to parse credential"})
+		abortWithError(c, utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Failed to parse credential", err))
 		return
 	}
+	credential_service.StripPRFResults(parsedCredential.ClientExtensionResults)
 
 	// Step 1 - 16
 	webAuthn := c.MustGet("webauthn").(*webauthn.WebAuthn)
 	credential, err := webAuthn.CreateCredential(user, *sessionData, parsedCredential)
 	if err != nil {
-		logger.Error("Failed to finish registration", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Failed to finish registration"})
+		appErr := utils.NewWebauthnError(http.StatusBadRequest, dto.ErrorRegistrationFailed, "Failed to finish registration", err)
+		recordAuditEvent(c, audit_service.Event{
+			Type:      audit_service.EventRegistrationFailed,
+			UserID:    user.ID,
+			UserRef:   user.RefID,
+			RequestID: requestId,
+			Reason:    string(appErr.Code) + ": " + err.Error(),
+		})
+		abortWithError(c, appErr)
 		return
 	}
 
-	logger.Info("Credential created", "credential", credential)
+	// The metadata checks only run on attestation statements, so tenants verified against metadata must not skip them
+	if webAuthn.Config.MDS != nil && credential.AttestationType == string(protocol.AttestationFormatNone) {
+		recordAuditEvent(c, audit_service.Event{
+			Type:      audit_service.EventRegistrationFailed,
+			UserID:    user.ID,
+			UserRef:   user.RefID,
+			RequestID: requestId,
+			Reason:    string(dto.ErrorInvalidAttestation) + ": attestation required",
+		})
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidAttestation, "Attestation is required", fmt.Errorf("credential for user(%v) has no attestation", user.ID)))
+		return
+	}
+
+	policy := getPolicy(c)
+	if err := policy.CheckRegistration(credential); err != nil {
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventRegistrationFailed,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: credential.ID,
+			RequestID:    requestId,
+			Reason:       string(dto.ErrorPolicyViolation) + ": " + err.Error(),
+		})
+		abortWithError(c, policyViolation(err))
+		return
+	}
+	var extensions dto.RegistrationExtensions
+	if requestInfo.Extensions != nil {
+		extensions = *requestInfo.Extensions
+	}
+	meta := credential_service.RegistrationMeta(extensions, requestInfo.PRFSalt, parsedCredential.ClientExtensionResults)
+	if policy.EnterpriseAttestation(webAuthn.Config.RPID) {
+		serial, ok := checkEnterpriseAttestation(c, user, credential, policy, requestId)
+		if !ok {
+			return
+		}
+		meta.Serial = serial
+	}
+	// Another registration may have finished, or the user been deprovisioned through SCIM, since this one started
+	if !checkCredentialCount(c, service, user, policy) || !checkUserActive(c, service, user) {
+		return
+	}
+
+	logger.Info("Credential created", "credentialId", credential.ID, "userId", user.ID)
 
 	// Step 17 - Check that the credentialId is not yet registered to any other user
 	// Step 18 - Associate the credential with the user account
-	err = service.InsertCredential(user, credential)
+	err = service.InsertCredential(user, credential, meta)
 	if err != nil {
-		logger.Error("Failed to insert credential", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"erqror": err.Error(), "message": "Failed to insert credential"})
+		abortWithError(c, internalError("Failed to save credential", err))
 		return
 	}
 
+	details := map[string]any{"attestationType": credential.AttestationType}
+	if meta.Serial != "" {
+		details["serial"] = meta.Serial
+	}
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventCredentialRegistered,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credential.ID,
+		RequestID:    requestId,
+		Details:      details,
+	})
+
+	publishEvent(c, dto.CredentialRegisteredEvent{
+		UserID:          user.RefID,
+		CredentialID:    credential.ID,
+		AttestationType: credential.AttestationType,
+		Transports: utils.Map(credential.Transport, func(t protocol.AuthenticatorTransport) string {
+			return string(t)
+		}),
+		BackupEligible: credential.Flags.BackupEligible,
+	})
+
 	// Clear request cache since request is finished
 	cache.DeleteRequestCache(requestId)
 
 	c.JSON(http.StatusOK, dto.FinishRegistrationResponse{
 		RequestID:  requestId,
-		Credential: dto.CredentialResponseFromWebauthn(credential),
+		Credential: credentialResponse(credential, meta),
 	})
 }
+
+func credentialResponse(credential *webauthn.Credential, meta credential_service.CredentialMeta) dto.CredentialResponse {
+	response := dto.CredentialResponseFromWebauthn(credential)
+	if meta.PRF != nil {
+		response.PRF = &dto.PRFStatus{Enabled: meta.PRF.Enabled}
+	}
+	if meta.LargeBlob != nil {
+		response.LargeBlob = &dto.LargeBlobStatus{Supported: meta.LargeBlob.Supported}
+	}
+	response.Discoverable = meta.Discoverable
+	return response
+}
+
+// checkEnterpriseAttestation reads the device serial number from the enterprise attestation and checks it against the
+// tenant's device inventory, aborting the registration when the authenticator is not a company-issued one
+func checkEnterpriseAttestation(c *gin.Context, user *credential_service.UserModel, credential *webauthn.Credential, policy *policy_service.Policy, requestId string) (string, bool) {
+	serial, err := metadata_service.AttestationSerial(credential)
+	if err != nil && !errors.Is(err, metadata_service.ErrNoSerialNumber) {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidAttestation, "Invalid attestation", err))
+		return "", false
+	}
+
+	tenant := getTenant(c)
+	err = policy.CheckSerial(serial, func(serial string) (bool, error) {
+		inventory, err := inventory_service.New(c)
+		if err != nil {
+			return false, err
+		}
+		return inventory.Contains(tenant, serial)
+	})
+	if errors.Is(err, policy_service.ErrPolicyViolation) {
+		recordAuditEvent(c, audit_service.Event{
+			Type:         audit_service.EventRegistrationFailed,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: credential.ID,
+			RequestID:    requestId,
+			Reason:       string(dto.ErrorPolicyViolation) + ": " + err.Error(),
+			Details:      map[string]any{"serial": serial},
+		})
+		abortWithError(c, policyViolation(err))
+		return "", false
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to check device inventory", err))
+		return "", false
+	}
+	return serial, true
+}
+
+// checkCredentialCount aborts the registration when the user already has as many active credentials as the policy allows
+func checkCredentialCount(c *gin.Context, service *credential_service.CredentialService, user *credential_service.UserModel, policy *policy_service.Policy) bool {
+	if policy.MaxCredentials == 0 {
+		return true
+	}
+
+	withCredentials, err := service.GetUserWithCredentialsByID(user.ID, false)
+	if err != nil {
+		abortWithError(c, internalError("Failed to get credentials", err))
+		return false
+	}
+	if err := policy.CheckCredentialCount(len(withCredentials.Credentials.Value)); err != nil {
+		abortWithError(c, policyViolation(err))
+		return false
+	}
+	return true
+}
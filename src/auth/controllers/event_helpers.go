@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
+)
+
+// publishEvent sends the event to the stream consumers, failures are logged and never fail the request
+func publishEvent(c *gin.Context, event dto.Event) {
+	publisher, ok := c.Get("events")
+	if !ok {
+		return
+	}
+	if err := publisher.(event_publisher.EventPublisher).Publish(c, event, getTenant(c)); err != nil {
+		logger.Error("Failed to publish event", "error", err, "event", event.EventType())
+	}
+}
+
+// withEvents has the service publish the events of the changes it makes to the stream consumers
+func withEvents(c *gin.Context, service *credential_service.CredentialService) *credential_service.CredentialService {
+	if publisher, ok := c.Get("events"); ok {
+		service.WithEvents(publisher.(event_publisher.EventPublisher), getTenant(c))
+	}
+	return service
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"blacksmithlabs.dev/webauthn-k8s/auth/cache"
-	"blacksmithlabs.dev/webauthn-k8s/auth/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
 )
 
 func HealthCheck(c *gin.Context) {
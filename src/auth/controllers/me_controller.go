@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/auth/services/request_cache"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+// getMe loads the signed in user, the session was checked by RequireSession
+func getMe(c *gin.Context) (*credential_service.CredentialService, *credential_service.UserModel, bool) {
+	session := mustGetSession(c)
+
+	service, err := credential_service.New(c)
+	if err != nil {
+		abortWithError(c, internalError("Database error", err))
+		return nil, nil, false
+	}
+
+	user, err := service.GetUserWithCredentialsByRef(session.Subject, true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", err))
+		return nil, nil, false
+	} else if err != nil {
+		abortWithError(c, internalError("User lookup failed", err))
+		return nil, nil, false
+	}
+	// Sessions outlive deprovisioning, the account of a deprovisioned user can no longer be managed with them
+	if !checkUserActive(c, service, user) {
+		return nil, nil, false
+	}
+	return service, user, true
+}
+
+func getCredentialIdParam(c *gin.Context) ([]byte, bool) {
+	credentialID, err := base64.RawURLEncoding.DecodeString(c.Param("credentialId"))
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid credentialId, expected base64url", err))
+		return nil, false
+	}
+	return credentialID, true
+}
+
+// GET /me/credentials end point to list the signed in user's credentials, revoked ones are left out
+func ListMyCredentials(c *gin.Context) {
+	_, user, ok := getMe(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": unrevokedCredentials(user)})
+}
+
+// POST /me/credentials/ end point to start adding a passkey to the signed in user's account
+func BeginCreateMyCredential(c *gin.Context) {
+	var requestPayload dto.StartMyRegistrationRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, user, ok := getMe(c)
+	if !ok || !requireRecentAuth(c, user.RefID) {
+		return
+	}
+
+	// Keep the names the relying party registered the user with
+	beginCreateCredential(c, service, dto.RegistrationUserInfo{
+		UserID:      user.RefID,
+		UserName:    user.Name,
+		DisplayName: user.DisplayName,
+	}, requestPayload.Extensions)
+}
+
+// PUT /me/credentials/:requestId end point to finish adding a passkey to the signed in user's account
+func FinishCreateMyCredential(c *gin.Context) {
+	requestId := c.Param("requestId")
+	session := mustGetSession(c)
+
+	_, user, ok := getMe(c)
+	if !ok {
+		return
+	}
+
+	// The registration must have been started by the same user
+	cache := request_cache.New(c)
+	requestInfo, err := cache.GetRequestCache(requestId)
+	if err == cache.Nil || (err == nil && requestInfo.UserId != user.ID) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorRequestNotFound, "Request not found", fmt.Errorf("request(%v) not in cache for user(%v)", requestId, session.Subject)))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to get request data", err))
+		return
+	}
+
+	FinishCreateCredential(c)
+}
+
+// PATCH /me/credentials/:credentialId end point to rename one of the signed in user's credentials
+func RenameMyCredential(c *gin.Context) {
+	credentialID, ok := getCredentialIdParam(c)
+	if !ok {
+		return
+	}
+
+	var requestPayload dto.RenameCredentialRequest
+	if err := c.BindJSON(&requestPayload); err != nil {
+		abortWithError(c, invalidRequestFormat(err))
+		return
+	}
+	if err := requestPayload.Validate(); err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return
+	}
+
+	service, user, ok := getMe(c)
+	if !ok {
+		return
+	}
+
+	credential, err := service.RenameCredential(user, credentialID, requestPayload.Nickname)
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorCredentialNotFound, "Credential not found", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to rename credential", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventCredentialRenamed,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credentialID,
+		Details:      map[string]any{"nickname": requestPayload.Nickname},
+	})
+
+	c.JSON(http.StatusOK, responseCredentials(*credential))
+}
+
+// DELETE /me/credentials/:credentialId end point to remove one of the signed in user's credentials
+func RemoveMyCredential(c *gin.Context) {
+	credentialID, ok := getCredentialIdParam(c)
+	if !ok {
+		return
+	}
+
+	service, user, ok := getMe(c)
+	if !ok || !requireRecentAuth(c, user.RefID) {
+		return
+	}
+
+	credential, err := withEvents(c, service).RemoveCredential(user, credentialID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorCredentialNotFound, "Credential not found", err))
+		return
+	} else if errors.Is(err, credential_service.ErrLastCredential) {
+		abortWithError(c, utils.NewError(http.StatusConflict, dto.ErrorLastCredential, "Set up a recovery method before removing your last passkey", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("Failed to remove credential", err))
+		return
+	}
+
+	recordAuditEvent(c, audit_service.Event{
+		Type:         audit_service.EventCredentialStatusChanged,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credentialID,
+		Reason:       "removed by user",
+		Details:      map[string]any{"status": string(credential.Meta.Status)},
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/auth/config"
+	session_service "blacksmithlabs.dev/webauthn-k8s/auth/services/session"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const sessionKey = "session"
+
+// Sessions and step-up confirmations are signed with the token signing key, relying parties verify them with the
+// public key from GET /.well-known/jwks.json. Neither is issued without a key.
+var signingKey *ecdsa.PrivateKey
+
+var sessionTokenTTL = config.GetSessionTokenTTL()
+
+var confirmationTTL = config.GetConfirmationTTL()
+
+var rpID = config.GetRPID()
+
+// UseSigningKey sets the key sessions and step-up confirmations are signed with
+func UseSigningKey(key *ecdsa.PrivateKey) {
+	signingKey = key
+}
+
+// tokenAudience is who a token is issued to and who may use it: the origin of the relying party's pages, or the RP ID
+// for relying parties calling from their servers
+func tokenAudience(c *gin.Context) string {
+	if origin := c.GetHeader("Origin"); origin != "" {
+		return origin
+	}
+	return rpID
+}
+
+// GET /.well-known/jwks.json end point with the public key relying parties verify sessions and confirmations with
+func GetSigningKeys(c *gin.Context) {
+	var publicKey *ecdsa.PublicKey
+	if signingKey != nil {
+		publicKey = &signingKey.PublicKey
+	}
+	c.JSON(http.StatusOK, token_service.JWKSet(publicKey))
+}
+
+// getSession reads the session token from the Authorization header once per request, nil when the request has none
+func getSession(c *gin.Context) (*dto.SessionClaims, error) {
+	if session, ok := c.Get(sessionKey); ok {
+		return session.(*dto.SessionClaims), nil
+	}
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, nil
+	}
+	if signingKey == nil {
+		return nil, token_service.ErrMissingKey
+	}
+	session, err := session_service.Parse(&signingKey.PublicKey, token, tokenAudience(c), getTenant(c), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	c.Set(sessionKey, session)
+	return session, nil
+}
+
+func stepUpRequired(maxAge int, err error) *utils.AppError {
+	message := "Authenticate again to continue"
+	if maxAge > 0 {
+		message = fmt.Sprintf("Authenticate again to continue, the login must be at most %v seconds old", maxAge)
+	}
+	return utils.NewError(http.StatusUnauthorized, dto.ErrorStepUpRequired, message, err)
+}
+
+// recentAuthMaxAge is the oldest a login may be for a sensitive operation, from the tenant's policy and the request's
+// max_age parameter
+func recentAuthMaxAge(c *gin.Context) (int, error) {
+	value, found := c.GetQuery("max_age")
+	if !found {
+		return getPolicy(c).MaxAge(nil), nil
+	}
+	requested, err := strconv.Atoi(value)
+	if err != nil || requested < 0 {
+		return 0, fmt.Errorf("max_age must be a number of seconds")
+	}
+	return getPolicy(c).MaxAge(&requested), nil
+}
+
+// requireRecentAuth guards a sensitive operation on the user's account. The request must carry a session of the user
+// whose login is recent enough, whatever the policy says, otherwise the client is told to step up with a new login first.
+func requireRecentAuth(c *gin.Context, userRef string) bool {
+	maxAge, err := recentAuthMaxAge(c)
+	if err != nil {
+		abortWithError(c, invalidRequestPayload(err))
+		return false
+	}
+
+	session, err := getSession(c)
+	if errors.Is(err, session_service.ErrSessionExpired) || (err == nil && session == nil) {
+		abortWithError(c, stepUpRequired(maxAge, fmt.Errorf("no valid session for user(%v): %v", userRef, err)))
+		return false
+	} else if err != nil {
+		abortWithError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorUnauthorized, "Invalid session", err))
+		return false
+	}
+	if session.Subject != userRef {
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Session belongs to another user", fmt.Errorf("session of user(%v) used for user(%v)", session.Subject, userRef)))
+		return false
+	}
+	if err := getPolicy(c).CheckRecentAuth(time.Unix(session.AuthTime, 0), session.UserVerified, maxAge, time.Now()); err != nil {
+		abortWithError(c, stepUpRequired(maxAge, err))
+		return false
+	}
+	return true
+}
+
+// RequireSession only lets requests with a valid session through, for the end points of the signed in user
+func RequireSession(c *gin.Context) {
+	session, err := getSession(c)
+	if err == nil && session == nil {
+		err = fmt.Errorf("no session")
+	}
+	if err != nil {
+		abortWithError(c, utils.NewError(http.StatusUnauthorized, dto.ErrorUnauthorized, "Sign in required", err))
+		return
+	}
+	c.Next()
+}
+
+// mustGetSession returns the session checked by RequireSession
+func mustGetSession(c *gin.Context) *dto.SessionClaims {
+	return c.MustGet(sessionKey).(*dto.SessionClaims)
+}
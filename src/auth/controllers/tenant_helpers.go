@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	policy_service "blacksmithlabs.dev/webauthn-k8s/shared/services/policy"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const tenantKey = "tenant"
+
+// ResolveTenant binds the request to the tenant whose pages are served from the request's origin, the default tenant
+// serves the RP origins no tenant lists. Origins nobody serves from are refused rather than given the default tenant.
+//
+// The binding only holds for requests made by browsers, which set the Origin header themselves. Any other client can
+// send the Origin of any tenant, or none for the default tenant, so it must not be trusted to keep tenants apart on
+// its own: users stay with the tenant they registered with whatever origin a request claims, see checkUserTenant, and
+// WebAuthn ceremonies still check the origin the authenticator signed. X-Tenant-ID is chosen by the client, so it is
+// only accepted when it names the same tenant.
+func ResolveTenant(policies *policy_service.Policies, defaultOrigins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		tenant, listed := policies.TenantForOrigin(origin)
+		if !listed && origin != "" && !slices.Contains(defaultOrigins, origin) {
+			abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Unknown origin", fmt.Errorf("origin(%v) is not served by any tenant", origin)))
+			return
+		}
+		if requested := c.GetHeader("X-Tenant-ID"); requested != "" && requested != tenant {
+			abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Unknown tenant", fmt.Errorf("tenant(%v) requested from origin(%v) of tenant(%v)", requested, origin, tenant)))
+			return
+		}
+		c.Set(tenantKey, tenant)
+		c.Next()
+	}
+}
+
+// getTenant returns the tenant bound by ResolveTenant, the default tenant is ""
+func getTenant(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// checkUserTenant keeps users with the tenant they registered with, so the policy and attestation checks that apply to
+// them do not depend on where a request comes from. Users of another tenant are not found.
+func checkUserTenant(c *gin.Context, service *credential_service.CredentialService, user *credential_service.UserModel) bool {
+	tenant, err := service.BindTenant(user, getTenant(c))
+	if err != nil {
+		abortWithError(c, internalError("Failed to get user tenant", err))
+		return false
+	}
+	if tenant != getTenant(c) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", fmt.Errorf("user(%v) of tenant(%v) requested for tenant(%v)", user.ID, tenant, getTenant(c))))
+		return false
+	}
+	return true
+}
@@ -1,11 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
-	credential_service "blacksmithlabs.dev/webauthn-k8s/auth/services/credential"
-	"blacksmithlabs.dev/webauthn-k8s/auth/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 )
 
 type ResponseCredentials struct {
@@ -14,30 +19,76 @@ type ResponseCredentials struct {
 
 	// The credential public key of the public key credential source
 	PublicKey []byte `json:"publicKey"`
+
+	// The nickname of the credential, the authenticator model name unless the user renamed it
+	Nickname string `json:"nickname,omitempty"`
+
+	// The status of the credential, active, disabled or revoked
+	Status string `json:"status,omitempty"`
+
+	// The authenticator model resolved from the AAGUID, omitted when unknown
+	Authenticator *dto.AuthenticatorInfo `json:"authenticator,omitempty"`
+
+	// Whether the credential can derive keys with the prf extension, omitted when it was never asked
+	PRF *dto.PRFStatus `json:"prf,omitempty"`
+
+	// Whether the credential is discoverable and can be used for usernameless login, omitted when unknown
+	Discoverable *bool `json:"rk,omitempty"`
+
+	// Whether the credential can store a large blob, omitted when it was never asked
+	LargeBlob *dto.LargeBlobStatus `json:"largeBlob,omitempty"`
 }
 
-// GET /users/:userId/credentials end point to handle getting the credentials for a user
+// GET /users/:userId/credentials end point to list a user's credentials, revoked ones are left out. It needs a session
+// of the user, like the /me/credentials end points.
 func GetUserCredentials(c *gin.Context) {
 	userId := c.Param("userId")
+	if session := mustGetSession(c); session.Subject != userId {
+		abortWithError(c, utils.NewError(http.StatusForbidden, dto.ErrorForbidden, "Session belongs to another user", fmt.Errorf("session of user(%v) used for user(%v)", session.Subject, userId)))
+		return
+	}
 
 	service, err := credential_service.New(c)
 	if err != nil {
-		logger.Error("Failed to get credentials service", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Database error"})
+		abortWithError(c, internalError("Database error", err))
 		return
 	}
 
 	user, err := service.GetUserWithCredentialsByRef(userId, true)
-	if err != nil {
-		logger.Error("Failed to get user", "error", err)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error(), "message": "User not found"})
+	if errors.Is(err, pgx.ErrNoRows) {
+		abortWithError(c, utils.NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", err))
+		return
+	} else if err != nil {
+		abortWithError(c, internalError("User lookup failed", err))
+		return
+	}
+	if !checkUserTenant(c, service, user) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"credentials": utils.Map(user.Credentials.Value, func(c credential_service.CredentialModel) ResponseCredentials {
-		return ResponseCredentials{
-			ID:        c.ID,
-			PublicKey: c.PublicKey,
+	c.JSON(http.StatusOK, gin.H{"credentials": unrevokedCredentials(user)})
+}
+
+// unrevokedCredentials lists the user's credentials for the user themselves, revoked ones are gone for good
+func unrevokedCredentials(user *credential_service.UserModel) []ResponseCredentials {
+	credentials := []ResponseCredentials{}
+	for _, credential := range user.Credentials.Value {
+		if credential.Meta.Status != credential_service.CredentialStatusRevoked {
+			credentials = append(credentials, responseCredentials(credential))
 		}
-	})})
+	}
+	return credentials
+}
+
+func responseCredentials(c credential_service.CredentialModel) ResponseCredentials {
+	return ResponseCredentials{
+		ID:            c.ID,
+		PublicKey:     c.PublicKey,
+		Nickname:      c.Meta.Nickname,
+		Status:        string(c.Meta.Status),
+		Authenticator: c.AuthenticatorInfo(),
+		PRF:           c.PRFStatus(),
+		Discoverable:  c.Meta.Discoverable,
+		LargeBlob:     c.LargeBlobStatus(),
+	}
 }
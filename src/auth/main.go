@@ -1,19 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
 	"fmt"
+	"slices"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 
+	"blacksmithlabs.dev/webauthn-k8s/auth/cache"
 	"blacksmithlabs.dev/webauthn-k8s/auth/config"
 	"blacksmithlabs.dev/webauthn-k8s/auth/controllers"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
+	metadata_service "blacksmithlabs.dev/webauthn-k8s/shared/services/metadata"
+	policy_service "blacksmithlabs.dev/webauthn-k8s/shared/services/policy"
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+	webhook_service "blacksmithlabs.dev/webauthn-k8s/shared/services/webhook"
 )
 
 var (
 	webAuthn       *webauthn.WebAuthn
+	attestedAuthn  *webauthn.WebAuthn
 	err            error
 	sessionTimeout = config.GetSessionTimeout()
 )
@@ -44,28 +56,118 @@ func main() {
 		panic(fmt.Errorf("failed to create WebAuthn handler: %w", err))
 	}
 
+	// Registrations for the metadata tenants request direct attestation and verify it against the mounted MDS3 BLOB
+	if blobPath := config.GetMDSBlobPath(); blobPath != "" {
+		roots, err := metadata_service.LoadRoots(config.GetMDSRootCertPath())
+		if err != nil {
+			panic(fmt.Errorf("failed to load metadata roots: %w", err))
+		}
+		parsed, err := metadata_service.LoadBlob(blobPath, roots)
+		if err != nil {
+			panic(fmt.Errorf("failed to load metadata BLOB: %w", err))
+		}
+		provider, err := metadata_service.NewProvider(parsed)
+		if err != nil {
+			panic(fmt.Errorf("failed to create metadata provider: %w", err))
+		}
+		// Name hardware authenticators from the metadata statements as well
+		resolver, err := metadata_service.NewResolver(parsed)
+		if err != nil {
+			panic(fmt.Errorf("failed to create authenticator resolver: %w", err))
+		}
+		metadata_service.SetDefaultResolver(resolver)
+
+		attestedConfig := *wconfig
+		attestedConfig.AttestationPreference = protocol.PreferDirectAttestation
+		attestedConfig.MDS = provider
+		if attestedAuthn, err = webauthn.New(&attestedConfig); err != nil {
+			panic(fmt.Errorf("failed to create attested WebAuthn handler: %w", err))
+		}
+	}
+	attestedTenants := config.GetMDSTenants()
+
+	// Authenticator policies are evaluated per tenant at registration and login
+	policies, err := policy_service.LoadPolicies(config.GetPolicyPath())
+	if err != nil {
+		panic(fmt.Errorf("failed to load authenticator policy: %w", err))
+	}
+	if attestedAuthn == nil && policies.RequiresMetadata() {
+		panic(fmt.Errorf("authenticator policy requires trusted attestation but MDS_BLOB_PATH is not set"))
+	}
+	// A tenant the policy does not declare has no origins, so no request would ever be verified for it
+	for _, tenant := range attestedTenants {
+		if _, ok := policies.Tenants[tenant]; !ok {
+			panic(fmt.Errorf("MDS_TENANTS lists tenant %q, which the authenticator policy does not declare", tenant))
+		}
+	}
+	// Without a tenant list every tenant is verified, unless the policy already names the tenants that are
+	attestAll := len(attestedTenants) == 0 && !policies.RequiresMetadata()
+
+	// Sessions and step-up confirmations are signed with an asymmetric key, relying parties only get its public half
+	if keyPath := config.GetTokenSigningKeyPath(); keyPath != "" {
+		key, err := token_service.LoadSigningKey(keyPath)
+		if err != nil {
+			panic(fmt.Errorf("failed to load token signing key: %w", err))
+		}
+		controllers.UseSigningKey(key)
+	}
+
+	// Start delivering webhooks from the outbox
+	database.Configure(config.GetPostgresUrl())
+	pool, err := database.ConnectDb(context.Background())
+	if err != nil {
+		panic(fmt.Errorf("failed to connect to database: %w", err))
+	}
+	worker := webhook_service.NewWorker(credentials.New(pool), config.GetWebhookPollInterval(), config.GetWebhookMaxAttempts())
+	go worker.Run(context.Background())
+
 	// Initialize Gin
 	engine := gin.Default()
-	// Bind the WebAuthn instance to the context
-	engine.Use(func(ctx *gin.Context) {
-		ctx.Set("webauthn", webAuthn)
-	})
-
 	// Enable CORS
 	if origins := config.GetRPOrigins(); len(origins) > 0 {
 		corsConfig := cors.DefaultConfig()
 		corsConfig.AllowOrigins = origins
 		corsConfig.AllowCredentials = true
+		// Session tokens are sent as bearer tokens
+		corsConfig.AddAllowHeaders("Authorization")
 		engine.Use(cors.New(corsConfig))
 	}
 
+	// Bind the tenant of the request's origin, its WebAuthn instance and policy, and the event publisher to the context
+	var publisher event_publisher.EventPublisher = event_publisher.MultiPublisher{
+		event_publisher.NewRedisStreamPublisher(cache.ConnectCache(), config.GetEventStream(), config.GetEventStreamMaxLen()),
+		event_publisher.NewRedisPubSubPublisher(cache.ConnectCache(), config.GetEventChannel()),
+	}
+	engine.Use(controllers.ResolveTenant(policies, config.GetRPOrigins()))
+	engine.Use(func(ctx *gin.Context) {
+		tenant := ctx.GetString("tenant")
+		policy := policies.ForTenant(tenant)
+		ctx.Set("policy", policy)
+		if attestedAuthn != nil && (attestAll || slices.Contains(attestedTenants, tenant) || policy.Attestation.Trusted) {
+			ctx.Set("webauthn", attestedAuthn)
+		} else {
+			ctx.Set("webauthn", webAuthn)
+		}
+		ctx.Set("events", publisher)
+	})
+
 	// Set up routes
 	engine.GET("/_health", controllers.HealthCheck)
-	engine.GET("/users/:userId/credentials/", controllers.GetUserCredentials)
+	engine.GET("/.well-known/jwks.json", controllers.GetSigningKeys)
+	engine.GET("/users/:userId/credentials/", controllers.RequireSession, controllers.GetUserCredentials)
 	engine.POST("/credentials/", controllers.BeginCreateCredential)
 	engine.PUT("/credentials/:requestId", controllers.FinishCreateCredential)
 	engine.POST("/authentication/", controllers.BeginAuthentication)
 	engine.PUT("/authentication/:requestId", controllers.FinishAuthentication)
+	engine.POST("/authentication/step-up/", controllers.BeginStepUp)
+	engine.PUT("/authentication/step-up/:requestId", controllers.FinishStepUp)
+	// Account settings pages manage the signed in user's passkeys with the session token
+	me := engine.Group("/me", controllers.RequireSession)
+	me.GET("/credentials", controllers.ListMyCredentials)
+	me.POST("/credentials/", controllers.BeginCreateMyCredential)
+	me.PUT("/credentials/:requestId", controllers.FinishCreateMyCredential)
+	me.PATCH("/credentials/:credentialId", controllers.RenameMyCredential)
+	me.DELETE("/credentials/:credentialId", controllers.RemoveMyCredential)
 
 	// Run Gin
 	engine.Run(":" + config.GetAppPort())
@@ -7,6 +7,8 @@ import (
 
 	"blacksmithlabs.dev/webauthn-k8s/auth/cache"
 	"blacksmithlabs.dev/webauthn-k8s/auth/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	stepup_service "blacksmithlabs.dev/webauthn-k8s/shared/services/stepup"
 	"github.com/go-webauthn/webauthn/webauthn"
 )
 
@@ -19,6 +21,12 @@ type RequestCacheService struct {
 type RequestInfo struct {
 	UserId      int64
 	SessionData *webauthn.SessionData
+	// PRFSalt is the prf salt offered to the credential being registered, kept when the authenticator enables prf
+	PRFSalt []byte `json:",omitempty"`
+	// Extensions are the extensions the registration asked for, their client results are recorded with the credential
+	Extensions *dto.RegistrationExtensions `json:",omitempty"`
+	// Transaction is the operation a step-up authentication confirms, its challenge is derived from it
+	Transaction *stepup_service.Transaction `json:",omitempty"`
 }
 
 var (
@@ -35,7 +43,16 @@ func New(ctx context.Context) *RequestCacheService {
 }
 
 func (s *RequestCacheService) GetRequestCache(requestId string) (*RequestInfo, error) {
-	requestJson, err := s.client.Get(s.ctx, requestId).Bytes()
+	return s.readRequestCache(s.client.Get(s.ctx, requestId).Bytes())
+}
+
+// ConsumeRequestCache gets and deletes the request in one step, so only one of concurrent attempts to finish a
+// request gets it and a failed attempt can not be retried with the same challenge
+func (s *RequestCacheService) ConsumeRequestCache(requestId string) (*RequestInfo, error) {
+	return s.readRequestCache(s.client.GetDel(s.ctx, requestId).Bytes())
+}
+
+func (s *RequestCacheService) readRequestCache(requestJson []byte, err error) (*RequestInfo, error) {
 	if err == cache.Nil {
 		return nil, s.Nil
 	} else if err != nil {
@@ -0,0 +1,53 @@
+package session_service
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+)
+
+var (
+	ErrSessionExpired = token_service.ErrTokenExpired
+	ErrWrongTenant    = token_service.ErrWrongTenant
+)
+
+// Issue signs a session for a user who just authenticated with the credential, for the audience that asked for it
+func Issue(key *ecdsa.PrivateKey, audience string, tenant string, userRef string, credentialID []byte, userVerified bool, now time.Time, ttl time.Duration) (*dto.SessionResponse, error) {
+	claims := dto.SessionClaims{
+		TokenClaims: dto.TokenClaims{
+			Audience:  audience,
+			Tenant:    tenant,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		ID:           uuid.New().String(),
+		Subject:      userRef,
+		AuthTime:     now.Unix(),
+		UserVerified: userVerified,
+		CredentialID: credentialID,
+	}
+	token, err := token_service.Sign(key, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign session: %w", err)
+	}
+	return &dto.SessionResponse{
+		Token:        token,
+		ExpiresAt:    time.Unix(claims.ExpiresAt, 0),
+		AuthTime:     time.Unix(claims.AuthTime, 0),
+		UserVerified: userVerified,
+	}, nil
+}
+
+// Parse verifies a session token and that it is still valid for the audience and tenant
+func Parse(key *ecdsa.PublicKey, token string, audience string, tenant string, now time.Time) (*dto.SessionClaims, error) {
+	var claims dto.SessionClaims
+	if err := token_service.Parse(key, token, &claims, audience, tenant, now); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
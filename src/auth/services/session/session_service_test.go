@@ -0,0 +1,78 @@
+package session_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+)
+
+const audience = "https://bank.example.com"
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return key
+}
+
+func TestIssueAndParse(t *testing.T) {
+	key := generateKey(t)
+	now := time.Unix(1700000000, 0)
+
+	// Given a session issued after a verified login
+	session, err := Issue(key, audience, "bank", "user", []byte{1, 2, 3}, true, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// When it is parsed for the same tenant
+	claims, err := Parse(&key.PublicKey, session.Token, audience, "bank", now.Add(time.Minute))
+
+	// Then it carries the login time and user verification
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.Subject != "user" || claims.AuthTime != now.Unix() || !claims.UserVerified {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if !session.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the session to expire at %v, got %v", now.Add(time.Hour), session.ExpiresAt)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	key := generateKey(t)
+	other := generateKey(t)
+	now := time.Unix(1700000000, 0)
+	session, err := Issue(key, audience, "bank", "user", nil, false, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      *ecdsa.PublicKey
+		audience string
+		tenant   string
+		now      time.Time
+		expected error
+	}{
+		{name: "Expired", key: &key.PublicKey, audience: audience, tenant: "bank", now: now.Add(time.Hour), expected: ErrSessionExpired},
+		{name: "Other audience", key: &key.PublicKey, audience: "https://insurer.example.com", tenant: "bank", now: now, expected: token_service.ErrWrongAudience},
+		{name: "Other tenant", key: &key.PublicKey, audience: audience, tenant: "insurer", now: now, expected: ErrWrongTenant},
+		{name: "Other key", key: &other.PublicKey, audience: audience, tenant: "bank", now: now, expected: token_service.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.key, session.Token, tt.audience, tt.tenant, tt.now); !errors.Is(err, tt.expected) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	admin_service "blacksmithlabs.dev/webauthn-k8s/shared/services/admin"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+func init() {
+	register("admins enroll", "<userId> <credentialId>", "Let an administrator sign in to the admin service with one of their passkeys", adminsEnroll)
+}
+
+// adminsEnroll bootstraps the first admin passkeys, later ones are enrolled by the admins themselves through the admin API.
+// Anyone can register a passkey for any user on the auth server, so confirm with the admin that the credential is theirs.
+func adminsEnroll(e *env, args []string) error {
+	flags := newFlagSet(e, "admins enroll", "<userId> <credentialId>")
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	credentialID, err := base64.RawURLEncoding.DecodeString(flags.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid credential ID, expected base64url: %w", err)
+	}
+
+	service, err := admin_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	if err := service.EnrollCredential(flags.Arg(0), credentialID, actor()); errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("user %q is not an administrator or has no credential %v", flags.Arg(0), flags.Arg(1))
+	} else if err != nil {
+		return err
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type:         audit_service.EventAdminAction,
+		UserRef:      flags.Arg(0),
+		CredentialID: credentialID,
+		Details:      map[string]any{"action": "admin_credential.enrolled"},
+	})
+
+	fmt.Fprintf(e.stdout, "Credential %v may now sign %v in to the admin service\n", flags.Arg(1), flags.Arg(0))
+	return nil
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+
+	api_key_service "blacksmithlabs.dev/webauthn-k8s/shared/services/api_key"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+func init() {
+	register("api-keys create", "[-scope scim] <name>", "Create an API key and print its token, which is only shown once", apiKeysCreate)
+	register("api-keys list", "[-json]", "List the API keys, revoked ones included", apiKeysList)
+	register("api-keys revoke", "<id>", "Revoke an API key", apiKeysRevoke)
+}
+
+func apiKeysCreate(e *env, args []string) error {
+	flags := newFlagSet(e, "api-keys create", "[-scope scim] <name>")
+	scope := flags.String("scope", string(api_key_service.ScopeScim), "what the key may be used for")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	if api_key_service.Scope(*scope) != api_key_service.ScopeScim {
+		return fmt.Errorf("unknown scope %q", *scope)
+	}
+
+	service, err := api_key_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	token, key, err := service.Create(flags.Arg(0), api_key_service.Scope(*scope))
+	if err != nil {
+		return err
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "api_key.created", "apiKeyId": key.ID, "scope": key.Scope},
+	})
+	fmt.Fprintf(e.stderr, "Created API key %v (%v), store the token now as it can not be shown again:\n", key.ID, key.Name)
+	fmt.Fprintln(e.stdout, token)
+	return nil
+}
+
+func apiKeysList(e *env, args []string) error {
+	flags := newFlagSet(e, "api-keys list", "[-json]")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	service, err := api_key_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := service.List()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, keys)
+	}
+	table := newTable(e.stdout, "ID", "NAME", "SCOPE", "CREATED", "LAST USED", "REVOKED")
+	for _, key := range keys {
+		lastUsed, revoked := "-", "-"
+		if key.LastUsedAt != nil {
+			lastUsed = key.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format("2006-01-02 15:04")
+		}
+		printRow(table, key.ID, key.Name, key.Scope, key.CreatedAt.Format("2006-01-02 15:04"), lastUsed, revoked)
+	}
+	return table.Flush()
+}
+
+func apiKeysRevoke(e *env, args []string) error {
+	flags := newFlagSet(e, "api-keys revoke", "<id>")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID %q", flags.Arg(0))
+	}
+
+	service, err := api_key_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	if err := service.Revoke(id); errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("no active API key with ID %v", id)
+	} else if err != nil {
+		return err
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "api_key.revoked", "apiKeyId": id},
+	})
+	fmt.Fprintf(e.stdout, "Revoked API key %v\n", id)
+	return nil
+}
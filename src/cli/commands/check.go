@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+)
+
+const checkTimeout = 5 * time.Second
+
+func init() {
+	register("check", "", "Check the connections to Postgres and Redis", check)
+}
+
+func checkPostgres(ctx context.Context) error {
+	pool, err := database.ConnectDb(ctx)
+	if err != nil {
+		return err
+	}
+	return pool.Ping(ctx)
+}
+
+func checkRedis(ctx context.Context) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.GetRedisHost(),
+		Password: config.GetRedisPassword(),
+		DB:       0,
+	})
+	defer client.Close()
+	return client.Ping(ctx).Err()
+}
+
+// check reports every connection even when an earlier one failed, so a single run shows everything that is wrong
+func check(e *env, args []string) error {
+	flags := newFlagSet(e, "check", "")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	checks := []struct {
+		name   string
+		target string
+		run    func(context.Context) error
+	}{
+		{"Postgres", "POSTGRES_URL", checkPostgres},
+		{"Redis", config.GetRedisHost(), checkRedis},
+	}
+
+	failed := 0
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(e.ctx, checkTimeout)
+		err := c.run(ctx)
+		cancel()
+
+		if err != nil {
+			failed++
+			fmt.Fprintf(e.stdout, "%-10v FAIL  %v: %v\n", c.name, c.target, err)
+		} else {
+			fmt.Fprintf(e.stdout, "%-10v OK    %v\n", c.name, c.target)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v checks failed", failed, len(checks))
+	}
+	return nil
+}
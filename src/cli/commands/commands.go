@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/user"
+	"sort"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
+)
+
+// errUsage is returned when the arguments are wrong, the usage has already been printed
+var errUsage = errors.New("invalid usage")
+
+// env is what every command gets to work with
+type env struct {
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+	redis  *redis.Client
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(name string, usage string, help string, run func(e *env, args []string) error) {
+	commands[name] = command{usage: usage, help: help, run: run}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: webauthnctl <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Connects with POSTGRES_URL, REDIS_HOST and REDIS_PASSWORD, the same settings as the servers.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-50v %v\n", name+" "+commands[name].usage, commands[name].help)
+	}
+}
+
+// newFlagSet creates the flags for a command, errors are reported by the caller so the output is not duplicated
+func newFlagSet(e *env, name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: webauthnctl %v %v\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the flags and checks the number of positional arguments left
+func parseFlags(flags *flag.FlagSet, args []string, positional int) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != positional {
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// actor identifies the operator in the audit log, the same way the admin API records the admin or API key
+func actor() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return "cli:" + current.Username
+	}
+	return "cli"
+}
+
+func (e *env) recordAuditEvent(event audit_service.Event) {
+	if event.Details == nil {
+		event.Details = map[string]any{}
+	}
+	event.Details["actor"] = actor()
+
+	service, err := audit_service.New(e.ctx)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "warning: failed to get audit service: %v\n", err)
+		return
+	}
+	if err := service.Record(&event); err != nil {
+		fmt.Fprintf(e.stderr, "warning: failed to record audit event: %v\n", err)
+	}
+}
+
+// withEvents has the service publish the changes it makes, such as revocations, to the same event stream and channel
+// as the servers, so consumers see them whichever tool made them
+func (e *env) withEvents(service *credential_service.CredentialService, tenant string) *credential_service.CredentialService {
+	if e.redis == nil {
+		e.redis = redis.NewClient(&redis.Options{
+			Addr:     config.GetRedisHost(),
+			Password: config.GetRedisPassword(),
+			DB:       0,
+		})
+	}
+	return service.WithEvents(event_publisher.MultiPublisher{
+		event_publisher.NewRedisStreamPublisher(e.redis, config.GetEventStream(), config.GetEventStreamMaxLen()),
+		event_publisher.NewRedisPubSubPublisher(e.redis, config.GetEventChannel()),
+	}, tenant)
+}
+
+// Run executes the command in args and returns the process exit code
+func Run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return 0
+	}
+
+	// Commands are grouped by what they work on, like "users list", a few stand on their own, like "check"
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok && len(args) > 1 {
+		name = args[0] + " " + args[1]
+		cmd, ok = commands[name]
+	}
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", strings.Join(args, " "))
+		printUsage(stderr)
+		return 2
+	}
+	args = args[len(strings.Fields(name)):]
+
+	database.Configure(config.GetPostgresUrl())
+	defer database.CloseDb()
+
+	e := &env{ctx: ctx, stdout: stdout, stderr: stderr}
+	defer func() {
+		if e.redis != nil {
+			e.redis.Close()
+		}
+	}()
+	if err := cmd.run(e, args); errors.Is(err, errUsage) {
+		return 2
+	} else if err != nil {
+		fmt.Fprintf(stderr, "%v: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "Help",
+			args:       []string{},
+			wantCode:   0,
+			wantStdout: "users export",
+		},
+		{
+			name:       "Unknown command",
+			args:       []string{"users", "frobnicate"},
+			wantCode:   2,
+			wantStderr: "unknown command \"users frobnicate\"",
+		},
+		{
+			name:       "Missing argument",
+			args:       []string{"users", "show"},
+			wantCode:   2,
+			wantStderr: "Usage: webauthnctl users show",
+		},
+		{
+			name:       "Unknown flag",
+			args:       []string{"credentials", "revoke", "-force", "user", "id"},
+			wantCode:   2,
+			wantStderr: "flag provided but not defined: -force",
+		},
+		{
+			name:       "Rotate needs an ID or all",
+			args:       []string{"webhooks", "rotate-secret", "-all", "1"},
+			wantCode:   2,
+			wantStderr: "Usage: webauthnctl webhooks rotate-secret",
+		},
+		{
+			name:       "Invalid conflict mode",
+			args:       []string{"users", "import", "-mode", "merge"},
+			wantCode:   1,
+			wantStderr: "users import: mode must be skip, overwrite or fail",
+		},
+		{
+			name:       "Missing U2F AppID",
+			args:       []string{"users", "import-u2f", "-i", "keys.jsonl"},
+			wantCode:   2,
+			wantStderr: "-app-id is required",
+		},
+		{
+			name:       "Missing policy file",
+			args:       []string{"credentials", "reevaluate", "-dry-run", "-policy", "missing.yaml"},
+			wantCode:   1,
+			wantStderr: "credentials reevaluate: failed to read policy",
+		},
+		{
+			name:       "Invalid credential ID",
+			args:       []string{"credentials", "disable", "user", "not+base64url"},
+			wantCode:   1,
+			wantStderr: "credentials disable: invalid credential ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			var stdout, stderr bytes.Buffer
+
+			// When
+			got := Run(context.Background(), tt.args, &stdout, &stderr)
+
+			// Then
+			if got != tt.wantCode {
+				t.Errorf("Run() = %v, want %v (stderr %q)", got, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("Run() stdout = %q, want it to contain %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("Run() stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	metadata_service "blacksmithlabs.dev/webauthn-k8s/shared/services/metadata"
+	policy_service "blacksmithlabs.dev/webauthn-k8s/shared/services/policy"
+)
+
+const reevaluateUsage = "[-dry-run] [-tenant id] [-mds-blob file] [-mds-root file] [-policy file] [-json]"
+
+func init() {
+	register("credentials revoke", "[-reason text] <userId> <credentialId>", "Revoke a credential for good", func(e *env, args []string) error {
+		return setCredentialStatus(e, "credentials revoke", args, credential_service.CredentialStatusRevoked)
+	})
+	register("credentials disable", "[-reason text] <userId> <credentialId>", "Disable a credential until it is enabled again", func(e *env, args []string) error {
+		return setCredentialStatus(e, "credentials disable", args, credential_service.CredentialStatusDisabled)
+	})
+	register("credentials enable", "[-reason text] <userId> <credentialId>", "Enable a disabled credential", func(e *env, args []string) error {
+		return setCredentialStatus(e, "credentials enable", args, credential_service.CredentialStatusActive)
+	})
+	register("credentials reevaluate", reevaluateUsage, "Disable active credentials that no longer pass the metadata or policy", credentialsReevaluate)
+}
+
+// setCredentialStatus changes the status the same way the admin API does, webhooks and audit log included.
+// The credential ID is the base64url form shown by "users show".
+func setCredentialStatus(e *env, name string, args []string, status credential_service.CredentialStatus) error {
+	flags := newFlagSet(e, name, "[-reason text] <userId> <credentialId>")
+	reason := flags.String("reason", "", "why the status is changed, recorded in the audit log")
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	credentialID, err := base64.RawURLEncoding.DecodeString(flags.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid credential ID, expected base64url: %w", err)
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	user, err := service.GetUserByRef(flags.Arg(0))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("user %q not found", flags.Arg(0))
+	} else if err != nil {
+		return err
+	}
+
+	tenant, err := service.GetTenant(user)
+	if err != nil {
+		return err
+	}
+
+	credential, err := e.withEvents(service, tenant).UpdateCredentialStatus(user, credentialID, status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("credential not found for user %q", user.RefID)
+	} else if err != nil {
+		return err
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type:         audit_service.EventCredentialStatusChanged,
+		UserID:       user.ID,
+		UserRef:      user.RefID,
+		CredentialID: credential.ID,
+		Tenant:       tenant,
+		Reason:       *reason,
+		Details:      map[string]any{"status": status},
+	})
+
+	fmt.Fprintf(e.stdout, "Credential %v is now %v\n", flags.Arg(1), status)
+	return nil
+}
+
+// credentialsReevaluate checks the active credentials of a tenant's users against the current metadata BLOB and the
+// tenant's authenticator policy, meant to run from a CronJob for every tenant whenever a newer BLOB or policy is mounted.
+// The files default to the auth server's settings.
+func credentialsReevaluate(e *env, args []string) error {
+	flags := newFlagSet(e, "credentials reevaluate", reevaluateUsage)
+	dryRun := flags.Bool("dry-run", false, "only report the credentials that would be disabled")
+	tenant := flags.String("tenant", "", "the tenant whose users are checked against its policy, the default tenant otherwise")
+	blobPath := flags.String("mds-blob", os.Getenv("MDS_BLOB_PATH"), "the FIDO MDS3 BLOB, $MDS_BLOB_PATH by default")
+	rootPath := flags.String("mds-root", os.Getenv("MDS_ROOT_CERT_PATH"), "the PEM root of the BLOB chain, $MDS_ROOT_CERT_PATH by default")
+	policyPath := flags.String("policy", os.Getenv("POLICY_PATH"), "the authenticator policy, $POLICY_PATH by default")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	policies, err := policy_service.LoadPolicies(*policyPath)
+	if err != nil {
+		return err
+	}
+	policy := policies.ForTenant(*tenant)
+
+	var provider metadata.Provider
+	if *blobPath != "" {
+		roots, err := metadata_service.LoadRoots(*rootPath)
+		if err != nil {
+			return err
+		}
+		parsed, err := metadata_service.LoadBlob(*blobPath, roots)
+		if err != nil {
+			return err
+		}
+		if provider, err = metadata_service.NewProvider(parsed); err != nil {
+			return err
+		}
+	} else if policy.Attestation.Trusted {
+		return fmt.Errorf("the policy requires trusted attestation, -mds-blob is required")
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	report, err := e.withEvents(service, *tenant).ReevaluateCredentials(*tenant, func(credential *credential_service.CredentialModel) error {
+		if err := policy.CheckStored(&credential.Credential); err != nil {
+			return fmt.Errorf("policy violation: %w", err)
+		}
+		if provider == nil {
+			return nil
+		}
+		return metadata_service.CheckCredential(provider, &credential.Credential, policy.Attestation.Trusted)
+	}, *dryRun, func(user *credential_service.UserModel, credential *credential_service.CredentialModel, reason string) {
+		// The walk only reaches the users of the tenant, so it is the tenant they are bound to
+		e.recordAuditEvent(audit_service.Event{
+			Type:         audit_service.EventCredentialStatusChanged,
+			UserID:       user.ID,
+			UserRef:      user.RefID,
+			CredentialID: credential.ID,
+			Tenant:       *tenant,
+			Reason:       reason,
+			Details:      map[string]any{"status": credential_service.CredentialStatusDisabled, "action": "credentials.reevaluated"},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, report)
+	}
+	return printReevaluationReport(e, report)
+}
+
+func printReevaluationReport(e *env, report *dto.ReevaluationReport) error {
+	table := newTable(e.stdout, "USER ID", "CREDENTIAL ID", "REASON")
+	for _, failing := range report.Failing {
+		printRow(table, failing.UserID, failing.CredentialID.String(), failing.Reason)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if report.DryRun {
+		fmt.Fprintf(e.stderr, "Checked %v active credentials, %v would be disabled\n", report.Checked, len(report.Failing))
+	} else {
+		fmt.Fprintf(e.stderr, "Checked %v active credentials, disabled %v\n", report.Checked, len(report.Failing))
+	}
+	return nil
+}
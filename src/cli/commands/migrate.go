@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"blacksmithlabs.dev/webauthn-k8s/cli/migrations"
+	"blacksmithlabs.dev/webauthn-k8s/shared/config"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+)
+
+func init() {
+	register("migrate up", "[-dir path]", "Apply every pending migration", migrateUp)
+	register("migrate down", "[-dir path] [-steps n]", "Undo the latest migrations, one by default", migrateDown)
+	register("migrate status", "[-dir path]", "Show the schema version and the pending migrations", migrateStatus)
+	register("migrate force", "[-dir path] <version>", "Mark a version as cleanly applied after fixing a failed migration", migrateForce)
+}
+
+func dirFlag(flags *flag.FlagSet) *string {
+	return flags.String("dir", config.GetMigrationsDir(), "directory holding the up and down SQL files")
+}
+
+func newMigrator(e *env, dir string) (*migrations.Migrator, []migrations.Migration, error) {
+	loaded, err := migrations.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := database.ConnectDb(e.ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	return migrations.New(conn, loaded), loaded, nil
+}
+
+func printMigrations(e *env, verb string, done []migrations.Migration) {
+	for _, migration := range done {
+		fmt.Fprintf(e.stdout, "%v %v_%v\n", verb, migration.Version, migration.Name)
+	}
+	if len(done) == 0 {
+		fmt.Fprintln(e.stdout, "Nothing to do")
+	}
+}
+
+func migrateUp(e *env, args []string) error {
+	flags := newFlagSet(e, "migrate up", "[-dir path]")
+	dir := dirFlag(flags)
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	migrator, _, err := newMigrator(e, *dir)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(e.ctx)
+	printMigrations(e, "Applied", applied)
+	return err
+}
+
+func migrateDown(e *env, args []string) error {
+	flags := newFlagSet(e, "migrate down", "[-dir path] [-steps n]")
+	dir := dirFlag(flags)
+	steps := flags.Int("steps", 1, "number of migrations to undo")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	migrator, _, err := newMigrator(e, *dir)
+	if err != nil {
+		return err
+	}
+	undone, err := migrator.Down(e.ctx, *steps)
+	printMigrations(e, "Undid", undone)
+	return err
+}
+
+func migrateStatus(e *env, args []string) error {
+	flags := newFlagSet(e, "migrate status", "[-dir path]")
+	dir := dirFlag(flags)
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	migrator, loaded, err := newMigrator(e, *dir)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := migrator.Version(e.ctx)
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if dirty {
+		state = "dirty"
+	}
+	fmt.Fprintf(e.stdout, "Version: %v (%v)\n", version, state)
+	for _, migration := range loaded {
+		status := "applied"
+		if migration.Version > version {
+			status = "pending"
+		}
+		fmt.Fprintf(e.stdout, "  %-8v %v_%v\n", status, migration.Version, migration.Name)
+	}
+	return nil
+}
+
+func migrateForce(e *env, args []string) error {
+	flags := newFlagSet(e, "migrate force", "[-dir path] <version>")
+	dir := dirFlag(flags)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	version, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %w", flags.Arg(0), err)
+	}
+
+	migrator, _, err := newMigrator(e, *dir)
+	if err != nil {
+		return err
+	}
+	if err := migrator.Force(e.ctx, version); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Forced version %v\n", version)
+	return nil
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+)
+
+// The JSON output uses the admin API shapes so scripts can handle either the same way
+
+func userResponse(user *credential_service.UserModel) dto.UserResponse {
+	return dto.UserResponse{
+		ID:          user.ID,
+		UserID:      user.RefID,
+		UserName:    user.Name,
+		DisplayName: user.DisplayName,
+	}
+}
+
+func credentialDetailResponse(credential credential_service.CredentialModel) dto.CredentialDetailResponse {
+	return dto.CredentialDetailResponse{
+		ID:              credential.ID,
+		Status:          string(credential.Meta.Status),
+		Nickname:        credential.Meta.Nickname,
+		DisabledReason:  credential.Meta.DisabledReason,
+		Serial:          credential.Meta.Serial,
+		AttestationType: credential.AttestationType,
+		Transports:      credential.Transport,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		CloneWarning:    credential.Authenticator.CloneWarning,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Authenticator:   credential.AuthenticatorInfo(),
+		PRF:             credential.PRFStatus(),
+		Discoverable:    credential.Meta.Discoverable,
+		LargeBlob:       credential.LargeBlobStatus(),
+	}
+}
+
+func printJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+func newTable(w io.Writer, header ...any) *tabwriter.Writer {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	printRow(table, header...)
+	return table
+}
+
+func printRow(table *tabwriter.Writer, columns ...any) {
+	for i, column := range columns {
+		if i > 0 {
+			fmt.Fprint(table, "\t")
+		}
+		fmt.Fprint(table, column)
+	}
+	fmt.Fprintln(table)
+}
+
+func printUsers(w io.Writer, users []*credential_service.UserModel) error {
+	table := newTable(w, "ID", "USER ID", "NAME", "DISPLAY NAME")
+	for _, user := range users {
+		printRow(table, user.ID, user.RefID, user.Name, user.DisplayName)
+	}
+	return table.Flush()
+}
+
+func printCredentials(w io.Writer, credentials []credential_service.CredentialModel) error {
+	table := newTable(w, "CREDENTIAL ID", "STATUS", "NICKNAME", "AUTHENTICATOR", "ATTESTATION", "SIGN COUNT", "BACKED UP")
+	for _, credential := range credentials {
+		authenticator := ""
+		if info := credential.AuthenticatorInfo(); info != nil {
+			authenticator = info.Name
+		}
+		printRow(table,
+			protocol.URLEncodedBase64(credential.ID).String(),
+			credential.Meta.Status,
+			credential.Meta.Nickname,
+			authenticator,
+			credential.AttestationType,
+			credential.Authenticator.SignCount,
+			credential.Flags.BackupState,
+		)
+	}
+	return table.Flush()
+}
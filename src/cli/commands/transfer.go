@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+)
+
+func init() {
+	register("users export", "[-format json|jsonl] [-o file]", "Export every user and credential in the portable format", usersExport)
+	register("users import", importUsage, "Import an export, nothing is imported if any user fails", usersImport)
+	register("users import-u2f", "-app-id url [-mode skip|overwrite|fail] [-i file]", "Import legacy U2F registrations as JSON lines, nothing is imported if any line fails", usersImportU2F)
+}
+
+// formatFlag defaults to the format matching the file extension, JSON lines when streaming through stdin or stdout
+func formatFlag(value string, file string) (dto.ExportFormat, error) {
+	if value == "" {
+		value = string(dto.ExportFormatJSONL)
+		if strings.HasSuffix(file, ".json") {
+			value = string(dto.ExportFormatJSON)
+		}
+	}
+	return dto.ParseExportFormat(value)
+}
+
+func usersExport(e *env, args []string) error {
+	flags := newFlagSet(e, "users export", "[-format json|jsonl] [-o file]")
+	formatValue := flags.String("format", "", "json or jsonl, from the file extension by default")
+	output := flags.String("o", "", "file to write to, stdout by default")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	format, err := formatFlag(*formatValue, *output)
+	if err != nil {
+		return err
+	}
+
+	w := e.stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %v: %w", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	summary, err := service.Export(w, format)
+	if err != nil {
+		return err
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: map[string]any{"action": "users.exported", "format": format, "users": summary.Users, "credentials": summary.Credentials},
+	})
+	fmt.Fprintf(e.stderr, "Exported %v users with %v credentials\n", summary.Users, summary.Credentials)
+	return nil
+}
+
+const importUsage = "[-format json|jsonl] [-mode skip|overwrite|fail] [-tenant id] [-i file]"
+
+func usersImport(e *env, args []string) error {
+	flags := newFlagSet(e, "users import", importUsage)
+	formatValue := flags.String("format", "", "json or jsonl, from the file extension by default")
+	modeValue := flags.String("mode", string(dto.ImportConflictSkip), "what to do with users and credentials that already exist")
+	tenantValue := flags.String("tenant", "", "the tenant to bind every user to, the tenant they were exported from by default")
+	input := flags.String("i", "", "file to read from, stdin by default")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	// -tenant "" binds to the default tenant, so leaving the flag out is told apart from an empty value
+	var tenant *string
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "tenant" {
+			tenant = tenantValue
+		}
+	})
+	format, err := formatFlag(*formatValue, *input)
+	if err != nil {
+		return err
+	}
+	mode, err := dto.ParseImportConflictMode(*modeValue)
+	if err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(*input)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	response, err := service.Import(r, format, mode, tenant)
+	if err != nil {
+		return fmt.Errorf("nothing was imported: %w", err)
+	}
+
+	details := map[string]any{
+		"action":      "users.imported",
+		"mode":        mode,
+		"users":       response.Users,
+		"credentials": response.Credentials,
+	}
+	if tenant != nil {
+		details["tenant"] = *tenant
+	}
+	e.recordAuditEvent(audit_service.Event{
+		Type:    audit_service.EventAdminAction,
+		Details: details,
+	})
+
+	return printImportResponse(e, response)
+}
+
+func usersImportU2F(e *env, args []string) error {
+	flags := newFlagSet(e, "users import-u2f", "-app-id url [-mode skip|overwrite|fail] [-i file]")
+	appID := flags.String("app-id", "", "the AppID the keys were registered under, required")
+	modeValue := flags.String("mode", string(dto.ImportConflictSkip), "what to do with credentials that already exist")
+	input := flags.String("i", "", "file to read from, stdin by default")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *appID == "" {
+		fmt.Fprintln(e.stderr, "-app-id is required")
+		flags.Usage()
+		return errUsage
+	}
+	mode, err := dto.ParseImportConflictMode(*modeValue)
+	if err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(*input)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	response, err := service.ImportU2F(r, *appID, mode)
+	if err != nil {
+		return fmt.Errorf("nothing was imported: %w", err)
+	}
+
+	e.recordAuditEvent(audit_service.Event{
+		Type: audit_service.EventAdminAction,
+		Details: map[string]any{
+			"action":      "users.u2f_imported",
+			"appId":       *appID,
+			"mode":        mode,
+			"users":       response.Users,
+			"credentials": response.Credentials,
+		},
+	})
+
+	return printImportResponse(e, response)
+}
+
+// openInput opens the file to import, stdin when no file is given
+func openInput(input string) (io.Reader, func() error, error) {
+	if input == "" {
+		return os.Stdin, func() error { return nil }, nil
+	}
+	file, err := os.Open(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %v: %w", input, err)
+	}
+	return file, file.Close, nil
+}
+
+func printImportResponse(e *env, response *dto.ImportResponse) error {
+	table := newTable(e.stdout, "", "CREATED", "OVERWRITTEN", "SKIPPED")
+	printRow(table, "Users", response.Users.Created, response.Users.Overwritten, response.Users.Skipped)
+	printRow(table, "Credentials", response.Credentials.Created, response.Credentials.Overwritten, response.Credentials.Skipped)
+	return table.Flush()
+}
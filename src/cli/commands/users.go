@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	credential_service "blacksmithlabs.dev/webauthn-k8s/shared/services/credential"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+func init() {
+	register("users list", "[-q query] [-after id] [-limit n] [-json]", "List users, optionally matching a query", usersList)
+	register("users find", "[-json] <name>", "Find the users with exactly the name", usersFind)
+	register("users show", "[-json] <userId>", "Show a user and all of their credentials", usersShow)
+}
+
+func usersList(e *env, args []string) error {
+	flags := newFlagSet(e, "users list", "[-q query] [-after id] [-limit n] [-json]")
+	query := flags.String("q", "", "only users whose reference, name or display name contain the query")
+	afterID := flags.Int64("after", 0, "continue after the user with this ID")
+	limit := flags.Int("limit", 0, "page size, 50 by default and at most 500")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	users, err := service.SearchUsers(*query, *afterID, *limit)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		response := dto.UserListResponse{Users: utils.Map(users, userResponse)}
+		if len(users) > 0 {
+			response.Next = users[len(users)-1].ID
+		}
+		return printJSON(e.stdout, response)
+	}
+	if err := printUsers(e.stdout, users); err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		fmt.Fprintf(e.stdout, "\nNext page: -after %v\n", users[len(users)-1].ID)
+	}
+	return nil
+}
+
+func usersFind(e *env, args []string) error {
+	flags := newFlagSet(e, "users find", "[-json] <name>")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	users, err := service.ListUsersByName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, utils.Map(users, userResponse))
+	}
+	return printUsers(e.stdout, users)
+}
+
+// getUserWithCredentials loads the user and every credential, whatever the status
+func getUserWithCredentials(service *credential_service.CredentialService, ref string) (*credential_service.UserModel, error) {
+	user, err := service.GetUserWithCredentialsByRef(ref, true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user %q not found", ref)
+	} else if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func usersShow(e *env, args []string) error {
+	flags := newFlagSet(e, "users show", "[-json] <userId>")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	service, err := credential_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	user, err := getUserWithCredentials(service, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, dto.UserDetailResponse{
+			UserResponse: userResponse(user),
+			Credentials:  utils.Map(user.Credentials.Value, credentialDetailResponse),
+		})
+	}
+	fmt.Fprintf(e.stdout, "ID:           %v\n", user.ID)
+	fmt.Fprintf(e.stdout, "User ID:      %v\n", user.RefID)
+	fmt.Fprintf(e.stdout, "Name:         %v\n", user.Name)
+	fmt.Fprintf(e.stdout, "Display name: %v\n\n", user.DisplayName)
+	return printCredentials(e.stdout, user.Credentials.Value)
+}
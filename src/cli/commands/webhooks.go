@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+	webhook_service "blacksmithlabs.dev/webauthn-k8s/shared/services/webhook"
+)
+
+func init() {
+	register("webhooks list", "[-json]", "List the webhook subscriptions", webhooksList)
+	register("webhooks rotate-secret", "(-all | <id>)", "Replace the secret webhook deliveries are signed with and print the new one", webhooksRotateSecret)
+}
+
+func webhooksList(e *env, args []string) error {
+	flags := newFlagSet(e, "webhooks list", "[-json]")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	service, err := webhook_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+	subscriptions, err := service.ListSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, subscriptions)
+	}
+	table := newTable(e.stdout, "ID", "URL", "EVENTS", "ACTIVE", "CREATED")
+	for _, subscription := range subscriptions {
+		printRow(table, subscription.ID, subscription.URL, strings.Join(subscription.EventTypes, ","), subscription.Active, subscription.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return table.Flush()
+}
+
+// webhooksRotateSecret prints "<id> <secret>" per subscription, receivers must be updated with the new secret as
+// deliveries are signed with it straight away
+func webhooksRotateSecret(e *env, args []string) error {
+	flags := newFlagSet(e, "webhooks rotate-secret", "(-all | <id>)")
+	all := flags.Bool("all", false, "rotate the secret of every subscription")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if (*all && flags.NArg() != 0) || (!*all && flags.NArg() != 1) {
+		flags.Usage()
+		return errUsage
+	}
+
+	service, err := webhook_service.New(e.ctx)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	if *all {
+		subscriptions, err := service.ListSubscriptions()
+		if err != nil {
+			return err
+		}
+		for _, subscription := range subscriptions {
+			ids = append(ids, subscription.ID)
+		}
+	} else {
+		id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid subscription ID %q", flags.Arg(0))
+		}
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids {
+		var subscription *dto.WebhookSubscriptionResponse
+		if subscription, err = service.RotateSecret(id); errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("no webhook subscription with ID %v", id)
+		} else if err != nil {
+			return err
+		}
+
+		e.recordAuditEvent(audit_service.Event{
+			Type:    audit_service.EventAdminAction,
+			Details: map[string]any{"action": "webhook.secret_rotated", "subscriptionId": id},
+		})
+		fmt.Fprintf(e.stdout, "%v %v\n", subscription.ID, subscription.Secret)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"blacksmithlabs.dev/webauthn-k8s/cli/commands"
+)
+
+// webauthnctl runs day-2 operations against the same database and cache as the servers
+func main() {
+	os.Exit(commands.Run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+)
+
+// The version table is the one golang-migrate keeps, so the CLI and the migrations image can be used interchangeably
+const createVersionTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single schema change with the files to apply and undo it
+type Migration struct {
+	Version  int64
+	Name     string
+	UpFile   string
+	DownFile string
+}
+
+// Load reads the migrations in the directory, ordered by version. Every migration needs both an up and a down file.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %v: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %v and %v share version %v", migration.Name, match[2], version)
+		}
+		path := filepath.Join(dir, entry.Name())
+		if match[3] == "up" {
+			migration.UpFile = path
+		} else {
+			migration.DownFile = path
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpFile == "" || migration.DownFile == "" {
+			return nil, fmt.Errorf("migration %v_%v needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to the database one at a time, marking the version dirty while a file runs
+type Migrator struct {
+	conn       database.DBConn
+	migrations []Migration
+}
+
+func New(conn database.DBConn, migrations []Migration) *Migrator {
+	return &Migrator{conn: conn, migrations: migrations}
+}
+
+// Version returns the version the database is at, 0 when no migration has run yet
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	if _, err := m.conn.Exec(ctx, createVersionTable); err != nil {
+		return 0, false, fmt.Errorf("failed to create version table: %w", err)
+	}
+
+	var version int64
+	var dirty bool
+	err := m.conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("failed to read version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+func (m *Migrator) setVersion(ctx context.Context, version int64, dirty bool) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return fmt.Errorf("failed to clear version: %w", err)
+	}
+	if version > 0 {
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return fmt.Errorf("failed to set version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, file string) error {
+	sql, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %w", file, err)
+	}
+	// Without arguments the statements are sent as a single simple query, so the files can hold several of them
+	if _, err := m.conn.Exec(ctx, string(sql)); err != nil {
+		return fmt.Errorf("failed to run %v: %w", filepath.Base(file), err)
+	}
+	return nil
+}
+
+func (m *Migrator) cleanVersion(ctx context.Context) (int64, error) {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %v, fix the schema by hand and force the version", version)
+	}
+	return version, nil
+}
+
+// Up applies every migration newer than the current version and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	version, err := m.cleanVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := m.setVersion(ctx, migration.Version, true); err != nil {
+			return applied, err
+		}
+		if err := m.run(ctx, migration.UpFile); err != nil {
+			return applied, err
+		}
+		if err := m.setVersion(ctx, migration.Version, false); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down undoes the given number of migrations, newest first, and returns the ones it undid
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	version, err := m.cleanVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var undone []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(undone) < steps; i-- {
+		migration := m.migrations[i]
+		if migration.Version > version {
+			continue
+		}
+		if migration.Version != version {
+			return undone, fmt.Errorf("database version %v has no migration file", version)
+		}
+
+		var previous int64
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+		if err := m.setVersion(ctx, migration.Version, true); err != nil {
+			return undone, err
+		}
+		if err := m.run(ctx, migration.DownFile); err != nil {
+			return undone, err
+		}
+		if err := m.setVersion(ctx, previous, false); err != nil {
+			return undone, err
+		}
+		undone = append(undone, migration)
+		version = previous
+	}
+
+	return undone, nil
+}
+
+// Force records the version as clean without running anything, once a failed migration has been fixed by hand
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if _, _, err := m.Version(ctx); err != nil {
+		return err
+	}
+	return m.setVersion(ctx, version, false)
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0600); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		want     []int64
+		hasError bool
+	}{
+		{
+			name:  "Ordered by version",
+			files: []string{"20241111_b.up.sql", "20241111_b.down.sql", "20240901_a.down.sql", "20240901_a.up.sql", "README.md"},
+			want:  []int64{20240901, 20241111},
+		},
+		{
+			name:     "Missing down file",
+			files:    []string{"20240901_a.up.sql"},
+			hasError: true,
+		},
+		{
+			name:     "Shared version",
+			files:    []string{"20240901_a.up.sql", "20240901_a.down.sql", "20240901_b.up.sql", "20240901_b.down.sql"},
+			hasError: true,
+		},
+		{
+			name:  "Empty",
+			files: []string{},
+			want:  []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			dir := writeFiles(t, tt.files...)
+
+			// When
+			got, err := Load(dir)
+
+			// Then
+			if (err != nil) != tt.hasError {
+				t.Fatalf("Load() error = %v, hasError %v", err, tt.hasError)
+			}
+			if tt.hasError {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Load() = %+v, want versions %v", got, tt.want)
+			}
+			for i, version := range tt.want {
+				if got[i].Version != version {
+					t.Errorf("Load()[%v].Version = %v, want %v", i, got[i].Version, version)
+				}
+				if got[i].UpFile == "" || got[i].DownFile == "" {
+					t.Errorf("Load()[%v] = %+v, want both files", i, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_RepositoryMigrations(t *testing.T) {
+	// When
+	got, err := Load(filepath.Join("..", "..", "..", "database", "migrations"))
+
+	// Then
+	if err != nil {
+		t.Fatalf("Load() error = %v, want every migration to have an up and a down file", err)
+	}
+	if len(got) == 0 {
+		t.Errorf("Load() = %+v, want the repository migrations", got)
+	}
+}
@@ -0,0 +1,240 @@
+package dto
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// UserResponse is a struct that holds a user as shown to administrators.
+type UserResponse struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"userId"`
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName"`
+}
+
+// UserListResponse is a struct that holds a page of users.
+// Next is the cursor to pass as `after` to fetch the following page, it is omitted on the last page.
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
+	Next  int64          `json:"next,omitempty"`
+}
+
+// CredentialDetailResponse is a struct that holds everything an administrator can see about a credential.
+// The public key is deliberately left out.
+type CredentialDetailResponse struct {
+	ID              protocol.URLEncodedBase64         `json:"id"`
+	Status          string                            `json:"status"`
+	Nickname        string                            `json:"nickname,omitempty"`
+	DisabledReason  string                            `json:"disabledReason,omitempty"`
+	Serial          string                            `json:"serial,omitempty"`
+	AttestationType string                            `json:"attestationType"`
+	Transports      []protocol.AuthenticatorTransport `json:"transports"`
+	AAGUID          protocol.URLEncodedBase64         `json:"aaguid,omitempty"`
+	SignCount       uint32                            `json:"signCount"`
+	CloneWarning    bool                              `json:"cloneWarning"`
+	UserVerified    bool                              `json:"userVerified"`
+	BackupEligible  bool                              `json:"backupEligible"`
+	BackupState     bool                              `json:"backupState"`
+	Authenticator   *AuthenticatorInfo                `json:"authenticator,omitempty"`
+	PRF             *PRFStatus                        `json:"prf,omitempty"`
+	Discoverable    *bool                             `json:"rk,omitempty"`
+	LargeBlob       *LargeBlobStatus                  `json:"largeBlob,omitempty"`
+}
+
+// UserDetailResponse is a struct that holds a user together with all of their credentials.
+type UserDetailResponse struct {
+	UserResponse
+	Credentials []CredentialDetailResponse `json:"credentials"`
+}
+
+// UpdateUserRequest is a struct that holds the request for renaming a user.
+type UpdateUserRequest struct {
+	UserName    string `json:"userName" binding:"required"`
+	DisplayName string `json:"displayName"`
+}
+
+// Validate validates the UpdateUserRequest.
+func (r UpdateUserRequest) Validate() error {
+	if r.UserName == "" {
+		return fmt.Errorf("userName is required")
+	}
+	if len(r.UserName) > 255 || len(r.DisplayName) > 255 {
+		return fmt.Errorf("userName and displayName must be at most 255 characters")
+	}
+	return nil
+}
+
+// UpdateCredentialStatusRequest is a struct that holds the request for changing the status of a credential.
+type UpdateCredentialStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// Validate validates the UpdateCredentialStatusRequest.
+func (r UpdateCredentialStatusRequest) Validate() error {
+	switch r.Status {
+	case "active", "disabled", "revoked":
+		return nil
+	case "":
+		return fmt.Errorf("status is required")
+	default:
+		return fmt.Errorf("status must be one of active, disabled or revoked")
+	}
+}
+
+// SetAdminRoleRequest is a struct that holds the request for granting an administrator a role for a tenant.
+type SetAdminRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// Validate validates the SetAdminRoleRequest.
+func (r SetAdminRoleRequest) Validate() error {
+	switch r.Role {
+	case "viewer", "support", "security-admin", "owner":
+		return nil
+	case "":
+		return fmt.Errorf("role is required")
+	default:
+		return fmt.Errorf("role must be one of viewer, support, security-admin or owner")
+	}
+}
+
+// CreateApiKeyRequest is a struct that holds the request for issuing a bearer token to a machine client.
+type CreateApiKeyRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Scope string `json:"scope" binding:"required"`
+}
+
+// Validate validates the CreateApiKeyRequest.
+func (r CreateApiKeyRequest) Validate() error {
+	if r.Name == "" || len(r.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if r.Scope != "scim" {
+		return fmt.Errorf("scope must be scim")
+	}
+	return nil
+}
+
+// AuthenticatorCredentialResponse is a struct that holds a credential of an authenticator model with its user.
+type AuthenticatorCredentialResponse struct {
+	User       UserResponse             `json:"user"`
+	Credential CredentialDetailResponse `json:"credential"`
+}
+
+// AuthenticatorPreviewResponse is a struct that holds the credentials that revoking an authenticator model would affect.
+type AuthenticatorPreviewResponse struct {
+	AAGUID      string                            `json:"aaguid"`
+	Tenant      string                            `json:"tenant"`
+	Credentials int64                             `json:"credentials"`
+	Users       int64                             `json:"users"`
+	Items       []AuthenticatorCredentialResponse `json:"items"`
+	Next        protocol.URLEncodedBase64         `json:"next,omitempty"`
+}
+
+// RevokeAuthenticatorRequest is a struct that holds the request for revoking every credential of an authenticator model.
+// Tenant limits the revocation to the users of one tenant, "" being the default tenant, every tenant when it is omitted.
+type RevokeAuthenticatorRequest struct {
+	Reason    string  `json:"reason" binding:"required"`
+	Tenant    *string `json:"tenant"`
+	Notify    bool    `json:"notify"`
+	BatchSize int     `json:"batchSize"`
+}
+
+// Validate validates the RevokeAuthenticatorRequest.
+func (r RevokeAuthenticatorRequest) Validate() error {
+	if r.Reason == "" || len(r.Reason) > 500 {
+		return fmt.Errorf("reason is required and must be at most 500 characters")
+	}
+	if r.Tenant != nil && len(*r.Tenant) > 100 {
+		return fmt.Errorf("tenant must be at most 100 characters")
+	}
+	if r.BatchSize < 0 || r.BatchSize > 1000 {
+		return fmt.Errorf("batchSize must be between 1 and 1000")
+	}
+	return nil
+}
+
+type RevocationStatus string
+
+const (
+	RevocationRunning   RevocationStatus = "running"
+	RevocationCompleted RevocationStatus = "completed"
+	RevocationFailed    RevocationStatus = "failed"
+)
+
+// RevokeAuthenticatorResponse is a struct that holds the progress of revoking an authenticator model, which runs in the
+// background and is updated after every batch.
+type RevokeAuthenticatorResponse struct {
+	JobID      string           `json:"jobId"`
+	AAGUID     string           `json:"aaguid"`
+	Tenant     string           `json:"tenant"`
+	Status     RevocationStatus `json:"status"`
+	Revoked    int              `json:"revoked"`
+	Users      int              `json:"users"`
+	Batches    int              `json:"batches"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+}
+
+// AuthenticatorInfo is a struct that holds the human readable name and icons of an authenticator model.
+// The icons are data URIs, the dark one is meant for dark backgrounds.
+type AuthenticatorInfo struct {
+	Name      string `json:"name"`
+	IconLight string `json:"iconLight,omitempty"`
+	IconDark  string `json:"iconDark,omitempty"`
+}
+
+// ReevaluatedCredential is a struct that holds a credential that no longer passes the authenticator metadata or policy.
+type ReevaluatedCredential struct {
+	UserID       string                    `json:"userId"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId"`
+	AAGUID       protocol.URLEncodedBase64 `json:"aaguid,omitempty"`
+	Reason       string                    `json:"reason"`
+}
+
+// ReevaluationReport is a struct that holds the outcome of re-evaluating the active credentials.
+// In a dry run the failing credentials are only reported, otherwise they have been disabled.
+type ReevaluationReport struct {
+	DryRun  bool                    `json:"dryRun"`
+	Checked int                     `json:"checked"`
+	Failing []ReevaluatedCredential `json:"failing"`
+}
+
+// InventoryDevice is a struct that holds a company-issued authenticator, identified by the serial number its enterprise attestation carries.
+type InventoryDevice struct {
+	Serial string `json:"serial"`
+	Label  string `json:"label,omitempty"`
+}
+
+// UploadInventoryRequest is a struct that holds the request for replacing the device inventory of a tenant.
+type UploadInventoryRequest struct {
+	Devices []InventoryDevice `json:"devices"`
+}
+
+// Validate validates the UploadInventoryRequest.
+func (r UploadInventoryRequest) Validate() error {
+	if len(r.Devices) > 100000 {
+		return fmt.Errorf("devices must have at most 100000 entries")
+	}
+	for i, device := range r.Devices {
+		if device.Serial == "" || len(device.Serial) > 100 {
+			return fmt.Errorf("devices[%v].serial is required and must be at most 100 characters", i)
+		}
+		if len(device.Label) > 200 {
+			return fmt.Errorf("devices[%v].label must be at most 200 characters", i)
+		}
+	}
+	return nil
+}
+
+// InventoryResponse is a struct that holds the size of a tenant's device inventory and when it was uploaded.
+type InventoryResponse struct {
+	Tenant     string     `json:"tenant"`
+	Devices    int64      `json:"devices"`
+	UploadedAt *time.Time `json:"uploadedAt,omitempty"`
+}
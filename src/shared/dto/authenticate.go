@@ -19,12 +19,16 @@ func (a AuthenticationUserInfo) Validate() error {
 
 // StartAuthenticationRequest is a struct that holds the request for starting authentication.
 type StartAuthenticationRequest struct {
-	User AuthenticationUserInfo `json:"user" binding:"required"`
+	User       AuthenticationUserInfo   `json:"user" binding:"required"`
+	Extensions AuthenticationExtensions `json:"extensions"`
 }
 
 // Validate validates the StartAuthenticationRequest.
 func (c StartAuthenticationRequest) Validate() error {
-	return c.User.Validate()
+	if err := c.User.Validate(); err != nil {
+		return err
+	}
+	return c.Extensions.Validate()
 }
 
 // Response for starting authentication
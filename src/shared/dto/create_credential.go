@@ -27,12 +27,16 @@ func (r RegistrationUserInfo) Validate() error {
 
 // StartRegistrationRequest is a struct that holds the request for creating a credential.
 type StartRegistrationRequest struct {
-	User RegistrationUserInfo `json:"user" binding:"required"`
+	User       RegistrationUserInfo   `json:"user" binding:"required"`
+	Extensions RegistrationExtensions `json:"extensions"`
 }
 
 // Validate validates the StartRegistrationRequest.
 func (c StartRegistrationRequest) Validate() error {
-	return c.User.Validate()
+	if err := c.User.Validate(); err != nil {
+		return err
+	}
+	return c.Extensions.Validate()
 }
 
 // StartRegistrationResponse is a struct that holds the response for creating a credential.
@@ -50,6 +54,10 @@ type FinishRegistrationRequest struct {
 type CredentialResponse struct {
 	ID        protocol.URLEncodedBase64 `json:"id" binding:"required"`
 	PublicKey protocol.URLEncodedBase64 `json:"publicKey" binding:"required"`
+	PRF       *PRFStatus                `json:"prf,omitempty"`
+	// Discoverable is the credProps rk result, omitted when the client did not report it
+	Discoverable *bool            `json:"rk,omitempty"`
+	LargeBlob    *LargeBlobStatus `json:"largeBlob,omitempty"`
 }
 
 func CredentialResponseFromWebauthn(credential *webauthn.Credential) CredentialResponse {
@@ -0,0 +1,116 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/google/uuid"
+)
+
+// EventSchemaVersion is bumped whenever a field is removed or changes meaning in one of the event payloads.
+// Adding optional fields does not change the version.
+const EventSchemaVersion = 1
+
+type EventType string
+
+const (
+	EventTypeCredentialRegistered    EventType = "credential.registered"
+	EventTypeAuthenticationSucceeded EventType = "authentication.succeeded"
+	EventTypeAuthenticationFailed    EventType = "authentication.failed"
+	EventTypeCredentialRevoked       EventType = "credential.revoked"
+)
+
+// Event is implemented by every payload that can be published on the event stream.
+type Event interface {
+	EventType() EventType
+}
+
+// EventEnvelope wraps an event payload with the metadata consumers need to route and deduplicate it.
+type EventEnvelope struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Tenant     string          `json:"tenant,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NewEventEnvelope wraps the event in a new envelope with a unique ID.
+func NewEventEnvelope(event Event, tenant string) (*EventEnvelope, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %v event: %w", event.EventType(), err)
+	}
+
+	return &EventEnvelope{
+		ID:         uuid.New().String(),
+		Type:       event.EventType(),
+		Version:    EventSchemaVersion,
+		OccurredAt: time.Now().UTC(),
+		Tenant:     tenant,
+		Data:       data,
+	}, nil
+}
+
+// UserID reads the user the event is about from the payload, every event carries it as userId.
+func (e *EventEnvelope) UserID() string {
+	var data struct {
+		UserID string `json:"userId"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return ""
+	}
+	return data.UserID
+}
+
+// CredentialRegisteredEvent is published when a user finishes registering a new credential.
+type CredentialRegisteredEvent struct {
+	UserID          string                    `json:"userId"`
+	CredentialID    protocol.URLEncodedBase64 `json:"credentialId"`
+	AttestationType string                    `json:"attestationType"`
+	Transports      []string                  `json:"transports"`
+	BackupEligible  bool                      `json:"backupEligible"`
+}
+
+func (e CredentialRegisteredEvent) EventType() EventType {
+	return EventTypeCredentialRegistered
+}
+
+// AuthenticationSucceededEvent is published when a user logs in with one of their credentials.
+type AuthenticationSucceededEvent struct {
+	UserID       string                    `json:"userId"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId"`
+	UseCount     int32                     `json:"useCount"`
+	UserVerified bool                      `json:"userVerified"`
+	CloneWarning bool                      `json:"cloneWarning"`
+}
+
+func (e AuthenticationSucceededEvent) EventType() EventType {
+	return EventTypeAuthenticationSucceeded
+}
+
+// AuthenticationFailedEvent is published when a login attempt is rejected.
+type AuthenticationFailedEvent struct {
+	UserID       string                    `json:"userId"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId,omitempty"`
+	Reason       string                    `json:"reason"`
+}
+
+func (e AuthenticationFailedEvent) EventType() EventType {
+	return EventTypeAuthenticationFailed
+}
+
+// CredentialRevokedEvent is published when a credential can no longer be used to log in.
+type CredentialRevokedEvent struct {
+	UserID         string                    `json:"userId"`
+	CredentialID   protocol.URLEncodedBase64 `json:"credentialId"`
+	PreviousStatus string                    `json:"previousStatus"`
+	Status         string                    `json:"status"`
+	Reason         string                    `json:"reason,omitempty"`
+}
+
+func (e CredentialRevokedEvent) EventType() EventType {
+	return EventTypeCredentialRevoked
+}
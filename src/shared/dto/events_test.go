@@ -0,0 +1,30 @@
+package dto
+
+import "testing"
+
+func TestEventEnvelope_UserID(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{name: "Authentication failed", event: AuthenticationFailedEvent{UserID: "user-ref", Reason: "bad signature"}, want: "user-ref"},
+		{name: "Credential registered", event: CredentialRegisteredEvent{UserID: "other-ref"}, want: "other-ref"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, err := NewEventEnvelope(tt.event, "tenant")
+			if err != nil {
+				t.Fatalf("NewEventEnvelope() error = %v, want nil", err)
+			}
+			if got := envelope.UserID(); got != tt.want {
+				t.Errorf("EventEnvelope.UserID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	invalid := EventEnvelope{Data: []byte("not json")}
+	if got := invalid.UserID(); got != "" {
+		t.Errorf("EventEnvelope.UserID() = %v, want empty for an invalid payload", got)
+	}
+}
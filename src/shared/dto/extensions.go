@@ -0,0 +1,117 @@
+package dto
+
+import (
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// PRFInput is a struct that holds the request for the prf extension. The first salt is generated and kept per
+// credential by the server so a passkey always derives the same key, Second is an optional extra salt for key rotation.
+// The PRF output stays on the client, it must never be sent back.
+type PRFInput struct {
+	Second protocol.URLEncodedBase64 `json:"second,omitempty"`
+}
+
+// Validate validates the PRFInput.
+func (p *PRFInput) Validate() error {
+	if p != nil && len(p.Second) > 256 {
+		return fmt.Errorf("prf.second must be at most 256 bytes")
+	}
+	return nil
+}
+
+// PRFStatus is a struct that holds whether a credential can evaluate the prf extension.
+type PRFStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// LargeBlobSupport is how strongly a registration asks for large blob storage
+type LargeBlobSupport string
+
+const (
+	LargeBlobPreferred LargeBlobSupport = "preferred"
+	LargeBlobRequired  LargeBlobSupport = "required"
+)
+
+// LargeBlobMaxSize caps the blob a client may write, authenticators only guarantee about 1KB of storage in total
+const LargeBlobMaxSize = 2048
+
+// LargeBlobRegistrationInput is a struct that holds the request for the largeBlob extension when creating a credential.
+// Support defaults to preferred, with required the client fails the registration on authenticators without storage.
+type LargeBlobRegistrationInput struct {
+	Support LargeBlobSupport `json:"support,omitempty"`
+}
+
+// Validate validates the LargeBlobRegistrationInput.
+func (l *LargeBlobRegistrationInput) Validate() error {
+	if l == nil {
+		return nil
+	}
+	switch l.Support {
+	case "", LargeBlobPreferred, LargeBlobRequired:
+		return nil
+	default:
+		return fmt.Errorf("largeBlob.support must be %q or %q", LargeBlobPreferred, LargeBlobRequired)
+	}
+}
+
+// LargeBlobAuthenticationInput is a struct that holds the request for the largeBlob extension when authenticating.
+// Either the blob is read, or Write is stored on the credential named by CredentialID, which is then the only one
+// offered to the client.
+type LargeBlobAuthenticationInput struct {
+	Read         bool                      `json:"read,omitempty"`
+	Write        protocol.URLEncodedBase64 `json:"write,omitempty"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId,omitempty"`
+}
+
+// Validate validates the LargeBlobAuthenticationInput.
+func (l *LargeBlobAuthenticationInput) Validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.Read == (l.Write != nil) {
+		return fmt.Errorf("largeBlob must either read or write")
+	}
+	if l.Write != nil && len(l.CredentialID) == 0 {
+		return fmt.Errorf("largeBlob.credentialId is required to write")
+	}
+	if len(l.Write) > LargeBlobMaxSize {
+		return fmt.Errorf("largeBlob.write must be at most %d bytes", LargeBlobMaxSize)
+	}
+	return nil
+}
+
+// LargeBlobStatus is a struct that holds whether a credential can store a large blob.
+type LargeBlobStatus struct {
+	Supported bool `json:"supported"`
+}
+
+// RegistrationExtensions is a struct that holds the extensions a client asks for when creating a credential.
+// credProps is always requested, so it is not an option.
+type RegistrationExtensions struct {
+	PRF       *PRFInput                   `json:"prf,omitempty"`
+	LargeBlob *LargeBlobRegistrationInput `json:"largeBlob,omitempty"`
+}
+
+// Validate validates the RegistrationExtensions.
+func (e RegistrationExtensions) Validate() error {
+	if err := e.PRF.Validate(); err != nil {
+		return err
+	}
+	return e.LargeBlob.Validate()
+}
+
+// AuthenticationExtensions is a struct that holds the extensions a client asks for when authenticating.
+type AuthenticationExtensions struct {
+	PRF       *PRFInput                     `json:"prf,omitempty"`
+	LargeBlob *LargeBlobAuthenticationInput `json:"largeBlob,omitempty"`
+}
+
+// Validate validates the AuthenticationExtensions.
+func (e AuthenticationExtensions) Validate() error {
+	if err := e.PRF.Validate(); err != nil {
+		return err
+	}
+	return e.LargeBlob.Validate()
+}
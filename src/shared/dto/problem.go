@@ -0,0 +1,155 @@
+package dto
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses.
+const ProblemContentType = "application/problem+json"
+
+const problemTypePrefix = "urn:webauthn-k8s:problem:"
+
+// ErrorCode is a stable machine readable identifier client SDKs can branch on.
+type ErrorCode string
+
+const (
+	ErrorInvalidRequest           ErrorCode = "invalid_request"
+	ErrorInternal                 ErrorCode = "internal_error"
+	ErrorNotFound                 ErrorCode = "not_found"
+	ErrorConflict                 ErrorCode = "conflict"
+	ErrorUserNotFound             ErrorCode = "user_not_found"
+	ErrorNoCredentials            ErrorCode = "no_credentials"
+	ErrorCredentialNotFound       ErrorCode = "credential_not_found"
+	ErrorCredentialRevoked        ErrorCode = "credential_revoked"
+	ErrorRequestNotFound          ErrorCode = "request_not_found"
+	ErrorChallengeExpired         ErrorCode = "challenge_expired"
+	ErrorChallengeMismatch        ErrorCode = "challenge_mismatch"
+	ErrorOriginMismatch           ErrorCode = "origin_mismatch"
+	ErrorRPIDMismatch             ErrorCode = "rp_id_mismatch"
+	ErrorUserPresenceRequired     ErrorCode = "user_presence_required"
+	ErrorUserVerificationRequired ErrorCode = "user_verification_required"
+	ErrorInvalidSignature         ErrorCode = "invalid_signature"
+	ErrorInvalidAttestation       ErrorCode = "invalid_attestation"
+	ErrorUnsupportedAlgorithm     ErrorCode = "unsupported_algorithm"
+	ErrorRegistrationFailed       ErrorCode = "registration_failed"
+	ErrorAuthenticationFailed     ErrorCode = "authentication_failed"
+	ErrorUnauthorized             ErrorCode = "unauthorized"
+	ErrorForbidden                ErrorCode = "forbidden"
+	ErrorReauthenticationRequired ErrorCode = "reauthentication_required"
+	ErrorPolicyViolation          ErrorCode = "policy_violation"
+	ErrorStepUpRequired           ErrorCode = "step_up_required"
+	ErrorLastCredential           ErrorCode = "last_credential"
+)
+
+var errorTitles = map[ErrorCode]string{
+	ErrorInvalidRequest:           "Invalid request",
+	ErrorInternal:                 "Internal server error",
+	ErrorNotFound:                 "Not found",
+	ErrorConflict:                 "Conflict",
+	ErrorUserNotFound:             "User not found",
+	ErrorNoCredentials:            "User has no credentials",
+	ErrorCredentialNotFound:       "Credential not found",
+	ErrorCredentialRevoked:        "Credential is not active",
+	ErrorRequestNotFound:          "Request not found",
+	ErrorChallengeExpired:         "Challenge expired",
+	ErrorChallengeMismatch:        "Challenge mismatch",
+	ErrorOriginMismatch:           "Origin mismatch",
+	ErrorRPIDMismatch:             "Relying party ID mismatch",
+	ErrorUserPresenceRequired:     "User presence required",
+	ErrorUserVerificationRequired: "User verification required",
+	ErrorInvalidSignature:         "Invalid signature",
+	ErrorInvalidAttestation:       "Invalid attestation",
+	ErrorUnsupportedAlgorithm:     "Unsupported algorithm",
+	ErrorRegistrationFailed:       "Registration failed",
+	ErrorAuthenticationFailed:     "Authentication failed",
+	ErrorUnauthorized:             "Authentication required",
+	ErrorForbidden:                "Forbidden",
+	ErrorReauthenticationRequired: "Re-authentication required",
+	ErrorPolicyViolation:          "Authenticator policy violation",
+	ErrorStepUpRequired:           "Step-up authentication required",
+	ErrorLastCredential:           "Last passkey of the account",
+}
+
+// Problem is an RFC 7807 problem details response extended with a stable error code.
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+}
+
+// NewProblem builds the problem for the code, the detail must be safe to show to clients.
+func NewProblem(status int, code ErrorCode, detail string) Problem {
+	title, ok := errorTitles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+
+	return Problem{
+		Type:   problemTypePrefix + string(code),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// ErrorCodeFromWebauthn maps the errors returned by the go-webauthn ceremonies to a stable error code.
+// The second return value is false when the error did not come from go-webauthn.
+func ErrorCodeFromWebauthn(err error) (ErrorCode, bool) {
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) {
+		return "", false
+	}
+
+	switch protocolErr.Type {
+	case protocol.ErrChallengeMismatch.Type:
+		return ErrorChallengeMismatch, true
+	case protocol.ErrAssertionSignature.Type:
+		return ErrorInvalidSignature, true
+	case protocol.ErrAttestation.Type, protocol.ErrInvalidAttestation.Type, protocol.ErrAttestationCertificate.Type:
+		return ErrorInvalidAttestation, true
+	case protocol.ErrUnsupportedKey.Type, protocol.ErrUnsupportedAlgorithm.Type:
+		return ErrorUnsupportedAlgorithm, true
+	case protocol.ErrVerification.Type:
+		switch {
+		case strings.Contains(protocolErr.Details, "challenge"):
+			return ErrorChallengeMismatch, true
+		case strings.Contains(protocolErr.Details, "origin"), strings.Contains(protocolErr.Details, "topOrigin"):
+			return ErrorOriginMismatch, true
+		case strings.HasPrefix(protocolErr.DevInfo, "RP Hash mismatch"):
+			return ErrorRPIDMismatch, true
+		case strings.HasPrefix(protocolErr.DevInfo, "User presence"):
+			return ErrorUserPresenceRequired, true
+		case strings.HasPrefix(protocolErr.DevInfo, "User verification"):
+			return ErrorUserVerificationRequired, true
+		}
+	case protocol.ErrBadRequest.Type:
+		switch protocolErr.Details {
+		case "Session has Expired":
+			return ErrorChallengeExpired, true
+		case "Unable to find the credential for the returned credential ID":
+			return ErrorCredentialNotFound, true
+		case "Found no credentials for user":
+			return ErrorNoCredentials, true
+		}
+		return ErrorInvalidRequest, true
+	case protocol.ErrParsingData.Type:
+		return ErrorInvalidRequest, true
+	}
+
+	return "", true
+}
@@ -0,0 +1,95 @@
+package dto
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+func TestNewProblem(t *testing.T) {
+	problem := NewProblem(http.StatusNotFound, ErrorUserNotFound, "No user with that ID")
+
+	if problem.Type != "urn:webauthn-k8s:problem:user_not_found" {
+		t.Errorf("NewProblem() type = %v", problem.Type)
+	}
+	if problem.Title != "User not found" {
+		t.Errorf("NewProblem() title = %v, want %v", problem.Title, "User not found")
+	}
+	if problem.Status != http.StatusNotFound || problem.Code != ErrorUserNotFound {
+		t.Errorf("NewProblem() = %+v", problem)
+	}
+
+	unknown := NewProblem(http.StatusTeapot, ErrorCode("custom"), "")
+	if unknown.Title != http.StatusText(http.StatusTeapot) {
+		t.Errorf("NewProblem() title = %v, want status text fallback", unknown.Title)
+	}
+}
+
+func TestErrorCodeFromWebauthn(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expected     ErrorCode
+		expectedFrom bool
+	}{
+		{
+			name:         "Not a webauthn error",
+			err:          fmt.Errorf("database error"),
+			expected:     "",
+			expectedFrom: false,
+		},
+		{
+			name:         "Session expired",
+			err:          protocol.ErrBadRequest.WithDetails("Session has Expired"),
+			expected:     ErrorChallengeExpired,
+			expectedFrom: true,
+		},
+		{
+			name:         "Origin mismatch",
+			err:          protocol.ErrVerification.WithDetails("Error validating origin"),
+			expected:     ErrorOriginMismatch,
+			expectedFrom: true,
+		},
+		{
+			name:         "Challenge mismatch",
+			err:          protocol.ErrVerification.WithDetails("Error validating challenge"),
+			expected:     ErrorChallengeMismatch,
+			expectedFrom: true,
+		},
+		{
+			name:         "RP ID mismatch",
+			err:          protocol.ErrVerification.WithInfo("RP Hash mismatch. Expected 00 and Received 01"),
+			expected:     ErrorRPIDMismatch,
+			expectedFrom: true,
+		},
+		{
+			name:         "User verification",
+			err:          protocol.ErrVerification.WithInfo("User verification required but flag not set by authenticator\n"),
+			expected:     ErrorUserVerificationRequired,
+			expectedFrom: true,
+		},
+		{
+			name:         "Wrapped signature error",
+			err:          fmt.Errorf("login: %w", protocol.ErrAssertionSignature.WithDetails("bad")),
+			expected:     ErrorInvalidSignature,
+			expectedFrom: true,
+		},
+		{
+			name:         "Unknown credential",
+			err:          protocol.ErrBadRequest.WithDetails("Unable to find the credential for the returned credential ID"),
+			expected:     ErrorCredentialNotFound,
+			expectedFrom: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, fromWebauthn := ErrorCodeFromWebauthn(tt.err)
+			if code != tt.expected || fromWebauthn != tt.expectedFrom {
+				t.Errorf("ErrorCodeFromWebauthn() = (%v, %v), want (%v, %v)", code, fromWebauthn, tt.expected, tt.expectedFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ScimContentType is the media type of SCIM 2.0 requests and responses.
+const ScimContentType = "application/scim+json"
+
+const (
+	ScimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ScimMeta is a struct that holds the SCIM resource metadata.
+type ScimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// ScimUser is a struct that holds a SCIM 2.0 user, only the attributes stored for webauthn users are supported.
+type ScimUser struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id,omitempty"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	UserName    string    `json:"userName"`
+	DisplayName string    `json:"displayName,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	Meta        *ScimMeta `json:"meta,omitempty"`
+}
+
+// Validate validates the ScimUser for creation.
+func (u ScimUser) Validate() error {
+	if u.UserName == "" {
+		return fmt.Errorf("userName is required")
+	}
+	if len(u.ExternalID) > 100 {
+		return fmt.Errorf("externalId must be at most 100 characters")
+	}
+	if len(u.UserName) > 255 || len(u.DisplayName) > 255 {
+		return fmt.Errorf("userName and displayName must be at most 255 characters")
+	}
+	return nil
+}
+
+// ScimListResponse is a struct that holds a page of SCIM resources.
+type ScimListResponse struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int        `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []ScimUser `json:"Resources"`
+}
+
+// ScimPatchOperation is a struct that holds a single SCIM patch operation.
+type ScimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ScimPatchRequest is a struct that holds a SCIM PatchOp request.
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations" binding:"required"`
+}
+
+// Validate validates the ScimPatchRequest.
+func (r ScimPatchRequest) Validate() error {
+	if len(r.Operations) == 0 {
+		return fmt.Errorf("Operations is required")
+	}
+	for _, operation := range r.Operations {
+		switch strings.ToLower(operation.Op) {
+		case "add", "replace":
+		default:
+			return fmt.Errorf("unsupported operation %q, only add and replace are supported", operation.Op)
+		}
+	}
+	return nil
+}
+
+// ScimError is a struct that holds a SCIM 2.0 error response.
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewScimError builds the SCIM error, the detail must be safe to show to clients.
+func NewScimError(status int, scimType string, detail string) ScimError {
+	return ScimError{
+		Schemas:  []string{ScimErrorSchema},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
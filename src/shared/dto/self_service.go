@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"fmt"
+	"slices"
+)
+
+// StartMyRegistrationRequest is a struct that holds the request for adding a passkey to the signed in user's account.
+type StartMyRegistrationRequest struct {
+	Extensions RegistrationExtensions `json:"extensions"`
+}
+
+// Validate validates the StartMyRegistrationRequest.
+func (r StartMyRegistrationRequest) Validate() error {
+	return r.Extensions.Validate()
+}
+
+// RenameCredentialRequest is a struct that holds the request for renaming a credential.
+type RenameCredentialRequest struct {
+	Nickname string `json:"nickname" binding:"required"`
+}
+
+// Validate validates the RenameCredentialRequest.
+func (r RenameCredentialRequest) Validate() error {
+	if r.Nickname == "" {
+		return fmt.Errorf("nickname is required")
+	}
+	if len(r.Nickname) > 100 {
+		return fmt.Errorf("nickname must be at most 100 characters")
+	}
+	return nil
+}
+
+// RecoveryMethodsRequest is a struct that holds the account recovery methods the relying party has set up for a user,
+// e.g. "email" or "recovery-codes". Users may only remove their last passkey when they have one.
+type RecoveryMethodsRequest struct {
+	Methods []string `json:"methods"`
+}
+
+// Validate validates the RecoveryMethodsRequest.
+func (r RecoveryMethodsRequest) Validate() error {
+	for i, method := range r.Methods {
+		if method == "" || len(method) > 50 {
+			return fmt.Errorf("methods[%d] must be between 1 and 50 characters", i)
+		}
+		if slices.Contains(r.Methods[:i], method) {
+			return fmt.Errorf("methods[%d] is a duplicate", i)
+		}
+	}
+	return nil
+}
+
+// RecoveryMethodsResponse is a struct that holds a user's account recovery methods.
+type RecoveryMethodsResponse struct {
+	Methods []string `json:"methods"`
+}
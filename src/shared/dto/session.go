@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// SessionClaims is the claims of the session token issued at login. AuthTime is when the user last authenticated with
+// a passkey and UserVerified whether that authentication verified the user, sensitive operations check both.
+type SessionClaims struct {
+	TokenClaims
+	ID           string                    `json:"jti"`
+	Subject      string                    `json:"sub"`
+	AuthTime     int64                     `json:"auth_time"`
+	UserVerified bool                      `json:"uv"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId"`
+}
+
+func (c SessionClaims) GetSubject() (string, error) {
+	return c.Subject, nil
+}
+
+// SessionResponse is a struct that holds the session token issued at login.
+type SessionResponse struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	AuthTime     time.Time `json:"authTime"`
+	UserVerified bool      `json:"userVerified"`
+}
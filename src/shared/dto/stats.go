@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+// DailyStats is a struct that holds the ceremony counts of a single day.
+type DailyStats struct {
+	Day                  string `json:"day"`
+	Registrations        int64  `json:"registrations"`
+	Logins               int64  `json:"logins"`
+	RegistrationFailures int64  `json:"registrationFailures"`
+	LoginFailures        int64  `json:"loginFailures"`
+}
+
+// CountStat is a struct that holds the count for one value of a breakdown.
+type CountStat struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// FailureStat is a struct that holds how often a ceremony failed with an error code.
+type FailureStat struct {
+	Flow   string `json:"flow"`
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+}
+
+// FailureRates is a struct that holds the share of ceremonies that failed, between 0 and 1.
+type FailureRates struct {
+	Registration float64 `json:"registration"`
+	Login        float64 `json:"login"`
+}
+
+// StatsReport is a struct that holds the passkey adoption statistics for a time window.
+// The daily counts, active users and failures cover the window, the credential breakdowns are of the active credentials now.
+type StatsReport struct {
+	From               time.Time     `json:"from"`
+	To                 time.Time     `json:"to"`
+	GeneratedAt        time.Time     `json:"generatedAt"`
+	ActiveUsers        int64         `json:"activeUsers"`
+	Daily              []DailyStats  `json:"daily"`
+	CredentialsPerUser []CountStat   `json:"credentialsPerUser"`
+	AttestationTypes   []CountStat   `json:"attestationTypes"`
+	Transports         []CountStat   `json:"transports"`
+	AAGUIDs            []CountStat   `json:"aaguids"`
+	BackupStates       []CountStat   `json:"backupStates"`
+	FailureRates       FailureRates  `json:"failureRates"`
+	Failures           []FailureStat `json:"failures"`
+}
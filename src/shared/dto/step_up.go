@@ -0,0 +1,60 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// StepUpTransactionMaxSize caps the transaction payload, it is hashed into the challenge and echoed in the confirmation
+const StepUpTransactionMaxSize = 8192
+
+// StartStepUpRequest is a struct that holds the request for starting a step-up authentication that confirms a transaction.
+// The transaction is any JSON object describing the operation, e.g. the payout amount and recipient.
+type StartStepUpRequest struct {
+	User        AuthenticationUserInfo `json:"user" binding:"required"`
+	Transaction json.RawMessage        `json:"transaction" binding:"required"`
+}
+
+// Validate validates the StartStepUpRequest.
+func (s StartStepUpRequest) Validate() error {
+	if err := s.User.Validate(); err != nil {
+		return err
+	}
+	if len(s.Transaction) == 0 {
+		return fmt.Errorf("transaction is required")
+	}
+	if len(s.Transaction) > StepUpTransactionMaxSize {
+		return fmt.Errorf("transaction must be at most %d bytes", StepUpTransactionMaxSize)
+	}
+	var transaction map[string]any
+	if err := json.Unmarshal(s.Transaction, &transaction); err != nil || transaction == nil {
+		return fmt.Errorf("transaction must be a JSON object")
+	}
+	return nil
+}
+
+// TransactionConfirmation is the claims of the signed confirmation a step-up returns. It names the transaction exactly
+// as it was hashed into the challenge the user's authenticator signed.
+type TransactionConfirmation struct {
+	TokenClaims
+	RequestID       string                    `json:"jti"`
+	Subject         string                    `json:"sub"`
+	CredentialID    protocol.URLEncodedBase64 `json:"credentialId"`
+	UserVerified    bool                      `json:"uv"`
+	Transaction     json.RawMessage           `json:"transaction"`
+	TransactionHash protocol.URLEncodedBase64 `json:"transactionHash"`
+	Nonce           protocol.URLEncodedBase64 `json:"nonce"`
+}
+
+func (c TransactionConfirmation) GetSubject() (string, error) {
+	return c.Subject, nil
+}
+
+// FinishStepUpResponse is a struct that holds the signed confirmation of a step-up authentication.
+type FinishStepUpResponse struct {
+	RequestID    string                  `json:"requestId"`
+	Confirmation string                  `json:"confirmation"`
+	Claims       TransactionConfirmation `json:"claims"`
+}
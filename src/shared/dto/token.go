@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenClaims are the claims every token the auth server signs carries. The audience is the origin of the relying
+// party the token was issued to, or the RP ID when it was issued to a server, and the tenant is the tenant of the user.
+type TokenClaims struct {
+	Audience  string `json:"aud"`
+	Tenant    string `json:"tenant"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// The claims are read by the JWT library through the jwt.Claims methods, tokens carry neither an issuer nor a not
+// before time
+func numericDate(seconds int64) *jwt.NumericDate {
+	if seconds == 0 {
+		return nil
+	}
+	return jwt.NewNumericDate(time.Unix(seconds, 0))
+}
+
+func (c TokenClaims) GetExpirationTime() (*jwt.NumericDate, error) {
+	return numericDate(c.ExpiresAt), nil
+}
+
+func (c TokenClaims) GetIssuedAt() (*jwt.NumericDate, error) {
+	return numericDate(c.IssuedAt), nil
+}
+
+func (c TokenClaims) GetNotBefore() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+func (c TokenClaims) GetIssuer() (string, error) {
+	return "", nil
+}
+
+func (c TokenClaims) GetSubject() (string, error) {
+	return "", nil
+}
+
+func (c TokenClaims) GetAudience() (jwt.ClaimStrings, error) {
+	return jwt.ClaimStrings{c.Audience}, nil
+}
+
+func (c TokenClaims) GetTenant() string {
+	return c.Tenant
+}
+
+// JSONWebKey is a struct that holds a public key that tokens are verified with, as defined by RFC 7517.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JSONWebKeySet is a struct that holds the public keys relying parties verify tokens with.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ExportVersion is the version of the export format written by this build. Imports accept this version and older ones.
+const ExportVersion = 1
+
+// ExportFormat is how an export is laid out, either a single JSON document or JSON lines that can be streamed
+type ExportFormat string
+
+const (
+	ExportFormatJSON  ExportFormat = "json"
+	ExportFormatJSONL ExportFormat = "jsonl"
+)
+
+// ImportConflictMode decides what an import does with users and credentials that already exist
+type ImportConflictMode string
+
+const (
+	ImportConflictSkip      ImportConflictMode = "skip"
+	ImportConflictOverwrite ImportConflictMode = "overwrite"
+	ImportConflictFail      ImportConflictMode = "fail"
+)
+
+// ParseExportFormat validates the format, an empty value is the JSON document.
+func ParseExportFormat(value string) (ExportFormat, error) {
+	switch ExportFormat(value) {
+	case "", ExportFormatJSON:
+		return ExportFormatJSON, nil
+	case ExportFormatJSONL:
+		return ExportFormatJSONL, nil
+	default:
+		return "", fmt.Errorf("format must be json or jsonl")
+	}
+}
+
+// ParseImportConflictMode validates the conflict mode, an empty value skips existing records.
+func ParseImportConflictMode(value string) (ImportConflictMode, error) {
+	switch ImportConflictMode(value) {
+	case "", ImportConflictSkip:
+		return ImportConflictSkip, nil
+	case ImportConflictOverwrite, ImportConflictFail:
+		return ImportConflictMode(value), nil
+	default:
+		return "", fmt.Errorf("mode must be skip, overwrite or fail")
+	}
+}
+
+// ExportHeader is a struct that holds the format version of an export.
+// It is the first line of a JSON lines export and the top level of a JSON export.
+type ExportHeader struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// ExportDocument is a struct that holds a whole export as a single JSON document.
+type ExportDocument struct {
+	ExportHeader
+	Users []ExportedUser `json:"users"`
+}
+
+// ExportedUser is a struct that holds a user with all of their credentials.
+// The raw ID is kept so the user handles returned by the authenticators keep matching.
+// The tenant is left out for users that are not bound to one yet, "" is the default tenant.
+type ExportedUser struct {
+	UserID      string                    `json:"userId"`
+	RawID       protocol.URLEncodedBase64 `json:"rawId"`
+	UserName    string                    `json:"userName"`
+	DisplayName string                    `json:"displayName"`
+	Tenant      *string                   `json:"tenant,omitempty"`
+	Credentials []ExportedCredential      `json:"credentials"`
+}
+
+// ExportedCredential is a struct that holds every field of a stored credential, the sign count included, with its meta.
+type ExportedCredential struct {
+	webauthn.Credential
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// ImportCounts is a struct that holds how many records an import created, overwrote and skipped.
+type ImportCounts struct {
+	Created     int `json:"created"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+}
+
+// ImportResponse is a struct that holds the outcome of an import.
+type ImportResponse struct {
+	Version     int                `json:"version"`
+	Mode        ImportConflictMode `json:"mode"`
+	Users       ImportCounts       `json:"users"`
+	Credentials ImportCounts       `json:"credentials"`
+}
+
+// U2FRegistration is a struct that holds a registration exported from a legacy FIDO U2F server, one per line of a U2F import.
+// The public key is the raw uncompressed P-256 point from the U2F registration response, not a COSE key.
+type U2FRegistration struct {
+	UserID      string                            `json:"userId"`
+	UserName    string                            `json:"userName"`
+	DisplayName string                            `json:"displayName"`
+	KeyHandle   protocol.URLEncodedBase64         `json:"keyHandle"`
+	PublicKey   protocol.URLEncodedBase64         `json:"publicKey"`
+	Counter     uint32                            `json:"counter"`
+	Transports  []protocol.AuthenticatorTransport `json:"transports,omitempty"`
+	Nickname    string                            `json:"nickname,omitempty"`
+}
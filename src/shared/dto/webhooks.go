@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// CreateWebhookSubscriptionRequest is a struct that holds the request for subscribing to webhook events.
+type CreateWebhookSubscriptionRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"eventTypes"`
+	Secret     string   `json:"secret"`
+}
+
+// Validate validates the CreateWebhookSubscriptionRequest.
+func (r CreateWebhookSubscriptionRequest) Validate() error {
+	if r.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	parsed, err := url.Parse(r.URL)
+	if err != nil {
+		return fmt.Errorf("url is invalid: %w", err)
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("url must be https")
+	}
+	if r.Secret != "" && len(r.Secret) < 16 {
+		return fmt.Errorf("secret must be at least 16 characters")
+	}
+	return nil
+}
+
+// WebhookSubscriptionResponse is a struct that holds a webhook subscription.
+// The secret is only included when the subscription is created.
+type WebhookSubscriptionResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Secret     string    `json:"secret,omitempty"`
+}
+
+// WebhookDeliveryResponse is a struct that holds a webhook delivery from the outbox.
+type WebhookDeliveryResponse struct {
+	ID             int64     `json:"id"`
+	SubscriptionID int64     `json:"subscriptionId"`
+	EventType      string    `json:"eventType"`
+	Status         string    `json:"status"`
+	Attempts       int32     `json:"attempts"`
+	LastError      string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
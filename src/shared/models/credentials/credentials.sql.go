@@ -11,8 +11,47 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countUnrevokedCredentialsByAaguid = `-- name: CountUnrevokedCredentialsByAaguid :one
+SELECT COUNT(*) AS credential_count, COUNT(DISTINCT webauthn_credentials.user_id) AS user_count
+FROM webauthn_credentials
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_credentials.user_id
+WHERE aaguid = $1
+AND meta->>'status' <> 'revoked'
+AND ($2::VARCHAR IS NULL OR COALESCE(user_tenants.tenant, '') = $2)
+`
+
+type CountUnrevokedCredentialsByAaguidParams struct {
+	Aaguid []byte
+	Tenant pgtype.Text
+}
+
+type CountUnrevokedCredentialsByAaguidRow struct {
+	CredentialCount int64
+	UserCount       int64
+}
+
+func (q *Queries) CountUnrevokedCredentialsByAaguid(ctx context.Context, arg CountUnrevokedCredentialsByAaguidParams) (CountUnrevokedCredentialsByAaguidRow, error) {
+	row := q.db.QueryRow(ctx, countUnrevokedCredentialsByAaguid, arg.Aaguid, arg.Tenant)
+	var i CountUnrevokedCredentialsByAaguidRow
+	err := row.Scan(&i.CredentialCount, &i.UserCount)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM webauthn_users
+WHERE ref_id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, refID string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, refID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getCredential = `-- name: GetCredential :one
-SELECT webauthn_credentials.credential_id, webauthn_credentials.user_id, webauthn_credentials.use_counter, webauthn_credentials.public_key, webauthn_credentials.attestation_type, webauthn_credentials.transport, webauthn_credentials.flags, webauthn_credentials.authenticator, webauthn_credentials.attestation, webauthn_credentials.meta, webauthn_users._id, webauthn_users.ref_id, webauthn_users.raw_id, webauthn_users.name, webauthn_users.display_name
+SELECT webauthn_credentials.credential_id, webauthn_credentials.user_id, webauthn_credentials.use_counter, webauthn_credentials.public_key, webauthn_credentials.attestation_type, webauthn_credentials.transport, webauthn_credentials.flags, webauthn_credentials.authenticator, webauthn_credentials.attestation, webauthn_credentials.meta, webauthn_credentials.aaguid, webauthn_users._id, webauthn_users.ref_id, webauthn_users.raw_id, webauthn_users.name, webauthn_users.display_name
 FROM webauthn_credentials
 INNER JOIN webauthn_users ON webauthn_credentials.user_id = webauthn_users._id
 WHERE credential_id = $1
@@ -37,6 +76,7 @@ func (q *Queries) GetCredential(ctx context.Context, credentialID []byte) (GetCr
 		&i.WebauthnCredential.Authenticator,
 		&i.WebauthnCredential.Attestation,
 		&i.WebauthnCredential.Meta,
+		&i.WebauthnCredential.Aaguid,
 		&i.WebauthnUser.ID,
 		&i.WebauthnUser.RefID,
 		&i.WebauthnUser.RawID,
@@ -100,10 +140,10 @@ func (q *Queries) IncrementCredentialUseCounter(ctx context.Context, credentialI
 
 const insertCredential = `-- name: InsertCredential :one
 INSERT INTO webauthn_credentials (
-    "credential_id", "user_id", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta"
+    "credential_id", "user_id", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta", "aaguid"
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
-) RETURNING credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
 `
 
 type InsertCredentialParams struct {
@@ -116,6 +156,7 @@ type InsertCredentialParams struct {
 	Authenticator   []byte
 	Attestation     []byte
 	Meta            []byte
+	Aaguid          []byte
 }
 
 func (q *Queries) InsertCredential(ctx context.Context, arg InsertCredentialParams) (WebauthnCredential, error) {
@@ -129,6 +170,7 @@ func (q *Queries) InsertCredential(ctx context.Context, arg InsertCredentialPara
 		arg.Authenticator,
 		arg.Attestation,
 		arg.Meta,
+		arg.Aaguid,
 	)
 	var i WebauthnCredential
 	err := row.Scan(
@@ -142,6 +184,7 @@ func (q *Queries) InsertCredential(ctx context.Context, arg InsertCredentialPara
 		&i.Authenticator,
 		&i.Attestation,
 		&i.Meta,
+		&i.Aaguid,
 	)
 	return i, err
 }
@@ -179,8 +222,68 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (Webauth
 	return i, err
 }
 
+const listActiveCredentials = `-- name: ListActiveCredentials :many
+SELECT webauthn_credentials.credential_id, webauthn_credentials.user_id, webauthn_credentials.use_counter, webauthn_credentials.public_key, webauthn_credentials.attestation_type, webauthn_credentials.transport, webauthn_credentials.flags, webauthn_credentials.authenticator, webauthn_credentials.attestation, webauthn_credentials.meta, webauthn_credentials.aaguid, webauthn_users._id, webauthn_users.ref_id, webauthn_users.raw_id, webauthn_users.name, webauthn_users.display_name
+FROM webauthn_credentials
+INNER JOIN webauthn_users ON webauthn_credentials.user_id = webauthn_users._id
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_users._id
+WHERE meta->>'status' = 'active'
+AND COALESCE(user_tenants.tenant, '') = $1
+AND credential_id > $2
+ORDER BY credential_id
+LIMIT $3
+`
+
+type ListActiveCredentialsParams struct {
+	Tenant   string
+	AfterID  []byte
+	RowLimit int32
+}
+
+type ListActiveCredentialsRow struct {
+	WebauthnCredential WebauthnCredential
+	WebauthnUser       WebauthnUser
+}
+
+func (q *Queries) ListActiveCredentials(ctx context.Context, arg ListActiveCredentialsParams) ([]ListActiveCredentialsRow, error) {
+	rows, err := q.db.Query(ctx, listActiveCredentials, arg.Tenant, arg.AfterID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveCredentialsRow
+	for rows.Next() {
+		var i ListActiveCredentialsRow
+		if err := rows.Scan(
+			&i.WebauthnCredential.CredentialID,
+			&i.WebauthnCredential.UserID,
+			&i.WebauthnCredential.UseCounter,
+			&i.WebauthnCredential.PublicKey,
+			&i.WebauthnCredential.AttestationType,
+			&i.WebauthnCredential.Transport,
+			&i.WebauthnCredential.Flags,
+			&i.WebauthnCredential.Authenticator,
+			&i.WebauthnCredential.Attestation,
+			&i.WebauthnCredential.Meta,
+			&i.WebauthnCredential.Aaguid,
+			&i.WebauthnUser.ID,
+			&i.WebauthnUser.RefID,
+			&i.WebauthnUser.RawID,
+			&i.WebauthnUser.Name,
+			&i.WebauthnUser.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listActiveCredentialsByUser = `-- name: ListActiveCredentialsByUser :many
-SELECT credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta
+SELECT credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
 FROM webauthn_credentials
 WHERE user_id = $1
 AND meta->>'status' = 'active'
@@ -207,6 +310,7 @@ func (q *Queries) ListActiveCredentialsByUser(ctx context.Context, userID pgtype
 			&i.Authenticator,
 			&i.Attestation,
 			&i.Meta,
+			&i.Aaguid,
 		); err != nil {
 			return nil, err
 		}
@@ -219,7 +323,7 @@ func (q *Queries) ListActiveCredentialsByUser(ctx context.Context, userID pgtype
 }
 
 const listAllCredentialsByUser = `-- name: ListAllCredentialsByUser :many
-SELECT credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta
+SELECT credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
 FROM webauthn_credentials
 WHERE user_id = $1
 ORDER BY credential_id
@@ -245,6 +349,114 @@ func (q *Queries) ListAllCredentialsByUser(ctx context.Context, userID pgtype.In
 			&i.Authenticator,
 			&i.Attestation,
 			&i.Meta,
+			&i.Aaguid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnrevokedCredentialsByAaguid = `-- name: ListUnrevokedCredentialsByAaguid :many
+SELECT webauthn_credentials.credential_id, webauthn_credentials.user_id, webauthn_credentials.use_counter, webauthn_credentials.public_key, webauthn_credentials.attestation_type, webauthn_credentials.transport, webauthn_credentials.flags, webauthn_credentials.authenticator, webauthn_credentials.attestation, webauthn_credentials.meta, webauthn_credentials.aaguid, webauthn_users._id, webauthn_users.ref_id, webauthn_users.raw_id, webauthn_users.name, webauthn_users.display_name
+FROM webauthn_credentials
+INNER JOIN webauthn_users ON webauthn_credentials.user_id = webauthn_users._id
+LEFT JOIN user_tenants ON user_tenants.user_id = webauthn_users._id
+WHERE aaguid = $1
+AND meta->>'status' <> 'revoked'
+AND ($2::VARCHAR IS NULL OR COALESCE(user_tenants.tenant, '') = $2)
+AND credential_id > $3
+ORDER BY credential_id
+LIMIT $4
+`
+
+type ListUnrevokedCredentialsByAaguidParams struct {
+	Aaguid   []byte
+	Tenant   pgtype.Text
+	AfterID  []byte
+	RowLimit int32
+}
+
+type ListUnrevokedCredentialsByAaguidRow struct {
+	WebauthnCredential WebauthnCredential
+	WebauthnUser       WebauthnUser
+}
+
+func (q *Queries) ListUnrevokedCredentialsByAaguid(ctx context.Context, arg ListUnrevokedCredentialsByAaguidParams) ([]ListUnrevokedCredentialsByAaguidRow, error) {
+	rows, err := q.db.Query(ctx, listUnrevokedCredentialsByAaguid,
+		arg.Aaguid,
+		arg.Tenant,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnrevokedCredentialsByAaguidRow
+	for rows.Next() {
+		var i ListUnrevokedCredentialsByAaguidRow
+		if err := rows.Scan(
+			&i.WebauthnCredential.CredentialID,
+			&i.WebauthnCredential.UserID,
+			&i.WebauthnCredential.UseCounter,
+			&i.WebauthnCredential.PublicKey,
+			&i.WebauthnCredential.AttestationType,
+			&i.WebauthnCredential.Transport,
+			&i.WebauthnCredential.Flags,
+			&i.WebauthnCredential.Authenticator,
+			&i.WebauthnCredential.Attestation,
+			&i.WebauthnCredential.Meta,
+			&i.WebauthnCredential.Aaguid,
+			&i.WebauthnUser.ID,
+			&i.WebauthnUser.RefID,
+			&i.WebauthnUser.RawID,
+			&i.WebauthnUser.Name,
+			&i.WebauthnUser.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnrevokedCredentialsByUser = `-- name: ListUnrevokedCredentialsByUser :many
+SELECT credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
+FROM webauthn_credentials
+WHERE user_id = $1
+AND meta->>'status' <> 'revoked'
+ORDER BY credential_id
+`
+
+func (q *Queries) ListUnrevokedCredentialsByUser(ctx context.Context, userID pgtype.Int8) ([]WebauthnCredential, error) {
+	rows, err := q.db.Query(ctx, listUnrevokedCredentialsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebauthnCredential
+	for rows.Next() {
+		var i WebauthnCredential
+		if err := rows.Scan(
+			&i.CredentialID,
+			&i.UserID,
+			&i.UseCounter,
+			&i.PublicKey,
+			&i.AttestationType,
+			&i.Transport,
+			&i.Flags,
+			&i.Authenticator,
+			&i.Attestation,
+			&i.Meta,
+			&i.Aaguid,
 		); err != nil {
 			return nil, err
 		}
@@ -256,13 +468,15 @@ func (q *Queries) ListAllCredentialsByUser(ctx context.Context, userID pgtype.In
 	return items, nil
 }
 
-const listUsers = `-- name: ListUsers :many
+const listUsersByName = `-- name: ListUsersByName :many
 SELECT _id, ref_id, raw_id, name, display_name
 FROM webauthn_users
+WHERE "name" = $1
+ORDER BY _id
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]WebauthnUser, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+func (q *Queries) ListUsersByName(ctx context.Context, name string) ([]WebauthnUser, error) {
+	rows, err := q.db.Query(ctx, listUsersByName, name)
 	if err != nil {
 		return nil, err
 	}
@@ -287,6 +501,163 @@ func (q *Queries) ListUsers(ctx context.Context) ([]WebauthnUser, error) {
 	return items, nil
 }
 
+const lockUser = `-- name: LockUser :one
+SELECT _id
+FROM webauthn_users
+WHERE _id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, ID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, lockUser, ID)
+	var _id int64
+	err := row.Scan(&_id)
+	return _id, err
+}
+
+const replaceCredential = `-- name: ReplaceCredential :one
+UPDATE webauthn_credentials
+SET public_key = $2, attestation_type = $3, transport = $4, flags = $5, authenticator = $6, attestation = $7, meta = $8, aaguid = $9, use_counter = GREATEST(use_counter, $10)
+WHERE credential_id = $1
+RETURNING credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
+`
+
+type ReplaceCredentialParams struct {
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType pgtype.Text
+	Transport       []byte
+	Flags           []byte
+	Authenticator   []byte
+	Attestation     []byte
+	Meta            []byte
+	Aaguid          []byte
+	UseCounter      int32
+}
+
+func (q *Queries) ReplaceCredential(ctx context.Context, arg ReplaceCredentialParams) (WebauthnCredential, error) {
+	row := q.db.QueryRow(ctx, replaceCredential,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.AttestationType,
+		arg.Transport,
+		arg.Flags,
+		arg.Authenticator,
+		arg.Attestation,
+		arg.Meta,
+		arg.Aaguid,
+		arg.UseCounter,
+	)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.CredentialID,
+		&i.UserID,
+		&i.UseCounter,
+		&i.PublicKey,
+		&i.AttestationType,
+		&i.Transport,
+		&i.Flags,
+		&i.Authenticator,
+		&i.Attestation,
+		&i.Meta,
+		&i.Aaguid,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT _id, ref_id, raw_id, name, display_name
+FROM webauthn_users
+WHERE (
+    $1::VARCHAR IS NULL
+    OR ref_id ILIKE '%' || $1 || '%' ESCAPE '\'
+    OR "name" ILIKE '%' || $1 || '%' ESCAPE '\'
+    OR display_name ILIKE '%' || $1 || '%' ESCAPE '\'
+)
+AND _id > $2
+ORDER BY _id
+LIMIT $3
+`
+
+type SearchUsersParams struct {
+	Query    pgtype.Text
+	AfterID  int64
+	RowLimit int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]WebauthnUser, error) {
+	rows, err := q.db.Query(ctx, searchUsers, arg.Query, arg.AfterID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebauthnUser
+	for rows.Next() {
+		var i WebauthnUser
+		if err := rows.Scan(
+			&i.ID,
+			&i.RefID,
+			&i.RawID,
+			&i.Name,
+			&i.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setCredentialUseCounter = `-- name: SetCredentialUseCounter :exec
+UPDATE webauthn_credentials
+SET use_counter = $2
+WHERE credential_id = $1
+`
+
+type SetCredentialUseCounterParams struct {
+	CredentialID []byte
+	UseCounter   int32
+}
+
+func (q *Queries) SetCredentialUseCounter(ctx context.Context, arg SetCredentialUseCounterParams) error {
+	_, err := q.db.Exec(ctx, setCredentialUseCounter, arg.CredentialID, arg.UseCounter)
+	return err
+}
+
+const updateCredentialMeta = `-- name: UpdateCredentialMeta :one
+UPDATE webauthn_credentials
+SET meta = $2
+WHERE credential_id = $1
+RETURNING credential_id, user_id, use_counter, public_key, attestation_type, transport, flags, authenticator, attestation, meta, aaguid
+`
+
+type UpdateCredentialMetaParams struct {
+	CredentialID []byte
+	Meta         []byte
+}
+
+func (q *Queries) UpdateCredentialMeta(ctx context.Context, arg UpdateCredentialMetaParams) (WebauthnCredential, error) {
+	row := q.db.QueryRow(ctx, updateCredentialMeta, arg.CredentialID, arg.Meta)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.CredentialID,
+		&i.UserID,
+		&i.UseCounter,
+		&i.PublicKey,
+		&i.AttestationType,
+		&i.Transport,
+		&i.Flags,
+		&i.Authenticator,
+		&i.Attestation,
+		&i.Meta,
+		&i.Aaguid,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE webauthn_users
 SET "name" = $2, display_name = $3
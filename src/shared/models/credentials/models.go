@@ -8,6 +8,79 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AdminCredential struct {
+	AdminUserID  int64
+	CredentialID []byte
+	EnrolledBy   pgtype.Text
+	EnrolledAt   pgtype.Timestamptz
+}
+
+type AdminRole struct {
+	ID          int64
+	AdminUserID int64
+	Tenant      string
+	Role        string
+	GrantedBy   pgtype.Text
+	GrantedAt   pgtype.Timestamptz
+}
+
+type AdminUser struct {
+	ID        int64
+	UserRef   string
+	Active    bool
+	CreatedAt pgtype.Timestamptz
+}
+
+type ApiKey struct {
+	ID         int64
+	Name       string
+	Scope      string
+	KeyHash    []byte
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
+
+type AuditEvent struct {
+	ID           int64
+	OccurredAt   pgtype.Timestamptz
+	EventType    string
+	UserID       pgtype.Int8
+	UserRef      pgtype.Text
+	CredentialID []byte
+	IpAddress    pgtype.Text
+	UserAgent    pgtype.Text
+	Tenant       pgtype.Text
+	RequestID    pgtype.Text
+	Reason       pgtype.Text
+	Details      []byte
+}
+
+type DeviceInventory struct {
+	Tenant     string
+	Serial     string
+	Label      string
+	UploadedAt pgtype.Timestamptz
+}
+
+type UserProvisioning struct {
+	UserID    int64
+	Active    bool
+	UpdatedAt pgtype.Timestamptz
+}
+
+type UserRecoveryMethod struct {
+	UserID    int64
+	Method    string
+	CreatedAt pgtype.Timestamptz
+}
+
+type UserTenant struct {
+	UserID    int64
+	Tenant    string
+	CreatedAt pgtype.Timestamptz
+}
+
 type WebauthnCredential struct {
 	CredentialID    []byte
 	UserID          pgtype.Int8
@@ -19,6 +92,7 @@ type WebauthnCredential struct {
 	Authenticator   []byte
 	Attestation     []byte
 	Meta            []byte
+	Aaguid          []byte
 }
 
 type WebauthnUser struct {
@@ -28,3 +102,25 @@ type WebauthnUser struct {
 	Name        string
 	DisplayName string
 }
+
+type WebhookOutbox struct {
+	ID             int64
+	SubscriptionID int64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	DeliveredAt    pgtype.Timestamptz
+}
+
+type WebhookSubscription struct {
+	ID         int64
+	Url        string
+	Secret     string
+	EventTypes []byte
+	Active     bool
+	CreatedAt  pgtype.Timestamptz
+}
@@ -0,0 +1,87 @@
+package admin_service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+// AdminService manages who is allowed to use the admin service
+type AdminService struct {
+	ctx     context.Context
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new AdminService instance
+func New(ctx context.Context) (*AdminService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminService{
+		ctx:     ctx,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+// IsAllowed reports whether the user with the provided reference is an active administrator
+func (s *AdminService) IsAllowed(userRef string) (bool, error) {
+	_, err := s.queries.GetActiveAdminUser(s.ctx, userRef)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+
+	return true, nil
+}
+
+// SetAllowed adds the user with the provided reference to the allowlist, or deactivates them
+func (s *AdminService) SetAllowed(userRef string, allowed bool) error {
+	if _, err := s.queries.UpsertAdminUser(s.ctx, credentials.UpsertAdminUserParams{
+		UserRef: userRef,
+		Active:  allowed,
+	}); err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	return nil
+}
+
+// ListCredentialIDs returns the passkeys the administrator may sign in to the admin service with
+func (s *AdminService) ListCredentialIDs(userRef string) ([][]byte, error) {
+	ids, err := s.queries.ListAdminCredentialIDs(s.ctx, userRef)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return ids, nil
+}
+
+// EnrollCredential lets the administrator sign in with one of their passkeys.
+// Returns pgx.ErrNoRows when the user is not on the admin allowlist or the credential is not theirs.
+func (s *AdminService) EnrollCredential(userRef string, credentialID []byte, enrolledBy string) error {
+	enrolled, err := s.queries.UpsertAdminCredential(s.ctx, credentials.UpsertAdminCredentialParams{
+		EnrolledBy:   pgtype.Text{String: enrolledBy, Valid: enrolledBy != ""},
+		UserRef:      userRef,
+		CredentialID: credentialID,
+	})
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	if enrolled == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
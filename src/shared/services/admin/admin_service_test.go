@@ -0,0 +1,141 @@
+package admin_service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+var createdAt = pgtype.Timestamptz{Time: time.Date(2024, 10, 21, 12, 0, 0, 0, time.UTC), Valid: true}
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func TestAdminService_IsAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     *pgxpoolmock.Row
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "Active admin",
+			row:  pgxpoolmock.NewRow(int64(1), "admin", true, createdAt),
+			want: true,
+		},
+		{
+			name: "Not on the allowlist",
+			row:  pgxpoolmock.NewRow(int64(0), "", false, pgtype.Timestamptz{}).WithError(pgx.ErrNoRows),
+			want: false,
+		},
+		{
+			name:    "Database error",
+			row:     pgxpoolmock.NewRow(int64(0), "", false, pgtype.Timestamptz{}).WithError(errors.New("boom")),
+			want:    false,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM admin_users.*active = TRUE)"), "admin").Return(tt.row)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.IsAllowed("admin")
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IsAllowed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminService_SetAllowed(t *testing.T) {
+	// Given
+	setupTest(t)
+	mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO admin_users.*ON CONFLICT)"), "admin", false).Return(
+		pgxpoolmock.NewRow(int64(1), "admin", false, createdAt),
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	err = s.SetAllowed("admin", false)
+
+	// Then
+	if err != nil {
+		t.Errorf("SetAllowed() error = %v, want nil", err)
+	}
+}
+
+func TestAdminService_EnrollCredential(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		wantErr error
+	}{
+		{
+			name: "Enrolled",
+			tag:  "INSERT 0 1",
+		},
+		{
+			name:    "Not an admin or not their credential",
+			tag:     "INSERT 0 0",
+			wantErr: pgx.ErrNoRows,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			enrolledBy := pgtype.Text{String: "owner", Valid: true}
+			mockPool.EXPECT().Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO admin_credentials.*webauthn_credentials.user_id = webauthn_users._id)"), enrolledBy, "admin", []byte("credential")).Return(pgconn.NewCommandTag(tt.tag), nil)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			err = s.EnrollCredential("admin", []byte("credential"), "owner")
+
+			// Then
+			if err != tt.wantErr {
+				t.Errorf("EnrollCredential() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package admin_service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+type Role string
+
+const (
+	RoleViewer        Role = "viewer"
+	RoleSupport       Role = "support"
+	RoleSecurityAdmin Role = "security-admin"
+	RoleOwner         Role = "owner"
+)
+
+// AllTenants grants a role for every tenant
+const AllTenants = "*"
+
+type Permission string
+
+const (
+	PermissionViewUsers          Permission = "users:view"
+	PermissionViewStats          Permission = "stats:view"
+	PermissionEditUsers          Permission = "users:edit"
+	PermissionDeleteUsers        Permission = "users:delete"
+	PermissionExportUsers        Permission = "users:export"
+	PermissionImportUsers        Permission = "users:import"
+	PermissionDisableCredentials Permission = "credentials:disable"
+	PermissionManageCredentials  Permission = "credentials:manage"
+	PermissionManageTenantPolicy Permission = "tenant-policy:manage"
+	PermissionManageRoles        Permission = "roles:manage"
+	PermissionManageApiKeys      Permission = "api-keys:manage"
+	PermissionViewAudit          Permission = "audit:view"
+	PermissionManageWebhooks     Permission = "webhooks:manage"
+)
+
+// Each role has every permission of the roles before it
+var roleOrder = []Role{RoleViewer, RoleSupport, RoleSecurityAdmin, RoleOwner}
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:        {PermissionViewUsers, PermissionViewStats},
+	RoleSupport:       {PermissionEditUsers, PermissionDisableCredentials},
+	RoleSecurityAdmin: {PermissionDeleteUsers, PermissionExportUsers, PermissionManageCredentials, PermissionManageTenantPolicy, PermissionViewAudit},
+	RoleOwner:         {PermissionImportUsers, PermissionManageRoles, PermissionManageApiKeys, PermissionManageWebhooks},
+}
+
+// RoleAssignment is a role granted to an administrator for a tenant
+type RoleAssignment struct {
+	Tenant    string    `json:"tenant"`
+	Role      Role      `json:"role"`
+	GrantedBy string    `json:"grantedBy,omitempty"`
+	GrantedAt time.Time `json:"grantedAt"`
+}
+
+// Roles are the roles of an administrator keyed by tenant
+type Roles map[string]Role
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role includes the permission
+func (r Role) Can(permission Permission) bool {
+	if !r.Valid() {
+		return false
+	}
+	for _, role := range roleOrder {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+		if role == r {
+			break
+		}
+	}
+	return false
+}
+
+// Can reports whether the administrator has the permission for the tenant, through either a tenant or an all tenants grant
+func (r Roles) Can(tenant string, permission Permission) bool {
+	if role, ok := r[tenant]; ok && role.Can(permission) {
+		return true
+	}
+	if role, ok := r[AllTenants]; ok && role.Can(permission) {
+		return true
+	}
+	return false
+}
+
+func roleAssignmentFromDatabase(row credentials.AdminRole) RoleAssignment {
+	return RoleAssignment{
+		Tenant:    row.Tenant,
+		Role:      Role(row.Role),
+		GrantedBy: row.GrantedBy.String,
+		GrantedAt: row.GrantedAt.Time,
+	}
+}
+
+// ListRoles returns every role granted to the administrator, inactive administrators have none
+func (s *AdminService) ListRoles(userRef string) ([]RoleAssignment, error) {
+	rows, err := s.queries.ListAdminRoles(s.ctx, userRef)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	assignments := make([]RoleAssignment, 0, len(rows))
+	for _, row := range rows {
+		assignments = append(assignments, roleAssignmentFromDatabase(row))
+	}
+	return assignments, nil
+}
+
+// GetRoles returns the roles of the administrator keyed by tenant, for permission checks
+func (s *AdminService) GetRoles(userRef string) (Roles, error) {
+	assignments, err := s.ListRoles(userRef)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(Roles, len(assignments))
+	for _, assignment := range assignments {
+		roles[assignment.Tenant] = assignment.Role
+	}
+	return roles, nil
+}
+
+// SetRole grants the role for the tenant, replacing any role the administrator already had there.
+// Returns pgx.ErrNoRows when the user is not on the admin allowlist.
+func (s *AdminService) SetRole(userRef string, tenant string, role Role, grantedBy string) (*RoleAssignment, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+
+	row, err := s.queries.UpsertAdminRole(s.ctx, credentials.UpsertAdminRoleParams{
+		Tenant:    tenant,
+		Role:      string(role),
+		GrantedBy: pgtype.Text{String: grantedBy, Valid: grantedBy != ""},
+		UserRef:   userRef,
+	})
+	if err == pgx.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	assignment := roleAssignmentFromDatabase(row)
+	return &assignment, nil
+}
+
+// RemoveRole takes away the role the administrator has for the tenant
+func (s *AdminService) RemoveRole(userRef string, tenant string) error {
+	deleted, err := s.queries.DeleteAdminRole(s.ctx, credentials.DeleteAdminRoleParams{
+		UserRef: userRef,
+		Tenant:  tenant,
+	})
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	if deleted == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
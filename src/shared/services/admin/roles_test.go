@@ -0,0 +1,179 @@
+package admin_service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var roleRows = []string{"_id", "admin_user_id", "tenant", "role", "granted_by", "granted_at"}
+
+func TestRole_Can(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{RoleViewer, PermissionViewUsers, true},
+		{RoleViewer, PermissionViewStats, true},
+		{RoleViewer, PermissionEditUsers, false},
+		{RoleSupport, PermissionViewUsers, true},
+		{RoleSupport, PermissionDisableCredentials, true},
+		{RoleSupport, PermissionManageCredentials, false},
+		{RoleSupport, PermissionDeleteUsers, false},
+		{RoleSupport, PermissionManageTenantPolicy, false},
+		{RoleSupport, PermissionViewAudit, false},
+		{RoleSecurityAdmin, PermissionDeleteUsers, true},
+		{RoleSecurityAdmin, PermissionViewAudit, true},
+		{RoleSecurityAdmin, PermissionManageTenantPolicy, true},
+		{RoleSecurityAdmin, PermissionExportUsers, true},
+		{RoleSecurityAdmin, PermissionImportUsers, false},
+		{RoleSecurityAdmin, PermissionManageRoles, false},
+		{RoleSecurityAdmin, PermissionManageWebhooks, false},
+		{RoleOwner, PermissionManageRoles, true},
+		{RoleOwner, PermissionManageWebhooks, true},
+		{RoleOwner, PermissionImportUsers, true},
+		{RoleOwner, PermissionViewUsers, true},
+		{Role("unknown"), PermissionViewUsers, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role)+"/"+string(tt.permission), func(t *testing.T) {
+			if got := tt.role.Can(tt.permission); got != tt.want {
+				t.Errorf("Role.Can() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoles_Can(t *testing.T) {
+	roles := Roles{
+		"acme":     RoleSupport,
+		AllTenants: RoleViewer,
+	}
+
+	if !roles.Can("acme", PermissionDisableCredentials) {
+		t.Errorf("Roles.Can() = false, want true for the tenant role")
+	}
+	if !roles.Can("other", PermissionViewUsers) {
+		t.Errorf("Roles.Can() = false, want true through the all tenants role")
+	}
+	if roles.Can("other", PermissionDisableCredentials) {
+		t.Errorf("Roles.Can() = true, want false for a tenant without a role")
+	}
+}
+
+func TestAdminService_GetRoles(t *testing.T) {
+	// Given
+	setupTest(t)
+	mockPool.EXPECT().Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM admin_roles.*INNER JOIN admin_users.*)"), "admin").Return(
+		pgxpoolmock.NewRows(roleRows).
+			AddRow(int64(1), int64(1), AllTenants, "viewer", pgtype.Text{String: "owner", Valid: true}, createdAt).
+			AddRow(int64(2), int64(1), "acme", "security-admin", pgtype.Text{}, createdAt).
+			ToPgxRows(),
+		nil,
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	got, err := s.GetRoles("admin")
+
+	// Then
+	if err != nil {
+		t.Errorf("GetRoles() error = %v, want nil", err)
+	}
+	want := Roles{AllTenants: RoleViewer, "acme": RoleSecurityAdmin}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRoles() = %v, want %v", got, want)
+	}
+}
+
+func TestAdminService_SetRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    Role
+		setup   func()
+		wantErr error
+	}{
+		{
+			name: "Role granted",
+			role: RoleSupport,
+			setup: func() {
+				mockPool.EXPECT().QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:INSERT INTO admin_roles.*ON CONFLICT)"),
+					"acme", "support", pgtype.Text{String: "owner", Valid: true}, "admin",
+				).Return(pgxpoolmock.NewRow(int64(1), int64(1), "acme", "support", pgtype.Text{String: "owner", Valid: true}, createdAt))
+			},
+		},
+		{
+			name: "Not an administrator",
+			role: RoleSupport,
+			setup: func() {
+				mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					pgxpoolmock.NewRow(int64(0), int64(0), "", "", pgtype.Text{}, pgtype.Timestamptz{}).WithError(pgx.ErrNoRows),
+				)
+			},
+			wantErr: pgx.ErrNoRows,
+		},
+		{
+			name:  "Unknown role is rejected before querying",
+			role:  Role("admin"),
+			setup: func() {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			tt.setup()
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.SetRole("admin", "acme", tt.role, "owner")
+
+			// Then
+			if !tt.role.Valid() {
+				if err == nil {
+					t.Errorf("SetRole() error = nil, want error for unknown role")
+				}
+				return
+			}
+			if err != tt.wantErr {
+				t.Errorf("SetRole() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (got == nil || got.Role != tt.role || got.GrantedBy != "owner") {
+				t.Errorf("SetRole() = %v, want role %v granted by owner", got, tt.role)
+			}
+		})
+	}
+}
+
+func TestAdminService_RemoveRole(t *testing.T) {
+	// Given
+	setupTest(t)
+	mockPool.EXPECT().Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:DELETE FROM admin_roles.*)"), "admin", "acme").Return(pgconn.NewCommandTag("DELETE 0"), nil)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	err = s.RemoveRole("admin", "acme")
+
+	// Then
+	if err != pgx.ErrNoRows {
+		t.Errorf("RemoveRole() error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
@@ -0,0 +1,139 @@
+package api_key_service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+type Scope string
+
+const (
+	ScopeScim Scope = "scim"
+)
+
+const keyPrefix = "wak_"
+
+// ApiKey describes a key without the secret, which is only ever shown when it is created
+type ApiKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      Scope      `json:"scope"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ApiKeyService issues and checks bearer tokens for machine clients
+type ApiKeyService struct {
+	ctx     context.Context
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new ApiKeyService instance
+func New(ctx context.Context) (*ApiKeyService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApiKeyService{
+		ctx:     ctx,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+func hashKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+func apiKeyFromDatabase(row credentials.ApiKey) *ApiKey {
+	key := &ApiKey{
+		ID:        row.ID,
+		Name:      row.Name,
+		Scope:     Scope(row.Scope),
+		CreatedAt: row.CreatedAt.Time,
+	}
+	if row.LastUsedAt.Valid {
+		key.LastUsedAt = &row.LastUsedAt.Time
+	}
+	if row.RevokedAt.Valid {
+		key.RevokedAt = &row.RevokedAt.Time
+	}
+	return key
+}
+
+// Create issues a new key for the scope, the returned token must be handed to the client as it can not be recovered
+func (s *ApiKeyService) Create(name string, scope Scope) (string, *ApiKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	token := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	row, err := s.queries.InsertApiKey(s.ctx, credentials.InsertApiKeyParams{
+		Name:    name,
+		Scope:   string(scope),
+		KeyHash: hashKey(token),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return token, apiKeyFromDatabase(row), nil
+}
+
+// Verify returns the key for the token when it is valid for the scope and has not been revoked.
+// Returns pgx.ErrNoRows for unknown, revoked or out of scope tokens.
+func (s *ApiKeyService) Verify(token string, scope Scope) (*ApiKey, error) {
+	row, err := s.queries.GetActiveApiKey(s.ctx, credentials.GetActiveApiKeyParams{
+		KeyHash: hashKey(token),
+		Scope:   string(scope),
+	})
+	if err == pgx.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return apiKeyFromDatabase(row), nil
+}
+
+// List returns every key, including revoked ones
+func (s *ApiKeyService) List() ([]*ApiKey, error) {
+	rows, err := s.queries.ListApiKeys(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	keys := make([]*ApiKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyFromDatabase(row))
+	}
+	return keys, nil
+}
+
+// Revoke stops the key from being accepted, returns pgx.ErrNoRows when there is no active key with the ID
+func (s *ApiKeyService) Revoke(id int64) error {
+	revoked, err := s.queries.RevokeApiKey(s.ctx, id)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	if revoked == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
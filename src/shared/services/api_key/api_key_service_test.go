@@ -0,0 +1,119 @@
+package api_key_service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+var createdAt = pgtype.Timestamptz{Time: time.Date(2024, 11, 4, 12, 0, 0, 0, time.UTC), Valid: true}
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func TestApiKeyService_CreateAndVerify(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	var storedHash []byte
+	mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO api_keys.*)"), "hr", "scim", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, args ...interface{}) pgx.Row {
+			storedHash = args[2].([]byte)
+			return pgxpoolmock.NewRow(int64(1), "hr", "scim", storedHash, createdAt, pgtype.Timestamptz{}, pgtype.Timestamptz{})
+		},
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	token, key, err := s.Create("hr", ScopeScim)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+	if !strings.HasPrefix(token, keyPrefix) {
+		t.Errorf("Create() token = %v, want prefix %v", token, keyPrefix)
+	}
+	if strings.Contains(string(storedHash), token) {
+		t.Errorf("Create() stored the token in plain text")
+	}
+	if key.ID != 1 || key.Scope != ScopeScim || key.LastUsedAt != nil {
+		t.Errorf("Create() key = %+v, want id 1 with scim scope and never used", key)
+	}
+
+	// The token verifies against the hash that was stored
+	mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE api_keys.*revoked_at IS NULL)"), storedHash, "scim").Return(
+		pgxpoolmock.NewRow(int64(1), "hr", "scim", storedHash, createdAt, createdAt, pgtype.Timestamptz{}),
+	)
+	verified, err := s.Verify(token, ScopeScim)
+	if err != nil {
+		t.Errorf("Verify() error = %v, want nil", err)
+	}
+	if verified == nil || verified.LastUsedAt == nil {
+		t.Errorf("Verify() key = %+v, want last used to be set", verified)
+	}
+}
+
+func TestApiKeyService_Verify_Unknown(t *testing.T) {
+	// Given
+	setupTest(t)
+	mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Any(), gomock.Any(), "scim").Return(
+		pgxpoolmock.NewRow(int64(0), "", "", []byte{}, pgtype.Timestamptz{}, pgtype.Timestamptz{}, pgtype.Timestamptz{}).WithError(pgx.ErrNoRows),
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	_, err = s.Verify("wak_unknown", ScopeScim)
+
+	// Then
+	if err != pgx.ErrNoRows {
+		t.Errorf("Verify() error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
+
+func TestApiKeyService_Revoke(t *testing.T) {
+	// Given
+	setupTest(t)
+	mockPool.EXPECT().Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SET revoked_at = NOW.*)"), int64(3)).Return(pgconn.NewCommandTag("UPDATE 0"), nil)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	err = s.Revoke(3)
+
+	// Then
+	if err != pgx.ErrNoRows {
+		t.Errorf("Revoke() error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
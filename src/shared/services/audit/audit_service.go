@@ -0,0 +1,181 @@
+package audit_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+type EventType string
+
+const (
+	EventCredentialRegistered    EventType = "credential.registered"
+	EventRegistrationFailed      EventType = "registration.failed"
+	EventAuthenticationSucceeded EventType = "authentication.succeeded"
+	EventAuthenticationFailed    EventType = "authentication.failed"
+	EventCredentialStatusChanged EventType = "credential.status_changed"
+	EventCredentialRenamed       EventType = "credential.renamed"
+	EventCloneWarning            EventType = "credential.clone_warning"
+	EventAdminAction             EventType = "admin.action"
+	EventAdminLogin              EventType = "admin.login"
+	EventAdminLoginFailed        EventType = "admin.login_failed"
+	EventAdminRoleChanged        EventType = "admin.role_changed"
+	EventTransactionConfirmed    EventType = "transaction.confirmed"
+)
+
+const defaultListLimit = 100
+const maxListLimit = 1000
+
+// Event is a single security relevant occurrence recorded in the audit log
+type Event struct {
+	ID           int64                     `json:"id"`
+	OccurredAt   time.Time                 `json:"occurredAt"`
+	Type         EventType                 `json:"type"`
+	UserID       int64                     `json:"-"`
+	UserRef      string                    `json:"userRef,omitempty"`
+	CredentialID protocol.URLEncodedBase64 `json:"credentialId,omitempty"`
+	IPAddress    string                    `json:"ipAddress,omitempty"`
+	UserAgent    string                    `json:"userAgent,omitempty"`
+	Tenant       string                    `json:"tenant,omitempty"`
+	RequestID    string                    `json:"requestId,omitempty"`
+	Reason       string                    `json:"reason,omitempty"`
+	Details      map[string]any            `json:"details,omitempty"`
+}
+
+// Filter narrows down the events returned by List
+type Filter struct {
+	UserRef string
+	Tenant  string
+	From    time.Time
+	To      time.Time
+	AfterID int64
+	Limit   int
+}
+
+// AuditService records and queries the append-only audit log
+type AuditService struct {
+	ctx     context.Context
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new AuditService instance
+func New(ctx context.Context) (*AuditService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditService{
+		ctx:     ctx,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+func optionalText(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
+
+func eventFromDatabase(row credentials.AuditEvent) (*Event, error) {
+	event := &Event{
+		ID:           row.ID,
+		OccurredAt:   row.OccurredAt.Time,
+		Type:         EventType(row.EventType),
+		UserID:       row.UserID.Int64,
+		UserRef:      row.UserRef.String,
+		CredentialID: row.CredentialID,
+		IPAddress:    row.IpAddress.String,
+		UserAgent:    row.UserAgent.String,
+		Tenant:       row.Tenant.String,
+		RequestID:    row.RequestID.String,
+		Reason:       row.Reason.String,
+	}
+	if len(row.Details) > 0 {
+		if err := json.Unmarshal(row.Details, &event.Details); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Details: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// Record appends the event to the audit log, filling in the ID and timestamp
+func (s *AuditService) Record(event *Event) error {
+	if event.Type == "" {
+		return fmt.Errorf("event type is required")
+	}
+
+	var details []byte
+	if len(event.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return fmt.Errorf("failed to marshal Details: %w", err)
+		}
+	}
+
+	row, err := s.queries.InsertAuditEvent(s.ctx, credentials.InsertAuditEventParams{
+		EventType:    string(event.Type),
+		UserID:       pgtype.Int8{Int64: event.UserID, Valid: event.UserID != 0},
+		UserRef:      optionalText(event.UserRef),
+		CredentialID: event.CredentialID,
+		IpAddress:    optionalText(event.IPAddress),
+		UserAgent:    optionalText(event.UserAgent),
+		Tenant:       optionalText(event.Tenant),
+		RequestID:    optionalText(event.RequestID),
+		Reason:       optionalText(event.Reason),
+		Details:      details,
+	})
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	event.ID = row.ID
+	event.OccurredAt = row.OccurredAt.Time
+	return nil
+}
+
+// List returns the events matching the filter ordered by ID, use the last ID as AfterID to fetch the next page
+func (s *AuditService) List(filter Filter) ([]Event, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	} else if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	rows, err := s.queries.ListAuditEvents(s.ctx, credentials.ListAuditEventsParams{
+		UserRef:  optionalText(filter.UserRef),
+		Tenant:   optionalText(filter.Tenant),
+		FromTime: pgtype.Timestamptz{Time: filter.From, Valid: true},
+		ToTime:   pgtype.Timestamptz{Time: to, Valid: true},
+		AfterID:  filter.AfterID,
+		RowLimit: int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		event, err := eventFromDatabase(row)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	return events, nil
+}
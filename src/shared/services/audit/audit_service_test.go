@@ -0,0 +1,194 @@
+package audit_service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+var auditRows = []string{"_id", "occurred_at", "event_type", "user_id", "user_ref", "credential_id", "ip_address", "user_agent", "tenant", "request_id", "reason", "details"}
+
+var occurredAt = time.Date(2024, 10, 7, 12, 0, 0, 0, time.UTC)
+
+func text(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
+
+func mockAuditRow(id int64, eventType EventType, userRef string, reason string, details string) []interface{} {
+	return []interface{}{
+		id, // _id
+		pgtype.Timestamptz{Time: occurredAt, Valid: true}, // occurred_at
+		string(eventType),                  // event_type
+		pgtype.Int8{Int64: 1, Valid: true}, // user_id
+		text(userRef),                      // user_ref
+		[]byte("cred"),                     // credential_id
+		text("10.0.0.1"),                   // ip_address
+		text("agent"),                      // user_agent
+		text("tenant"),                     // tenant
+		text("request"),                    // request_id
+		text(reason),                       // reason
+		[]byte(details),                    // details
+	}
+}
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func TestAuditService_Record(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mockPool.EXPECT().QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:INSERT INTO audit_events.*)"),
+		string(EventAuthenticationFailed),
+		pgtype.Int8{Int64: 1, Valid: true},
+		text("user-ref"),
+		[]byte("cred"),
+		text("10.0.0.1"),
+		text("agent"),
+		text("tenant"),
+		text("request"),
+		text("bad signature"),
+		[]byte(`{"attempt":2}`),
+	).Return(
+		pgxpoolmock.NewRow(mockAuditRow(5, EventAuthenticationFailed, "user-ref", "bad signature", `{"attempt":2}`)...),
+	)
+
+	event := &Event{
+		Type:         EventAuthenticationFailed,
+		UserID:       1,
+		UserRef:      "user-ref",
+		CredentialID: []byte("cred"),
+		IPAddress:    "10.0.0.1",
+		UserAgent:    "agent",
+		Tenant:       "tenant",
+		RequestID:    "request",
+		Reason:       "bad signature",
+		Details:      map[string]any{"attempt": 2},
+	}
+
+	// When
+	service, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	err = service.Record(event)
+
+	// Then
+	if err != nil {
+		t.Errorf("Record() error = %v, want nil", err)
+	}
+	if event.ID != 5 {
+		t.Errorf("Record() ID = %v, want %v", event.ID, 5)
+	}
+	if !event.OccurredAt.Equal(occurredAt) {
+		t.Errorf("Record() OccurredAt = %v, want %v", event.OccurredAt, occurredAt)
+	}
+}
+
+func TestAuditService_Record_MissingType(t *testing.T) {
+	setupTest(t)
+
+	service, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	if err := service.Record(&Event{UserRef: "user-ref"}); err == nil {
+		t.Errorf("Record() error = nil, want not nil")
+	}
+}
+
+func TestAuditService_List(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantLimit int32
+		wantUser  pgtype.Text
+	}{
+		{
+			name:      "Default limit",
+			filter:    Filter{UserRef: "user-ref"},
+			wantLimit: defaultListLimit,
+			wantUser:  text("user-ref"),
+		},
+		{
+			name:      "Limit capped",
+			filter:    Filter{Limit: 5000},
+			wantLimit: maxListLimit,
+			wantUser:  text(""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			mockPool.EXPECT().Query(
+				gomock.Any(),
+				pgxpoolmock.QueryContains("(?ms:SELECT.*FROM audit_events.*ORDER BY _id)"),
+				tt.wantUser,
+				text(""),
+				gomock.Any(),
+				gomock.Any(),
+				int64(0),
+				tt.wantLimit,
+			).Return(
+				pgxpoolmock.NewRows(auditRows).AddRow(
+					mockAuditRow(1, EventAuthenticationSucceeded, "user-ref", "", "")...,
+				).ToPgxRows(),
+				nil,
+			)
+
+			expected := []Event{{
+				ID:           1,
+				OccurredAt:   occurredAt,
+				Type:         EventAuthenticationSucceeded,
+				UserID:       1,
+				UserRef:      "user-ref",
+				CredentialID: []byte("cred"),
+				IPAddress:    "10.0.0.1",
+				UserAgent:    "agent",
+				Tenant:       "tenant",
+				RequestID:    "request",
+			}}
+
+			// When
+			service, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			events, err := service.List(tt.filter)
+
+			// Then
+			if err != nil {
+				t.Errorf("List() error = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(events, expected) {
+				t.Errorf("List() = %v, want %v", events, expected)
+			}
+		})
+	}
+}
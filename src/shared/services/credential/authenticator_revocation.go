@@ -0,0 +1,153 @@
+package credential_service
+
+import (
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	webhook_service "blacksmithlabs.dev/webauthn-k8s/shared/services/webhook"
+)
+
+const defaultRevokeBatchSize = 100
+const maxRevokeBatchSize = 1000
+
+// AllTenants has the authenticator queries span the users of every tenant, like the wildcard of the admin roles
+const AllTenants = "*"
+
+// AaguidUsage counts the credentials of an authenticator model that are not revoked yet and the users they belong to
+type AaguidUsage struct {
+	Credentials int64
+	Users       int64
+}
+
+func clampRevokeBatchSize(limit int) int {
+	if limit <= 0 {
+		return defaultRevokeBatchSize
+	} else if limit > maxRevokeBatchSize {
+		return maxRevokeBatchSize
+	}
+	return limit
+}
+
+// tenantFilter matches the users bound to the tenant, unbound users belong to the default tenant ""
+func tenantFilter(tenant string) pgtype.Text {
+	if tenant == AllTenants {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: tenant, Valid: true}
+}
+
+func credentialsByAaguidFromDatabase(rows []credentials.ListUnrevokedCredentialsByAaguidRow) ([]*CredentialModel, error) {
+	models := make([]*CredentialModel, 0, len(rows))
+	for _, row := range rows {
+		credential, err := CredentialModelFromDatabase(row.WebauthnCredential)
+		if err != nil {
+			return nil, err
+		}
+		credential.SetUser(UserModelFromDatabase(row.WebauthnUser))
+		models = append(models, credential)
+	}
+	return models, nil
+}
+
+// CountCredentialsByAaguid counts the credentials of the authenticator model that are not revoked yet among the users
+// of the tenant, or of every tenant with AllTenants
+func (s *CredentialService) CountCredentialsByAaguid(aaguid []byte, tenant string) (*AaguidUsage, error) {
+	row, err := s.queries.CountUnrevokedCredentialsByAaguid(s.ctx, credentials.CountUnrevokedCredentialsByAaguidParams{
+		Aaguid: aaguid,
+		Tenant: tenantFilter(tenant),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return &AaguidUsage{Credentials: row.CredentialCount, Users: row.UserCount}, nil
+}
+
+// ListCredentialsByAaguid returns a page of the credentials of the authenticator model that are not revoked yet, with
+// their users of the tenant, ordered by credential ID. Pass the ID of the last credential of the previous page as afterID to continue.
+func (s *CredentialService) ListCredentialsByAaguid(aaguid []byte, tenant string, afterID []byte, limit int) ([]*CredentialModel, error) {
+	if afterID == nil {
+		// A nil slice would be sent as NULL and match nothing
+		afterID = []byte{}
+	}
+
+	rows, err := s.queries.ListUnrevokedCredentialsByAaguid(s.ctx, credentials.ListUnrevokedCredentialsByAaguidParams{
+		Aaguid:   aaguid,
+		Tenant:   tenantFilter(tenant),
+		AfterID:  afterID,
+		RowLimit: int32(clampRevokeBatchSize(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return credentialsByAaguidFromDatabase(rows)
+}
+
+// RevokeCredentialBatchByAaguid revokes the next batch of credentials of the authenticator model among the users of the
+// tenant in one transaction and returns them, an empty batch means there is nothing left to revoke. When notify is set, webhook subscribers also get
+// one EventCredentialsRevokedNotice per user so the user can be told why their passkey stopped working.
+func (s *CredentialService) RevokeCredentialBatchByAaguid(aaguid []byte, tenant string, batchSize int, reason string, notify bool) ([]*CredentialModel, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	// Revoked credentials drop out of the query, so every batch starts from the beginning
+	rows, err := txn.ListUnrevokedCredentialsByAaguid(s.ctx, credentials.ListUnrevokedCredentialsByAaguidParams{
+		Aaguid:   aaguid,
+		Tenant:   tenantFilter(tenant),
+		AfterID:  []byte{},
+		RowLimit: int32(clampRevokeBatchSize(batchSize)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	revoked, err := credentialsByAaguidFromDatabase(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*UserModel
+	var events []dto.Event
+	credentialIDs := map[int64][]protocol.URLEncodedBase64{}
+	for _, credential := range revoked {
+		user := &credential.User.Value
+		statusEvents, err := s.setCredentialStatus(txn, user, credential, CredentialStatusRevoked)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, statusEvents...)
+		if _, ok := credentialIDs[user.ID]; !ok {
+			users = append(users, user)
+		}
+		credentialIDs[user.ID] = append(credentialIDs[user.ID], credential.ID)
+	}
+
+	if notify {
+		for _, user := range users {
+			event := webhook_service.NewEvent(webhook_service.EventCredentialsRevokedNotice, map[string]any{
+				"userId":        user.RefID,
+				"credentialIds": credentialIDs[user.ID],
+				"reason":        reason,
+			})
+			if err := webhook_service.EnqueueEvent(s.ctx, txn, event); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	s.publishEvents(events)
+
+	return revoked, nil
+}
@@ -0,0 +1,177 @@
+package credential_service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var aaguidRows = append(append([]string{}, credentialRows...), "_id", "ref_id", "raw_id", "name", "display_name")
+
+func mockAaguidRow(credentialId string, userId int64, userRef string) []any {
+	credential, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, _ := mockCredentialRow(credentialId, true, credentialId+"-nickname")
+	return []any{
+		credential, pgtype.Int8{Int64: userId, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, []byte("aaguid"),
+		userId, userRef, []byte(userRef), "name", "display",
+	}
+}
+
+func TestCredentialService_CountCredentialsByAaguid(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT COUNT.*FROM webauthn_credentials.*)"), []byte("aaguid"), pgtype.Text{}).Return(
+		pgxpoolmock.NewRow(int64(3), int64(2)),
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+	got, err := s.CountCredentialsByAaguid([]byte("aaguid"), AllTenants)
+
+	// Then
+	if err != nil {
+		t.Fatalf("CountCredentialsByAaguid() error = %v, want nil", err)
+	}
+	if got.Credentials != 3 || got.Users != 2 {
+		t.Errorf("CountCredentialsByAaguid() = %+v, want 3 credentials for 2 users", got)
+	}
+}
+
+func TestCredentialService_ListCredentialsByAaguid(t *testing.T) {
+	tests := []struct {
+		name      string
+		afterID   []byte
+		limit     int
+		wantAfter []byte
+		wantLimit int32
+	}{
+		{
+			name:      "First page with the default limit",
+			afterID:   nil,
+			limit:     0,
+			wantAfter: []byte{},
+			wantLimit: defaultRevokeBatchSize,
+		},
+		{
+			name:      "Next page with a clamped limit",
+			afterID:   []byte("c1"),
+			limit:     100000,
+			wantAfter: []byte("c1"),
+			wantLimit: maxRevokeBatchSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			mockPool.EXPECT().Query(
+				gomock.Any(),
+				pgxpoolmock.QueryContains("(?ms:FROM webauthn_credentials.*WHERE aaguid.*)"),
+				[]byte("aaguid"),
+				pgtype.Text{String: "bank", Valid: true},
+				tt.wantAfter,
+				tt.wantLimit,
+			).Return(
+				pgxpoolmock.NewRows(aaguidRows).
+					AddRow(mockAaguidRow("c2", 1, "u1")...).
+					AddRow(mockAaguidRow("c3", 2, "u2")...).
+					ToPgxRows(),
+				nil,
+			)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			got, err := s.ListCredentialsByAaguid([]byte("aaguid"), "bank", tt.afterID, tt.limit)
+
+			// Then
+			if err != nil {
+				t.Fatalf("ListCredentialsByAaguid() error = %v, want nil", err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("ListCredentialsByAaguid() returned %v credentials, want 2", len(got))
+			}
+			if !got[1].User.Loaded || got[1].User.Value.RefID != "u2" {
+				t.Errorf("ListCredentialsByAaguid() user = %+v, want loaded user u2", got[1].User)
+			}
+		})
+	}
+}
+
+func TestCredentialService_RevokeCredentialBatchByAaguid(t *testing.T) {
+	tests := []struct {
+		name        string
+		notify      bool
+		wantNotices int
+	}{
+		{name: "Revoke without notifying users", notify: false, wantNotices: 0},
+		{name: "Revoke and notify each user once", notify: true, wantNotices: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			mocker := mockPool.EXPECT()
+			mocker.Begin(gomock.Any()).Return(mockPool, nil)
+			mocker.Commit(gomock.Any()).Return(nil)
+			mocker.Rollback(gomock.Any()).Return(nil)
+			mocker.Query(
+				gomock.Any(),
+				pgxpoolmock.QueryContains("(?ms:FROM webauthn_credentials.*WHERE aaguid.*)"),
+				[]byte("aaguid"),
+				pgtype.Text{String: "", Valid: true},
+				[]byte{},
+				int32(2),
+			).Return(
+				pgxpoolmock.NewRows(aaguidRows).
+					AddRow(mockAaguidRow("c1", 1, "u1")...).
+					AddRow(mockAaguidRow("c2", 1, "u1")...).
+					AddRow(mockAaguidRow("c3", 2, "u2")...).
+					ToPgxRows(),
+				nil,
+			)
+			mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"), gomock.Any(), gomock.Any()).
+				Return(pgxpoolmock.NewRow(mockCredentialRow("c1", false, "c1-nickname"))).
+				Times(3)
+			mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"), "credential.status_changed", gomock.Any()).
+				Return(pgconn.NewCommandTag("INSERT 0 1"), nil).
+				Times(3)
+			if tt.wantNotices > 0 {
+				mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"), "user.credentials_revoked", gomock.Any()).
+					Return(pgconn.NewCommandTag("INSERT 0 1"), nil).
+					Times(tt.wantNotices)
+			}
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			got, err := s.RevokeCredentialBatchByAaguid([]byte("aaguid"), "", 2, "vendor advisory", tt.notify)
+
+			// Then
+			if err != nil {
+				t.Fatalf("RevokeCredentialBatchByAaguid() error = %v, want nil", err)
+			}
+			if len(got) != 3 {
+				t.Fatalf("RevokeCredentialBatchByAaguid() revoked %v credentials, want 3", len(got))
+			}
+			for _, credential := range got {
+				if credential.Meta.Status != CredentialStatusRevoked {
+					t.Errorf("RevokeCredentialBatchByAaguid() status = %v, want %v", credential.Meta.Status, CredentialStatusRevoked)
+				}
+			}
+		})
+	}
+}
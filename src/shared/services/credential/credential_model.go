@@ -3,9 +3,12 @@ package credential_service
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 
-	"blacksmithlabs.dev/webauthn-k8s/auth/utils"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
 	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	metadata_service "blacksmithlabs.dev/webauthn-k8s/shared/services/metadata"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -25,6 +28,18 @@ const (
 type CredentialMeta struct {
 	Status   CredentialStatus `json:"status"`
 	Nickname string           `json:"nickname"`
+	// AppID is the FIDO U2F AppID a legacy credential was registered under, assertions for it are scoped to the AppID instead of the RP ID
+	AppID string `json:"appId,omitempty"`
+	// DisabledReason is why the credential was disabled by the re-evaluation against the metadata and policy
+	DisabledReason string `json:"disabledReason,omitempty"`
+	// Serial is the device serial number from an enterprise attestation
+	Serial string `json:"serial,omitempty"`
+	// PRF is set when the registration asked for the prf extension
+	PRF *CredentialPRF `json:"prf,omitempty"`
+	// Discoverable is the credProps rk result of the registration, nil when the client did not report it
+	Discoverable *bool `json:"rk,omitempty"`
+	// LargeBlob is set when the registration asked for the largeBlob extension
+	LargeBlob *CredentialLargeBlob `json:"largeBlob,omitempty"`
 }
 
 type CredentialModel struct {
@@ -33,6 +48,15 @@ type CredentialModel struct {
 	Meta CredentialMeta
 }
 
+// AuthenticatorInfo names the authenticator model from the credential's AAGUID, nil when the model is unknown
+func (c *CredentialModel) AuthenticatorInfo() *dto.AuthenticatorInfo {
+	info, ok := metadata_service.DefaultResolver().Resolve(c.Authenticator.AAGUID)
+	if !ok {
+		return nil
+	}
+	return &info
+}
+
 func CredentialModelFromDatabase(credential credentials.WebauthnCredential) (*CredentialModel, error) {
 	var transport []protocol.AuthenticatorTransport
 	if err := json.Unmarshal(credential.Transport, &transport); err != nil {
@@ -81,6 +105,16 @@ func CredentialModelFromDatabase(credential credentials.WebauthnCredential) (*Cr
 	}, nil
 }
 
+// LogValue only exposes identifiers and status so key material stays out of the logs
+func (c CredentialModel) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", protocol.URLEncodedBase64(c.ID).String()),
+		slog.Int64("userId", c.User.Value.ID),
+		slog.String("status", string(c.Meta.Status)),
+		slog.String("attestationType", c.AttestationType),
+	)
+}
+
 func (c *CredentialModel) SetUser(user *UserModel) {
 	c.User.Loaded = true
 	c.User.Value = *user
@@ -122,5 +156,6 @@ func (c *CredentialModel) ToInsertParams() (*credentials.InsertCredentialParams,
 		Authenticator:   authenticatorJson,
 		Attestation:     attestationJson,
 		Meta:            metaJson,
+		Aaguid:          c.Authenticator.AAGUID,
 	}, nil
 }
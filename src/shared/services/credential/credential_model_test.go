@@ -0,0 +1,30 @@
+package credential_service
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestModels_LogValue(t *testing.T) {
+	credential := buildCredentialModel("credential-id", true, "nickname")
+	credential.PublicKey = []byte("public-key-bytes")
+	user := buildUserModel(1, "ref-id", "Secret Name", "Secret Display", credential)
+
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, nil))
+	logger.Info("test", "user", user, "credential", credential)
+
+	output := buffer.String()
+	for _, hidden := range []string{"Secret Name", "Secret Display", "public-key-bytes", "nickname"} {
+		if strings.Contains(output, hidden) {
+			t.Errorf("Log output contains %q: %s", hidden, output)
+		}
+	}
+	for _, shown := range []string{`"refId":"ref-id"`, `"credentials":1`, `"status":"active"`} {
+		if !strings.Contains(output, shown) {
+			t.Errorf("Log output is missing %q: %s", shown, output)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package credential_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	event_publisher "blacksmithlabs.dev/webauthn-k8s/shared/services/events"
+	webhook_service "blacksmithlabs.dev/webauthn-k8s/shared/services/webhook"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const defaultSearchLimit = 50
+const maxSearchLimit = 500
+
+var logger = utils.GetLogger()
+
+// CredentialService provides methods for interacting with user credentials
+type CredentialService struct {
+	ctx     context.Context
+	conn    database.DBConn
+	queries *credentials.Queries
+	events  event_publisher.EventPublisher
+	tenant  string
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new CredentialService instance
+func New(ctx context.Context) (*CredentialService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := credentials.New(pool)
+
+	return &CredentialService{
+		ctx:     ctx,
+		conn:    pool,
+		queries: queries,
+	}, nil
+}
+
+// WithEvents has the service publish stream events for the tenant once its changes are committed, such as
+// credentials that can no longer be used to log in
+func (s *CredentialService) WithEvents(publisher event_publisher.EventPublisher, tenant string) *CredentialService {
+	s.events = publisher
+	s.tenant = tenant
+	return s
+}
+
+// publishEvents sends the events of a committed change, failing to publish never fails the change
+func (s *CredentialService) publishEvents(events []dto.Event) {
+	if s.events == nil {
+		return
+	}
+	for _, event := range events {
+		if err := s.events.Publish(s.ctx, event, s.tenant); err != nil {
+			logger.Error("Failed to publish event", "error", err, "event", event.EventType())
+		}
+	}
+}
+
+// UpsertUser creates or updates a user in the database based on the provided user information from the DTO
+func (s *CredentialService) UpsertUser(userDto dto.RegistrationUserInfo) (*UserModel, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	user, err := txn.GetUserByRef(s.ctx, userDto.UserID)
+	if err == pgx.ErrNoRows {
+		rawId := make([]byte, 32)
+
+		if _, err := rand.Read(rawId); err != nil {
+			return nil, fmt.Errorf("failed to generate raw ID: %v", err)
+		}
+
+		// User does not exist, create a new user
+		user, err = txn.InsertUser(s.ctx, credentials.InsertUserParams{
+			RefID:       userDto.UserID,
+			RawID:       rawId,
+			Name:        userDto.UserName,
+			DisplayName: userDto.DisplayName,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return UserModelFromDatabase(user), nil
+}
+
+// GetUserByID retrieves a user from the database based on the provided ID
+func (s *CredentialService) GetUserByID(id int64) (*UserModel, error) {
+	user, err := s.queries.GetUserByID(s.ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return UserModelFromDatabase(user), nil
+}
+
+// GetUserByRef retrieves a user from the database based on the provided reference
+func (s *CredentialService) GetUserByRef(ref string) (*UserModel, error) {
+	user, err := s.queries.GetUserByRef(s.ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return UserModelFromDatabase(user), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes the LIKE wildcards in the query match themselves, the queries use \ as their escape character
+func escapeLike(query string) string {
+	return likeEscaper.Replace(query)
+}
+
+// SearchUsers returns a page of users whose reference, name or display name contains the query, ordered by ID.
+// Pass the ID of the last user of the previous page as afterID to continue from there.
+func (s *CredentialService) SearchUsers(query string, afterID int64, limit int) ([]*UserModel, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	} else if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	rows, err := s.queries.SearchUsers(s.ctx, credentials.SearchUsersParams{
+		Query:    pgtype.Text{String: escapeLike(query), Valid: query != ""},
+		AfterID:  afterID,
+		RowLimit: int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	users := make([]*UserModel, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, UserModelFromDatabase(row))
+	}
+
+	return users, nil
+}
+
+// UpdateUser renames the user with the provided reference
+func (s *CredentialService) UpdateUser(ref string, name string, displayName string) (*UserModel, error) {
+	user, err := s.queries.UpdateUser(s.ctx, credentials.UpdateUserParams{
+		RefID:       ref,
+		Name:        name,
+		DisplayName: displayName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return UserModelFromDatabase(user), nil
+}
+
+// ListUsersByName returns the users with exactly the provided name
+func (s *CredentialService) ListUsersByName(name string) ([]*UserModel, error) {
+	rows, err := s.queries.ListUsersByName(s.ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	users := make([]*UserModel, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, UserModelFromDatabase(row))
+	}
+
+	return users, nil
+}
+
+// IsUserActive reports whether the user has not been deprovisioned, users that were never provisioned are active
+func (s *CredentialService) IsUserActive(user *UserModel) (bool, error) {
+	provisioning, err := s.queries.GetUserProvisioning(s.ctx, user.ID)
+	if err == pgx.ErrNoRows {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+
+	return provisioning.Active, nil
+}
+
+// HasUnrevokedCredentials reports whether the user has any credential that is not revoked. Disabled and pending
+// credentials still protect the account, they can be enabled again, so only revoked ones do not count.
+func (s *CredentialService) HasUnrevokedCredentials(user *UserModel) (bool, error) {
+	userCredentials, err := s.queries.ListAllCredentialsByUser(s.ctx, user.PgID())
+	if err != nil && err != pgx.ErrNoRows {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+
+	for _, row := range userCredentials {
+		credential, err := CredentialModelFromDatabase(row)
+		if err != nil {
+			return false, fmt.Errorf("invalid credential: %w", err)
+		}
+		if credential.Meta.Status != CredentialStatusRevoked {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// BindTenant binds the user to the tenant unless they already belong to one, and returns the tenant they belong to.
+// The query updates the existing binding without changing it so it is returned even when a concurrent request bound
+// the user first.
+func (s *CredentialService) BindTenant(user *UserModel, tenant string) (string, error) {
+	bound, err := s.queries.BindUserTenant(s.ctx, credentials.BindUserTenantParams{
+		UserID: user.ID,
+		Tenant: tenant,
+	})
+	if err != nil {
+		return "", fmt.Errorf("data access error: %w", err)
+	}
+
+	return bound, nil
+}
+
+// GetTenant returns the tenant the user is bound to, the default tenant "" for users that are not bound to one yet
+func (s *CredentialService) GetTenant(user *UserModel) (string, error) {
+	tenant, err := s.queries.GetUserTenant(s.ctx, user.ID)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("data access error: %w", err)
+	}
+
+	return tenant, nil
+}
+
+// SetTenant moves the user to the tenant, for operators assigning the users from before tenants were bound
+func (s *CredentialService) SetTenant(user *UserModel, tenant string) error {
+	if err := s.queries.SetUserTenant(s.ctx, credentials.SetUserTenantParams{
+		UserID: user.ID,
+		Tenant: tenant,
+	}); err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserActive records whether the user is provisioned. Deprovisioning revokes every credential that is not already
+// revoked and returns their IDs, reactivating leaves credentials revoked so the user has to register again.
+func (s *CredentialService) SetUserActive(user *UserModel, active bool) ([][]byte, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	if _, err := txn.UpsertUserProvisioning(s.ctx, credentials.UpsertUserProvisioningParams{
+		UserID: user.ID,
+		Active: active,
+	}); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	var revoked [][]byte
+	var events []dto.Event
+	if !active {
+		rows, err := txn.ListUnrevokedCredentialsByUser(s.ctx, user.PgID())
+		if err != nil && err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+		for _, row := range rows {
+			credential, err := CredentialModelFromDatabase(row)
+			if err != nil {
+				return nil, err
+			}
+			statusEvents, err := s.setCredentialStatus(txn, user, credential, CredentialStatusRevoked)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, statusEvents...)
+			revoked = append(revoked, credential.ID)
+		}
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	s.publishEvents(events)
+
+	return revoked, nil
+}
+
+// DeleteUser removes the user with the provided reference along with all of their credentials
+func (s *CredentialService) DeleteUser(ref string) error {
+	deleted, err := s.queries.DeleteUser(s.ctx, ref)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	if deleted == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetCredentialByID retrieves a credential with its user from the database, regardless of the credential status
+func (s *CredentialService) GetCredentialByID(credentialID []byte) (*CredentialModel, error) {
+	row, err := s.queries.GetCredential(s.ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := CredentialModelFromDatabase(row.WebauthnCredential)
+	if err != nil {
+		return nil, err
+	}
+	credential.SetUser(UserModelFromDatabase(row.WebauthnUser))
+
+	return credential, nil
+}
+
+func addCredentialListToUser(user *UserModel, userCredentials []credentials.WebauthnCredential) {
+	for _, row := range userCredentials {
+		credential, err := CredentialModelFromDatabase(row)
+		if err != nil {
+			return
+		}
+
+		user.linkCredential(*credential)
+	}
+}
+
+func (s *CredentialService) addUserCredentialList(user *UserModel, allCredentials bool) error {
+	var (
+		userCredentials []credentials.WebauthnCredential
+		err             error
+	)
+
+	if allCredentials {
+		userCredentials, err = s.queries.ListAllCredentialsByUser(s.ctx, user.PgID())
+	} else {
+		userCredentials, err = s.queries.ListActiveCredentialsByUser(s.ctx, user.PgID())
+	}
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("fetch user(%v) credentials (all: $%v) failed: %w", user.ID, allCredentials, err)
+	}
+
+	addCredentialListToUser(user, userCredentials)
+
+	return nil
+}
+
+// GetUserWithCredentialsByID retrieves a user from the database based on the provided ID and includes the user's credentials
+func (s *CredentialService) GetUserWithCredentialsByID(id int64, allCredentials bool) (*UserModel, error) {
+	user, err := s.queries.GetUserByID(s.ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	userModel := UserModelFromDatabase(user)
+	err = s.addUserCredentialList(userModel, allCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	return userModel, nil
+}
+
+// GetUserWithCredentialsByRef retrieves a user from the database based on the provided reference and includes the user's credentials
+func (s *CredentialService) GetUserWithCredentialsByRef(ref string, allCredentials bool) (*UserModel, error) {
+	user, err := s.queries.GetUserByRef(s.ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	userModel := UserModelFromDatabase(user)
+	err = s.addUserCredentialList(userModel, allCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	return userModel, nil
+}
+
+func credentialEventData(user *UserModel, credentialID []byte, status CredentialStatus) map[string]any {
+	return map[string]any{
+		"userId":       user.RefID,
+		"credentialId": protocol.URLEncodedBase64(credentialID),
+		"status":       status,
+	}
+}
+
+// InsertCredential inserts a credential into the database for the provided user with what the registration learned
+// about it in meta. New credentials are active and nicknamed after their authenticator model unless meta says otherwise.
+func (s *CredentialService) InsertCredential(user *UserModel, credential *webauthn.Credential, meta CredentialMeta) error {
+	model := &CredentialModel{
+		Credential: *credential,
+		User:       UserRelationship{Loaded: true, Value: *user},
+		Meta:       meta,
+	}
+	if model.Meta.Status == "" {
+		model.Meta.Status = CredentialStatusActive
+	}
+	if info := model.AuthenticatorInfo(); info != nil && model.Meta.Nickname == "" {
+		model.Meta.Nickname = info.Name
+	}
+	params, err := model.ToInsertParams()
+	if err != nil {
+		return fmt.Errorf("failed to convert credential to model: %w", err)
+	}
+
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	if _, err := txn.InsertCredential(s.ctx, *params); err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	event := webhook_service.NewEvent(webhook_service.EventCredentialRegistered, credentialEventData(user, credential.ID, model.Meta.Status))
+	if err := webhook_service.EnqueueEvent(s.ctx, txn, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// setCredentialStatus saves the new status in the credential meta and lets webhook subscribers know, within the transaction.
+// It returns the stream events to publish once the transaction is committed.
+func (s *CredentialService) setCredentialStatus(txn *credentials.Queries, user *UserModel, credential *CredentialModel, status CredentialStatus) ([]dto.Event, error) {
+	previousStatus := credential.Meta.Status
+	credential.Meta.Status = status
+	if status != CredentialStatusDisabled {
+		credential.Meta.DisabledReason = ""
+	}
+
+	metaJson, err := json.Marshal(credential.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Meta: %w", err)
+	}
+	if _, err := txn.UpdateCredentialMeta(s.ctx, credentials.UpdateCredentialMetaParams{
+		CredentialID: credential.ID,
+		Meta:         metaJson,
+	}); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	data := credentialEventData(user, credential.ID, status)
+	data["previousStatus"] = previousStatus
+	event := webhook_service.NewEvent(webhook_service.EventCredentialStatusChanged, data)
+	if err := webhook_service.EnqueueEvent(s.ctx, txn, event); err != nil {
+		return nil, err
+	}
+
+	if previousStatus == status || (status != CredentialStatusRevoked && status != CredentialStatusDisabled) {
+		return nil, nil
+	}
+	return []dto.Event{dto.CredentialRevokedEvent{
+		UserID:         user.RefID,
+		CredentialID:   credential.ID,
+		PreviousStatus: string(previousStatus),
+		Status:         string(status),
+		Reason:         credential.Meta.DisabledReason,
+	}}, nil
+}
+
+// UpdateCredentialStatus changes the status of a credential belonging to the provided user
+func (s *CredentialService) UpdateCredentialStatus(user *UserModel, credentialID []byte, status CredentialStatus) (*CredentialModel, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	credential, err := s.getUserCredential(txn, user, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.setCredentialStatus(txn, user, credential, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	s.publishEvents(events)
+
+	credential.SetUser(user)
+	return credential, nil
+}
+
+// NotifyNewAuthenticatorLogin lets webhook subscribers know a credential was used to log in for the first time
+func (s *CredentialService) NotifyNewAuthenticatorLogin(user *UserModel, credentialID []byte) error {
+	event := webhook_service.NewEvent(webhook_service.EventNewAuthenticatorLogin, credentialEventData(user, credentialID, CredentialStatusActive))
+	return webhook_service.EnqueueEvent(s.ctx, s.queries, event)
+}
+
+// IncrementCredentialUseCounter increments the use counter for a credential in the database
+func (s *CredentialService) IncrementCredentialUseCounter(credentialID []byte) (int32, error) {
+	useCount, err := s.queries.IncrementCredentialUseCounter(s.ctx, credentialID)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+
+	return useCount, nil
+}
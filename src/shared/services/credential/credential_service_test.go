@@ -7,12 +7,13 @@ import (
 	"strings"
 	"testing"
 
-	"blacksmithlabs.dev/webauthn-k8s/auth/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
 	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang/mock/gomock"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/milqa/pgxpoolmock"
 )
@@ -22,13 +23,13 @@ var mockPool *pgxpoolmock.MockPgxIface
 const getUserByIdSql = "(?s:.*SELECT.*FROM webauthn_users.*WHERE _id =.*)"
 const getUserByRefSql = "(?s:.*SELECT.*FROM webauthn_users.*WHERE ref_id =.*)"
 
-var credentialRows = []string{"credential_id", "user_id", "use_counter", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta"}
+var credentialRows = []string{"credential_id", "user_id", "use_counter", "public_key", "attestation_type", "transport", "flags", "authenticator", "attestation", "meta", "aaguid"}
 
 func mockCredentialRow(
 	credentialId string,
 	active bool,
 	nickname string,
-) ([]byte, pgtype.Int8, int32, []byte, pgtype.Text, []byte, []byte, []byte, []byte, []byte) {
+) ([]byte, pgtype.Int8, int32, []byte, pgtype.Text, []byte, []byte, []byte, []byte, []byte, []byte) {
 	status := CredentialStatusActive
 	if !active {
 		status = CredentialStatusDisabled
@@ -42,7 +43,8 @@ func mockCredentialRow(
 		[]byte("{}"), // flags
 		[]byte("{}"), // authenticator
 		[]byte("{}"), // attestation
-		[]byte(fmt.Sprintf(`{"status": "%v", "nickname": "%v"}`, status, nickname)) // meta
+		[]byte(fmt.Sprintf(`{"status": "%v", "nickname": "%v"}`, status, nickname)), // meta
+		[]byte{} // aaguid
 }
 
 func buildUserModel(
@@ -362,7 +364,7 @@ func TestCredentialService_GetUserWithCredentialsByID(t *testing.T) {
 				)
 				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT.*FROM webauthn_credentials.*)"), pgtype.Int8{Int64: 1, Valid: true}).Return(
 					pgxpoolmock.NewRows(credentialRows).AddRow(
-						[]byte{}, int64(0), int32(0), []byte{}, "", []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
+						[]byte{}, int64(0), int32(0), []byte{}, "", []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
 					).ToPgxRows(),
 					pgx.ErrNoRows,
 				)
@@ -495,7 +497,7 @@ func TestCredentialService_GetUserWithCredentialsByRef(t *testing.T) {
 				)
 				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT.*FROM webauthn_credentials.*)"), pgtype.Int8{Int64: 1, Valid: true}).Return(
 					pgxpoolmock.NewRows(credentialRows).AddRow(
-						[]byte{}, int64(0), int32(0), []byte{}, "", []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
+						[]byte{}, int64(0), int32(0), []byte{}, "", []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
 					).ToPgxRows(),
 					pgx.ErrNoRows,
 				)
@@ -607,7 +609,17 @@ func TestCredentialService_InsertCredential(t *testing.T) {
 		{
 			name: "Insert credential success",
 			setup: func() {
-				mockPool.EXPECT().QueryRow(
+				mocker := mockPool.EXPECT()
+				mocker.Begin(gomock.Any()).Return(mockPool, nil)
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.Rollback(gomock.Any()).Return(nil)
+				mocker.Exec(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"),
+					"credential.registered",
+					gomock.Any(),
+				).Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+				mocker.QueryRow(
 					gomock.Any(),
 					pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_credentials.*)"),
 					[]byte("credential-id"),
@@ -619,7 +631,8 @@ func TestCredentialService_InsertCredential(t *testing.T) {
 					gomock.Any(),
 					gomock.Any(),
 					gomock.Any(),
-				).Return(pgxpoolmock.NewRow([]byte("credential-id"), pgtype.Int8{Int64: 1, Valid: true}, int32(0), []byte{}, pgtype.Text{String: "none", Valid: true}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}))
+					gomock.Any(),
+				).Return(pgxpoolmock.NewRow([]byte("credential-id"), pgtype.Int8{Int64: 1, Valid: true}, int32(0), []byte{}, pgtype.Text{String: "none", Valid: true}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}))
 			},
 			args: args{
 				user:       buildUserModel(1, "test-id", "name", "display"),
@@ -639,7 +652,11 @@ func TestCredentialService_InsertCredential(t *testing.T) {
 		{
 			name: "Insert credential error",
 			setup: func() {
-				mockPool.EXPECT().QueryRow(
+				mocker := mockPool.EXPECT()
+				mocker.Begin(gomock.Any()).Return(mockPool, nil)
+				// Commit and the webhook outbox should not be called
+				mocker.Rollback(gomock.Any()).Return(nil)
+				mocker.QueryRow(
 					gomock.Any(),
 					pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_credentials.*)"),
 					[]byte("credential-id"),
@@ -651,8 +668,9 @@ func TestCredentialService_InsertCredential(t *testing.T) {
 					gomock.Any(),
 					gomock.Any(),
 					gomock.Any(),
+					gomock.Any(),
 				).Return(
-					pgxpoolmock.NewRow([]byte{}, pgtype.Int8{Int64: 0, Valid: false}, int32(0), []byte{}, pgtype.Text{String: "", Valid: false}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}).WithError(fmt.Errorf("query failed")),
+					pgxpoolmock.NewRow([]byte{}, pgtype.Int8{Int64: 0, Valid: false}, int32(0), []byte{}, pgtype.Text{String: "", Valid: false}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}).WithError(fmt.Errorf("query failed")),
 				)
 			},
 			args: args{
@@ -672,7 +690,7 @@ func TestCredentialService_InsertCredential(t *testing.T) {
 				t.Errorf("New() error = %v, want nil", err)
 			}
 
-			if err := s.InsertCredential(tt.args.user, tt.args.credential); (err != nil) != tt.wantErr {
+			if err := s.InsertCredential(tt.args.user, tt.args.credential, CredentialMeta{}); (err != nil) != tt.wantErr {
 				t.Errorf("CredentialService.InsertCredential() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -739,3 +757,486 @@ func TestCredentialService_IncrementCredentialUseCounter(t *testing.T) {
 		})
 	}
 }
+
+func TestCredentialService_UpdateCredentialStatus(t *testing.T) {
+	const getCredentialSql = "(?ms:SELECT.*FROM webauthn_credentials.*INNER JOIN webauthn_users.*)"
+	credentialRow := func(userId int64) *pgxpoolmock.Row {
+		credential, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid := mockCredentialRow("credential-id", true, "nickname")
+		return pgxpoolmock.NewRow(
+			credential, pgtype.Int8{Int64: userId, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid,
+			userId, "test-id", []byte("test-id"), "name", "display",
+		)
+	}
+
+	type setup func()
+	tests := []struct {
+		name       string
+		setup      setup
+		want       CredentialStatus
+		wantEvents int
+		wantErr    bool
+	}{
+		{
+			name: "Update status success",
+			setup: func() {
+				mocker := mockPool.EXPECT()
+				mocker.Begin(gomock.Any()).Return(mockPool, nil)
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.Rollback(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(credentialRow(1))
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+					[]byte("credential-id"),
+					[]byte(`{"status":"revoked","nickname":"nickname"}`),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("credential-id", false, "nickname")))
+				mocker.Exec(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"),
+					"credential.status_changed",
+					gomock.Any(),
+				).Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+			},
+			want:       CredentialStatusRevoked,
+			wantEvents: 1,
+			wantErr:    false,
+		},
+		{
+			name: "Credential belongs to another user",
+			setup: func() {
+				mocker := mockPool.EXPECT()
+				mocker.Begin(gomock.Any()).Return(mockPool, nil)
+				mocker.Rollback(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(credentialRow(2))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			tt.setup()
+
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			publisher := &recordingPublisher{}
+			s.WithEvents(publisher, "tenant")
+
+			got, err := s.UpdateCredentialStatus(buildUserModel(1, "test-id", "name", "display"), []byte("credential-id"), CredentialStatusRevoked)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CredentialService.UpdateCredentialStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(publisher.events) != tt.wantEvents {
+				t.Fatalf("CredentialService.UpdateCredentialStatus() published %v events, want %v", len(publisher.events), tt.wantEvents)
+			}
+			if tt.wantEvents > 0 {
+				event, ok := publisher.events[0].(dto.CredentialRevokedEvent)
+				if !ok || event.Status != string(CredentialStatusRevoked) || event.PreviousStatus != string(CredentialStatusActive) || event.UserID != "test-id" {
+					t.Errorf("CredentialService.UpdateCredentialStatus() published %+v, want a credential.revoked event", publisher.events[0])
+				}
+			}
+			if !tt.wantErr && got.Meta.Status != tt.want {
+				t.Errorf("CredentialService.UpdateCredentialStatus() status = %v, want %v", got.Meta.Status, tt.want)
+			}
+		})
+	}
+}
+
+// recordingPublisher keeps the events the service publishes
+type recordingPublisher struct {
+	events []dto.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event dto.Event, tenant string) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestCredentialService_GetCredentialByID(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	credential, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid := mockCredentialRow("credential-id", false, "nickname")
+	mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT.*FROM webauthn_credentials.*INNER JOIN webauthn_users.*)"), []byte("credential-id")).Return(
+		pgxpoolmock.NewRow(
+			credential, pgtype.Int8{Int64: 1, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid,
+			int64(1), "test-id", []byte("test-id"), "name", "display",
+		),
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+	got, err := s.GetCredentialByID([]byte("credential-id"))
+
+	// Then
+	if err != nil {
+		t.Fatalf("GetCredentialByID() error = %v, want nil", err)
+	}
+	if got.Meta.Status != CredentialStatusDisabled {
+		t.Errorf("GetCredentialByID() status = %v, want %v", got.Meta.Status, CredentialStatusDisabled)
+	}
+	if !got.User.Loaded || got.User.Value.RefID != "test-id" {
+		t.Errorf("GetCredentialByID() user = %+v, want loaded user test-id", got.User)
+	}
+}
+
+func TestCredentialService_SearchUsers(t *testing.T) {
+	userRows := []string{"_id", "ref_id", "raw_id", "name", "display_name"}
+	type args struct {
+		query   string
+		afterID int64
+		limit   int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantQuery pgtype.Text
+		wantLimit int32
+		want      []*UserModel
+	}{
+		{
+			name:      "No query uses the default limit",
+			args:      args{},
+			wantQuery: pgtype.Text{},
+			wantLimit: defaultSearchLimit,
+			want: []*UserModel{
+				buildUserModel(1, "u1", "Name 1", "Display 1"),
+				buildUserModel(2, "u2", "Name 2", "Display 2"),
+			},
+		},
+		{
+			name:      "Query with cursor and clamped limit",
+			args:      args{query: "name", afterID: 1, limit: 10000},
+			wantQuery: pgtype.Text{String: "name", Valid: true},
+			wantLimit: maxSearchLimit,
+			want: []*UserModel{
+				buildUserModel(2, "u2", "Name 2", "Display 2"),
+			},
+		},
+		{
+			name:      "Wildcards in the query are escaped",
+			args:      args{query: `50%_off\`},
+			wantQuery: pgtype.Text{String: `50\%\_off\\`, Valid: true},
+			wantLimit: defaultSearchLimit,
+			want:      []*UserModel{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			rows := pgxpoolmock.NewRows(userRows)
+			for _, user := range tt.want {
+				rows.AddRow(user.ID, user.RefID, user.RawID, user.Name, user.DisplayName)
+			}
+			mockPool.EXPECT().Query(
+				gomock.Any(),
+				pgxpoolmock.QueryContains("(?ms:SELECT.*FROM webauthn_users.*ILIKE.*ORDER BY _id)"),
+				tt.wantQuery,
+				tt.args.afterID,
+				tt.wantLimit,
+			).Return(rows.ToPgxRows(), nil)
+
+			// When
+			credentialService, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+
+			got, err := credentialService.SearchUsers(tt.args.query, tt.args.afterID, tt.args.limit)
+
+			// Then
+			if err != nil {
+				t.Errorf("SearchUsers() error = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_UpdateUser(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mockPool.EXPECT().QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_users.*)"),
+		"ref-id",
+		"New Name",
+		"New Display",
+	).Return(
+		pgxpoolmock.NewRow(int64(2), "ref-id", []byte("ref-id"), "New Name", "New Display"),
+	)
+
+	expected := buildUserModel(2, "ref-id", "New Name", "New Display")
+
+	// When
+	credentialService, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+
+	user, err := credentialService.UpdateUser("ref-id", "New Name", "New Display")
+
+	// Then
+	if err != nil {
+		t.Errorf("UpdateUser() error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("UpdateUser() user = %v, want %v", user, expected)
+	}
+}
+
+func TestCredentialService_DeleteUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		wantErr error
+	}{
+		{
+			name:    "User deleted",
+			tag:     "DELETE 1",
+			wantErr: nil,
+		},
+		{
+			name:    "User not found",
+			tag:     "DELETE 0",
+			wantErr: pgx.ErrNoRows,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			mockPool.EXPECT().Exec(
+				gomock.Any(),
+				pgxpoolmock.QueryContains("(?ms:DELETE FROM webauthn_users.*WHERE ref_id =.*)"),
+				"ref-id",
+			).Return(pgconn.NewCommandTag(tt.tag), nil)
+
+			// When
+			credentialService, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+
+			err = credentialService.DeleteUser("ref-id")
+
+			// Then
+			if err != tt.wantErr {
+				t.Errorf("DeleteUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredentialService_IsUserActive(t *testing.T) {
+	tests := []struct {
+		name string
+		row  *pgxpoolmock.Row
+		want bool
+	}{
+		{
+			name: "Never provisioned",
+			row:  pgxpoolmock.NewRow(int64(0), false, pgtype.Timestamptz{}).WithError(pgx.ErrNoRows),
+			want: true,
+		},
+		{
+			name: "Deprovisioned",
+			row:  pgxpoolmock.NewRow(int64(1), false, pgtype.Timestamptz{}),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM user_provisioning.*)"), int64(1)).Return(tt.row)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.IsUserActive(buildUserModel(1, "test-id", "name", "display"))
+
+			// Then
+			if err != nil {
+				t.Errorf("IsUserActive() error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsUserActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_HasUnrevokedCredentials(t *testing.T) {
+	revoked := func(credentialId string) []any {
+		return []any{
+			[]byte(credentialId), pgtype.Int8{Int64: 1, Valid: true}, int32(0), []byte{}, pgtype.Text{String: "none", Valid: true},
+			[]byte("[\"internal\"]"), []byte("{}"), []byte("{}"), []byte("{}"), []byte(`{"status": "revoked"}`), []byte{},
+		}
+	}
+	tests := []struct {
+		name string
+		rows *pgxpoolmock.Rows
+		want bool
+	}{
+		{
+			name: "Every credential disabled",
+			rows: pgxpoolmock.NewRows(credentialRows).AddRow(
+				mockCredentialRow("c1", false, "c1-nickname"),
+			).AddRow(
+				mockCredentialRow("c2", false, "c2-nickname"),
+			),
+			want: true,
+		},
+		{
+			name: "Revoked and active credentials",
+			rows: pgxpoolmock.NewRows(credentialRows).AddRow(revoked("c1")...).AddRow(
+				mockCredentialRow("c2", true, "c2-nickname"),
+			),
+			want: true,
+		},
+		{
+			name: "Every credential revoked",
+			rows: pgxpoolmock.NewRows(credentialRows).AddRow(revoked("c1")...).AddRow(revoked("c2")...),
+			want: false,
+		},
+		{
+			name: "No credentials",
+			rows: pgxpoolmock.NewRows(credentialRows),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mockPool.EXPECT().Query(gomock.Any(), pgxpoolmock.QueryContains(`(?ms:SELECT.*FROM webauthn_credentials.*WHERE user_id = \$1\s+ORDER BY)`), pgtype.Int8{Int64: 1, Valid: true}).Return(
+				tt.rows.ToPgxRows(),
+				nil,
+			)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.HasUnrevokedCredentials(buildUserModel(1, "test-id", "name", "display"))
+
+			// Then
+			if err != nil {
+				t.Errorf("HasUnrevokedCredentials() error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasUnrevokedCredentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_BindTenant(t *testing.T) {
+	tests := []struct {
+		name string
+		row  *pgxpoolmock.Row
+		want string
+	}{
+		{
+			name: "Newly bound",
+			row:  pgxpoolmock.NewRow("bank"),
+			want: "bank",
+		},
+		{
+			name: "Already bound to another tenant",
+			row:  pgxpoolmock.NewRow("shop"),
+			want: "shop",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO user_tenants.*ON CONFLICT \\(user_id\\).*DO UPDATE SET user_id = EXCLUDED.user_id.*RETURNING tenant)"), int64(1), "bank").Return(tt.row)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.BindTenant(buildUserModel(1, "test-id", "name", "display"), "bank")
+
+			// Then
+			if err != nil {
+				t.Errorf("BindTenant() error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("BindTenant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_SetUserActive_Deprovision(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Begin(gomock.Any()).Return(mockPool, nil)
+	mocker.Commit(gomock.Any()).Return(nil)
+	mocker.Rollback(gomock.Any()).Return(nil)
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO user_provisioning.*)"), int64(1), false).Return(
+		pgxpoolmock.NewRow(int64(1), false, pgtype.Timestamptz{}),
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM webauthn_credentials.*<> 'revoked')"), pgtype.Int8{Int64: 1, Valid: true}).Return(
+		pgxpoolmock.NewRows(credentialRows).
+			AddRow(mockCredentialRow("c1", true, "c1-nickname")).
+			AddRow(mockCredentialRow("c2", false, "c2-nickname")).
+			ToPgxRows(),
+		nil,
+	)
+	mocker.QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+		[]byte("c1"),
+		[]byte(`{"status":"revoked","nickname":"c1-nickname"}`),
+	).Return(pgxpoolmock.NewRow(mockCredentialRow("c1", false, "c1-nickname")))
+	mocker.QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+		[]byte("c2"),
+		[]byte(`{"status":"revoked","nickname":"c2-nickname"}`),
+	).Return(pgxpoolmock.NewRow(mockCredentialRow("c2", false, "c2-nickname")))
+	mocker.Exec(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"),
+		"credential.status_changed",
+		gomock.Any(),
+	).Return(pgconn.NewCommandTag("INSERT 0 1"), nil).Times(2)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	revoked, err := s.SetUserActive(buildUserModel(1, "test-id", "name", "display"), false)
+
+	// Then
+	if err != nil {
+		t.Errorf("SetUserActive() error = %v, want nil", err)
+	}
+	if want := [][]byte{[]byte("c1"), []byte("c2")}; !reflect.DeepEqual(revoked, want) {
+		t.Errorf("SetUserActive() revoked = %s, want %s", revoked, want)
+	}
+}
@@ -0,0 +1,109 @@
+package credential_service
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+const (
+	extensionCredProps = "credProps"
+	extensionLargeBlob = "largeBlob"
+)
+
+var (
+	ErrLargeBlobCredentialNotFound = errors.New("largeBlob credential is not an active credential of the user")
+	ErrLargeBlobUnsupported        = errors.New("largeBlob credential does not support large blobs")
+)
+
+// CredentialLargeBlob is the largeBlob extension state of a credential
+type CredentialLargeBlob struct {
+	Supported bool `json:"supported"`
+}
+
+// LargeBlobStatus reports whether the credential can store a large blob, nil when it was never asked
+func (c *CredentialModel) LargeBlobStatus() *dto.LargeBlobStatus {
+	if c.Meta.LargeBlob == nil {
+		return nil
+	}
+	return &dto.LargeBlobStatus{Supported: c.Meta.LargeBlob.Supported}
+}
+
+// RegistrationExtensions builds the extensions of a registration. credProps is always requested so the credential's
+// discoverability is known, prf is requested with the salt when one is given.
+func RegistrationExtensions(input dto.RegistrationExtensions, prfSalt []byte) protocol.AuthenticationExtensions {
+	extensions := protocol.AuthenticationExtensions{extensionCredProps: true}
+	if input.PRF != nil && prfSalt != nil {
+		maps.Copy(extensions, PRFRegistrationExtensions(prfSalt, input.PRF))
+	}
+	if input.LargeBlob != nil {
+		support := input.LargeBlob.Support
+		if support == "" {
+			support = dto.LargeBlobPreferred
+		}
+		extensions[extensionLargeBlob] = map[string]any{"support": string(support)}
+	}
+	return extensions
+}
+
+// RegistrationMeta records the client extension results of a registration in the meta of the new credential.
+// The prf salt is only kept when the authenticator enabled prf.
+func RegistrationMeta(input dto.RegistrationExtensions, prfSalt []byte, results protocol.AuthenticationExtensionsClientOutputs) CredentialMeta {
+	meta := CredentialMeta{}
+	if credProps, ok := results[extensionCredProps].(map[string]any); ok {
+		if rk, ok := credProps["rk"].(bool); ok {
+			meta.Discoverable = &rk
+		}
+	}
+	if prfSalt != nil {
+		meta.PRF = &CredentialPRF{Enabled: PRFEnabled(results)}
+		if meta.PRF.Enabled {
+			meta.PRF.Salt = prfSalt
+		}
+	}
+	if input.LargeBlob != nil {
+		meta.LargeBlob = &CredentialLargeBlob{}
+		if largeBlob, ok := results[extensionLargeBlob].(map[string]any); ok {
+			meta.LargeBlob.Supported, _ = largeBlob["supported"].(bool)
+		}
+	}
+	return meta
+}
+
+// LargeBlobCredential finds the credential a large blob is written to. The client only writes with a single allowed
+// credential, so it must be one of the user's and support large blobs.
+func LargeBlobCredential(credentials []CredentialModel, id []byte) (*CredentialModel, error) {
+	for i := range credentials {
+		if !bytes.Equal(credentials[i].ID, id) {
+			continue
+		}
+		if credentials[i].Meta.LargeBlob == nil || !credentials[i].Meta.LargeBlob.Supported {
+			return nil, ErrLargeBlobUnsupported
+		}
+		return &credentials[i], nil
+	}
+	return nil, ErrLargeBlobCredentialNotFound
+}
+
+// AuthenticationExtensions builds the extensions of an authentication offering the credentials, nil when none apply
+func AuthenticationExtensions(credentials []CredentialModel, input dto.AuthenticationExtensions) protocol.AuthenticationExtensions {
+	extensions := protocol.AuthenticationExtensions{}
+	if input.PRF != nil {
+		maps.Copy(extensions, PRFAuthenticationExtensions(credentials, input.PRF))
+	}
+	if largeBlob := input.LargeBlob; largeBlob != nil {
+		if largeBlob.Write != nil {
+			extensions[extensionLargeBlob] = map[string]any{"write": largeBlob.Write}
+		} else {
+			extensions[extensionLargeBlob] = map[string]any{"read": true}
+		}
+	}
+	if len(extensions) == 0 {
+		return nil
+	}
+	return extensions
+}
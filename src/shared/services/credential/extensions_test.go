@@ -0,0 +1,151 @@
+package credential_service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+func TestRegistrationExtensions(t *testing.T) {
+	// Given a registration asking for prf and largeBlob without a support level
+	input := dto.RegistrationExtensions{PRF: &dto.PRFInput{}, LargeBlob: &dto.LargeBlobRegistrationInput{}}
+
+	// When the extensions are built
+	extensions := RegistrationExtensions(input, []byte("salt"))
+
+	// Then credProps is requested with both extensions and largeBlob is preferred
+	if extensions["credProps"] != true {
+		t.Errorf("Expected credProps to be requested, got %v", extensions)
+	}
+	if _, ok := extensions["prf"]; !ok {
+		t.Errorf("Expected prf to be requested, got %v", extensions)
+	}
+	if support := extensions["largeBlob"].(map[string]any)["support"]; support != "preferred" {
+		t.Errorf("Expected largeBlob support to be preferred, got %v", support)
+	}
+
+	// And without options only credProps is requested
+	if extensions := RegistrationExtensions(dto.RegistrationExtensions{}, nil); len(extensions) != 1 {
+		t.Errorf("Expected only credProps, got %v", extensions)
+	}
+}
+
+func TestRegistrationMeta(t *testing.T) {
+	input := dto.RegistrationExtensions{PRF: &dto.PRFInput{}, LargeBlob: &dto.LargeBlobRegistrationInput{Support: dto.LargeBlobRequired}}
+
+	t.Run("All results", func(t *testing.T) {
+		// Given the client reported every extension
+		results := protocol.AuthenticationExtensionsClientOutputs{
+			"credProps": map[string]any{"rk": true},
+			"prf":       map[string]any{"enabled": true},
+			"largeBlob": map[string]any{"supported": true},
+		}
+
+		// When the meta is built
+		meta := RegistrationMeta(input, []byte("salt"), results)
+
+		// Then everything is recorded
+		if meta.Discoverable == nil || !*meta.Discoverable {
+			t.Errorf("Expected the credential to be discoverable, got %v", meta.Discoverable)
+		}
+		if meta.PRF == nil || !meta.PRF.Enabled || string(meta.PRF.Salt) != "salt" {
+			t.Errorf("Expected prf to be enabled with the salt, got %v", meta.PRF)
+		}
+		if meta.LargeBlob == nil || !meta.LargeBlob.Supported {
+			t.Errorf("Expected largeBlob to be supported, got %v", meta.LargeBlob)
+		}
+	})
+
+	t.Run("No results", func(t *testing.T) {
+		// When the client reported nothing
+		meta := RegistrationMeta(input, []byte("salt"), nil)
+
+		// Then discoverability is unknown and the asked extensions are not supported
+		if meta.Discoverable != nil {
+			t.Errorf("Expected discoverability to be unknown, got %v", *meta.Discoverable)
+		}
+		if meta.PRF == nil || meta.PRF.Enabled || meta.PRF.Salt != nil {
+			t.Errorf("Expected prf to be disabled without a salt, got %v", meta.PRF)
+		}
+		if meta.LargeBlob == nil || meta.LargeBlob.Supported {
+			t.Errorf("Expected largeBlob to be unsupported, got %v", meta.LargeBlob)
+		}
+	})
+
+	t.Run("Not asked", func(t *testing.T) {
+		// When the registration asked for no extension
+		meta := RegistrationMeta(dto.RegistrationExtensions{}, nil, protocol.AuthenticationExtensionsClientOutputs{
+			"credProps": map[string]any{"rk": false},
+		})
+
+		// Then only credProps is recorded
+		if meta.Discoverable == nil || *meta.Discoverable {
+			t.Errorf("Expected the credential not to be discoverable, got %v", meta.Discoverable)
+		}
+		if meta.PRF != nil || meta.LargeBlob != nil {
+			t.Errorf("Expected no extension state, got %v", meta)
+		}
+	})
+}
+
+func TestLargeBlobCredential(t *testing.T) {
+	supported := CredentialModel{Credential: webauthn.Credential{ID: []byte{1}}, Meta: CredentialMeta{LargeBlob: &CredentialLargeBlob{Supported: true}}}
+	unsupported := CredentialModel{Credential: webauthn.Credential{ID: []byte{2}}, Meta: CredentialMeta{LargeBlob: &CredentialLargeBlob{}}}
+	never := CredentialModel{Credential: webauthn.Credential{ID: []byte{3}}}
+	credentials := []CredentialModel{supported, unsupported, never}
+
+	cases := []struct {
+		name     string
+		id       []byte
+		expected error
+	}{
+		{"Supported", []byte{1}, nil},
+		{"Unsupported", []byte{2}, ErrLargeBlobUnsupported},
+		{"Never asked", []byte{3}, ErrLargeBlobUnsupported},
+		{"Unknown", []byte{4}, ErrLargeBlobCredentialNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			credential, err := LargeBlobCredential(credentials, c.id)
+			if !errors.Is(err, c.expected) {
+				t.Fatalf("Expected error %v, got %v", c.expected, err)
+			}
+			if err == nil && credential.ID[0] != 1 {
+				t.Errorf("Expected the supported credential, got %v", credential.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticationExtensions(t *testing.T) {
+	credentials := []CredentialModel{{Credential: webauthn.Credential{ID: []byte{1}}, Meta: CredentialMeta{PRF: &CredentialPRF{Enabled: true, Salt: []byte("salt")}}}}
+
+	// Given a write of a large blob together with prf
+	extensions := AuthenticationExtensions(credentials, dto.AuthenticationExtensions{
+		PRF:       &dto.PRFInput{},
+		LargeBlob: &dto.LargeBlobAuthenticationInput{Write: []byte("blob"), CredentialID: []byte{1}},
+	})
+
+	// Then both are requested
+	if _, ok := extensions["prf"]; !ok {
+		t.Errorf("Expected prf to be requested, got %v", extensions)
+	}
+	if write := extensions["largeBlob"].(map[string]any)["write"]; string(write.(protocol.URLEncodedBase64)) != "blob" {
+		t.Errorf("Expected the blob to be written, got %v", write)
+	}
+
+	// And a read is requested as such
+	extensions = AuthenticationExtensions(credentials, dto.AuthenticationExtensions{LargeBlob: &dto.LargeBlobAuthenticationInput{Read: true}})
+	if read := extensions["largeBlob"].(map[string]any)["read"]; read != true {
+		t.Errorf("Expected the blob to be read, got %v", extensions)
+	}
+
+	// And nothing is requested without extensions
+	if extensions := AuthenticationExtensions(credentials, dto.AuthenticationExtensions{}); extensions != nil {
+		t.Errorf("Expected no extensions, got %v", extensions)
+	}
+}
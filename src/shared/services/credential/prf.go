@@ -0,0 +1,85 @@
+package credential_service
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+const extensionPRF = "prf"
+
+const prfSaltLength = 32
+
+// CredentialPRF is the prf extension state of a credential. The salt is not secret, the key derived from it never
+// leaves the authenticator and the client.
+type CredentialPRF struct {
+	Enabled bool                      `json:"enabled"`
+	Salt    protocol.URLEncodedBase64 `json:"salt,omitempty"`
+}
+
+// PRFStatus reports whether the credential can evaluate the prf extension, nil when it was never asked
+func (c *CredentialModel) PRFStatus() *dto.PRFStatus {
+	if c.Meta.PRF == nil {
+		return nil
+	}
+	return &dto.PRFStatus{Enabled: c.Meta.PRF.Enabled}
+}
+
+// NewPRFSalt generates the salt a new credential evaluates the prf extension with
+func NewPRFSalt() ([]byte, error) {
+	salt := make([]byte, prfSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate prf salt: %w", err)
+	}
+	return salt, nil
+}
+
+func prfValues(first []byte, second []byte) map[string]any {
+	values := map[string]any{"first": protocol.URLEncodedBase64(first)}
+	if len(second) > 0 {
+		values["second"] = protocol.URLEncodedBase64(second)
+	}
+	return values
+}
+
+// PRFRegistrationExtensions asks a new credential to enable the prf extension, authenticators that can already
+// evaluate it at creation do so with the salt
+func PRFRegistrationExtensions(salt []byte, input *dto.PRFInput) protocol.AuthenticationExtensions {
+	return protocol.AuthenticationExtensions{extensionPRF: map[string]any{"eval": prfValues(salt, input.Second)}}
+}
+
+// PRFAuthenticationExtensions evaluates the prf extension with the stored salt of each credential that has it enabled,
+// nil when none has
+func PRFAuthenticationExtensions(credentials []CredentialModel, input *dto.PRFInput) protocol.AuthenticationExtensions {
+	byCredential := map[string]any{}
+	for _, credential := range credentials {
+		if credential.Meta.PRF == nil || !credential.Meta.PRF.Enabled || len(credential.Meta.PRF.Salt) == 0 {
+			continue
+		}
+		byCredential[protocol.URLEncodedBase64(credential.ID).String()] = prfValues(credential.Meta.PRF.Salt, input.Second)
+	}
+	if len(byCredential) == 0 {
+		return nil
+	}
+	return protocol.AuthenticationExtensions{extensionPRF: map[string]any{"evalByCredential": byCredential}}
+}
+
+// PRFEnabled reads prf.enabled from the client extension results of a registration
+func PRFEnabled(results protocol.AuthenticationExtensionsClientOutputs) bool {
+	prf, ok := results[extensionPRF].(map[string]any)
+	if !ok {
+		return false
+	}
+	enabled, _ := prf["enabled"].(bool)
+	return enabled
+}
+
+// StripPRFResults drops the prf output a client sent back by mistake, so the derived key is never stored or logged
+func StripPRFResults(results protocol.AuthenticationExtensionsClientOutputs) {
+	if prf, ok := results[extensionPRF].(map[string]any); ok {
+		delete(prf, "results")
+	}
+}
@@ -0,0 +1,102 @@
+package credential_service
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+func TestPRFRegistrationExtensions(t *testing.T) {
+	// Given a salt and a second salt from the client
+	salt := []byte("first-salt")
+
+	// When the registration extensions are built
+	extensions := PRFRegistrationExtensions(salt, &dto.PRFInput{Second: []byte("second-salt")})
+
+	// Then both salts are evaluated
+	eval := extensions["prf"].(map[string]any)["eval"].(map[string]any)
+	if !bytes.Equal(eval["first"].(protocol.URLEncodedBase64), salt) {
+		t.Errorf("Expected first to be the salt, got %v", eval["first"])
+	}
+	if string(eval["second"].(protocol.URLEncodedBase64)) != "second-salt" {
+		t.Errorf("Expected second to be the client salt, got %v", eval["second"])
+	}
+
+	// And without a second salt only the first is evaluated
+	eval = PRFRegistrationExtensions(salt, &dto.PRFInput{})["prf"].(map[string]any)["eval"].(map[string]any)
+	if _, ok := eval["second"]; ok {
+		t.Errorf("Expected no second salt, got %v", eval["second"])
+	}
+}
+
+func TestPRFAuthenticationExtensions(t *testing.T) {
+	// Given credentials with and without prf enabled
+	enabled := CredentialModel{Credential: webauthn.Credential{ID: []byte{1, 2, 3}}, Meta: CredentialMeta{PRF: &CredentialPRF{Enabled: true, Salt: []byte("salt")}}}
+	disabled := CredentialModel{Credential: webauthn.Credential{ID: []byte{4, 5, 6}}, Meta: CredentialMeta{PRF: &CredentialPRF{}}}
+	never := CredentialModel{Credential: webauthn.Credential{ID: []byte{7, 8, 9}}}
+
+	// When the authentication extensions are built
+	extensions := PRFAuthenticationExtensions([]CredentialModel{enabled, disabled, never}, &dto.PRFInput{})
+
+	// Then only the enabled credential is evaluated, keyed by its base64url ID
+	byCredential := extensions["prf"].(map[string]any)["evalByCredential"].(map[string]any)
+	if len(byCredential) != 1 {
+		t.Fatalf("Expected 1 credential, got %v", byCredential)
+	}
+	values, ok := byCredential["AQID"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected the credential to be keyed by AQID, got %v", byCredential)
+	}
+	if string(values["first"].(protocol.URLEncodedBase64)) != "salt" {
+		t.Errorf("Expected the stored salt, got %v", values["first"])
+	}
+
+	// And no extensions are built when no credential has prf enabled
+	if extensions := PRFAuthenticationExtensions([]CredentialModel{disabled, never}, &dto.PRFInput{}); extensions != nil {
+		t.Errorf("Expected no extensions, got %v", extensions)
+	}
+}
+
+func TestPRFEnabled(t *testing.T) {
+	cases := []struct {
+		name     string
+		results  protocol.AuthenticationExtensionsClientOutputs
+		expected bool
+	}{
+		{"Enabled", protocol.AuthenticationExtensionsClientOutputs{"prf": map[string]any{"enabled": true}}, true},
+		{"Not enabled", protocol.AuthenticationExtensionsClientOutputs{"prf": map[string]any{"enabled": false}}, false},
+		{"No prf result", protocol.AuthenticationExtensionsClientOutputs{"credProps": map[string]any{"rk": true}}, false},
+		{"No results", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := PRFEnabled(c.results); actual != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStripPRFResults(t *testing.T) {
+	// Given results carrying the prf output
+	results := protocol.AuthenticationExtensionsClientOutputs{
+		"prf": map[string]any{"enabled": true, "results": map[string]any{"first": "secret"}},
+	}
+
+	// When the results are stripped
+	StripPRFResults(results)
+	StripPRFResults(nil)
+
+	// Then the output is gone but enabled is kept
+	prf := results["prf"].(map[string]any)
+	if _, ok := prf["results"]; ok {
+		t.Errorf("Expected the prf output to be stripped, got %v", prf)
+	}
+	if !PRFEnabled(results) {
+		t.Errorf("Expected enabled to be kept")
+	}
+}
@@ -0,0 +1,94 @@
+package credential_service
+
+import (
+	"fmt"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+const reevaluationPageSize = 100
+
+// CredentialCheck returns why a stored credential is no longer acceptable, nil when it still is
+type CredentialCheck func(credential *CredentialModel) error
+
+// CredentialDisabled is called as soon as a failing credential is disabled, so a walk that stops halfway loses no
+// record of the credentials it already disabled
+type CredentialDisabled func(user *UserModel, credential *CredentialModel, reason string)
+
+// ReevaluateCredentials walks every active credential of the tenant's users and runs the check on it, users not bound
+// to a tenant yet belong to the default tenant "". Unless dryRun is set, credentials that fail are disabled with the
+// reason in their meta, each in its own transaction so a long walk holds no locks.
+// The returned report lists the failing credentials either way, disabled is called for each of them that was disabled.
+func (s *CredentialService) ReevaluateCredentials(tenant string, check CredentialCheck, dryRun bool, disabled CredentialDisabled) (*dto.ReevaluationReport, error) {
+	report := &dto.ReevaluationReport{DryRun: dryRun, Failing: []dto.ReevaluatedCredential{}}
+
+	afterID := []byte{}
+	for {
+		rows, err := s.queries.ListActiveCredentials(s.ctx, credentials.ListActiveCredentialsParams{
+			Tenant:   tenant,
+			AfterID:  afterID,
+			RowLimit: reevaluationPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			credential, err := CredentialModelFromDatabase(row.WebauthnCredential)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read credential of user(%v): %w", row.WebauthnUser.ID, err)
+			}
+			user := UserModelFromDatabase(row.WebauthnUser)
+			credential.SetUser(user)
+			report.Checked++
+
+			failure := check(credential)
+			if failure == nil {
+				continue
+			}
+			if !dryRun {
+				if err := s.disableCredential(user, credential, failure.Error()); err != nil {
+					return nil, err
+				}
+				if disabled != nil {
+					disabled(user, credential, failure.Error())
+				}
+			}
+			report.Failing = append(report.Failing, dto.ReevaluatedCredential{
+				UserID:       user.RefID,
+				CredentialID: credential.ID,
+				AAGUID:       credential.Authenticator.AAGUID,
+				Reason:       failure.Error(),
+			})
+		}
+		afterID = rows[len(rows)-1].WebauthnCredential.CredentialID
+	}
+
+	return report, nil
+}
+
+func (s *CredentialService) disableCredential(user *UserModel, credential *CredentialModel, reason string) error {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	credential.Meta.DisabledReason = reason
+	events, err := s.setCredentialStatus(txn, user, credential, CredentialStatusDisabled)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	s.publishEvents(events)
+	return nil
+}
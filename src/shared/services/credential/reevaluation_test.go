@@ -0,0 +1,91 @@
+package credential_service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/milqa/pgxpoolmock"
+)
+
+func TestCredentialService_ReevaluateCredentials(t *testing.T) {
+	const activeSql = "(?ms:FROM webauthn_credentials.*LEFT JOIN user_tenants.*WHERE meta->>'status' = 'active'.*)"
+	// c2 is the only credential that fails the check
+	check := func(credential *CredentialModel) error {
+		if string(credential.ID) == "c2" {
+			return errors.New("authenticator status REVOKED")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+	}{
+		{name: "Dry run only reports", dryRun: true},
+		{name: "Disable failing credentials", dryRun: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+
+			mocker := mockPool.EXPECT()
+			mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(activeSql), "bank", []byte{}, int32(reevaluationPageSize)).Return(
+				pgxpoolmock.NewRows(aaguidRows).
+					AddRow(mockAaguidRow("c1", 1, "u1")...).
+					AddRow(mockAaguidRow("c2", 2, "u2")...).
+					ToPgxRows(),
+				nil,
+			)
+			mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(activeSql), "bank", []byte("c2"), int32(reevaluationPageSize)).Return(
+				pgxpoolmock.NewRows(aaguidRows).ToPgxRows(),
+				nil,
+			)
+			if !tt.dryRun {
+				mocker.Begin(gomock.Any()).Return(mockPool, nil)
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.Rollback(gomock.Any()).Return(nil)
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+					[]byte("c2"),
+					[]byte(`{"status":"disabled","nickname":"c2-nickname","disabledReason":"authenticator status REVOKED"}`),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("c2", false, "c2-nickname")))
+				mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"), "credential.status_changed", gomock.Any()).
+					Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+			}
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			var disabled []string
+			got, err := s.ReevaluateCredentials("bank", check, tt.dryRun, func(user *UserModel, credential *CredentialModel, reason string) {
+				disabled = append(disabled, user.RefID+"/"+string(credential.ID))
+			})
+
+			// Then
+			if err != nil {
+				t.Fatalf("ReevaluateCredentials() error = %v, want nil", err)
+			}
+			if got.DryRun != tt.dryRun || got.Checked != 2 || len(got.Failing) != 1 {
+				t.Fatalf("ReevaluateCredentials() = %+v, want 2 checked and 1 failing", got)
+			}
+			if got.Failing[0].UserID != "u2" || got.Failing[0].Reason != "authenticator status REVOKED" {
+				t.Errorf("ReevaluateCredentials() failing = %+v, want c2 of u2 with the check's reason", got.Failing[0])
+			}
+			wantDisabled := []string{"u2/c2"}
+			if tt.dryRun {
+				wantDisabled = nil
+			}
+			if !slices.Equal(disabled, wantDisabled) {
+				t.Errorf("ReevaluateCredentials() disabled = %v, want %v", disabled, wantDisabled)
+			}
+		})
+	}
+}
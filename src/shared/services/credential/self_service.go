@@ -0,0 +1,160 @@
+package credential_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+// ErrLastCredential is returned when removing the user's last active credential would lock them out of their account
+var ErrLastCredential = errors.New("last active credential")
+
+// getUserCredential loads a credential within the transaction, pgx.ErrNoRows when it does not belong to the user
+func (s *CredentialService) getUserCredential(txn *credentials.Queries, user *UserModel, credentialID []byte) (*CredentialModel, error) {
+	row, err := txn.GetCredential(s.ctx, credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	if row.WebauthnUser.ID != user.ID {
+		return nil, fmt.Errorf("credential does not belong to user(%v): %w", user.ID, pgx.ErrNoRows)
+	}
+	return CredentialModelFromDatabase(row.WebauthnCredential)
+}
+
+// RenameCredential changes the nickname of a credential belonging to the user, revoked credentials are not found
+func (s *CredentialService) RenameCredential(user *UserModel, credentialID []byte, nickname string) (*CredentialModel, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	credential, err := s.getUserCredential(txn, user, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Meta.Status == CredentialStatusRevoked {
+		return nil, fmt.Errorf("credential is revoked: %w", pgx.ErrNoRows)
+	}
+
+	credential.Meta.Nickname = nickname
+	metaJson, err := json.Marshal(credential.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Meta: %w", err)
+	}
+	if _, err := txn.UpdateCredentialMeta(s.ctx, credentials.UpdateCredentialMetaParams{
+		CredentialID: credential.ID,
+		Meta:         metaJson,
+	}); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	credential.SetUser(user)
+	return credential, nil
+}
+
+// RemoveCredential revokes a credential of the user on their own behalf. The last active credential is only removed
+// when the user has a recovery method to get back into their account, otherwise ErrLastCredential is returned.
+func (s *CredentialService) RemoveCredential(user *UserModel, credentialID []byte) (*CredentialModel, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	// Removals of the user's credentials wait for each other, otherwise two of them could each see the other
+	// credential as active and remove the last two together
+	if _, err := txn.LockUser(s.ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	credential, err := s.getUserCredential(txn, user, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Meta.Status == CredentialStatusRevoked {
+		return nil, fmt.Errorf("credential is already revoked: %w", pgx.ErrNoRows)
+	}
+
+	if credential.Meta.Status == CredentialStatusActive {
+		active, err := txn.ListActiveCredentialsByUser(s.ctx, user.PgID())
+		if err != nil && err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+		if len(active) <= 1 {
+			methods, err := txn.ListUserRecoveryMethods(s.ctx, user.ID)
+			if err != nil && err != pgx.ErrNoRows {
+				return nil, fmt.Errorf("data access error: %w", err)
+			}
+			if len(methods) == 0 {
+				return nil, ErrLastCredential
+			}
+		}
+	}
+
+	events, err := s.setCredentialStatus(txn, user, credential, CredentialStatusRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	s.publishEvents(events)
+
+	credential.SetUser(user)
+	return credential, nil
+}
+
+// RecoveryMethods lists the account recovery methods the relying party has set up for the user
+func (s *CredentialService) RecoveryMethods(user *UserModel) ([]string, error) {
+	methods, err := s.queries.ListUserRecoveryMethods(s.ctx, user.ID)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	if methods == nil {
+		methods = []string{}
+	}
+	return methods, nil
+}
+
+// SetRecoveryMethods replaces the user's account recovery methods
+func (s *CredentialService) SetRecoveryMethods(user *UserModel, methods []string) ([]string, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	if err := txn.DeleteUserRecoveryMethods(s.ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	for _, method := range methods {
+		if err := txn.InsertUserRecoveryMethod(s.ctx, credentials.InsertUserRecoveryMethodParams{
+			UserID: user.ID,
+			Method: method,
+		}); err != nil {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return s.RecoveryMethods(user)
+}
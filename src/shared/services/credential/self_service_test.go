@@ -0,0 +1,207 @@
+package credential_service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+const getCredentialWithUserSql = "(?ms:SELECT.*FROM webauthn_credentials.*INNER JOIN webauthn_users.*)"
+
+func credentialWithUserRow(credentialId string, userId int64) *pgxpoolmock.Row {
+	credential, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid := mockCredentialRow(credentialId, true, "nickname")
+	return pgxpoolmock.NewRow(
+		credential, pgtype.Int8{Int64: userId, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid,
+		userId, "test-id", []byte("test-id"), "name", "display",
+	)
+}
+
+func TestCredentialService_RenameCredential(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Begin(gomock.Any()).Return(mockPool, nil)
+	mocker.Commit(gomock.Any()).Return(nil)
+	mocker.Rollback(gomock.Any()).Return(nil)
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialWithUserSql), []byte("credential-id")).Return(credentialWithUserRow("credential-id", 1))
+	mocker.QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+		[]byte("credential-id"),
+		[]byte(`{"status":"active","nickname":"Work laptop"}`),
+	).Return(pgxpoolmock.NewRow(mockCredentialRow("credential-id", true, "Work laptop")))
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	credential, err := s.RenameCredential(buildUserModel(1, "test-id", "name", "display"), []byte("credential-id"), "Work laptop")
+
+	// Then
+	if err != nil {
+		t.Fatalf("RenameCredential() error = %v, want nil", err)
+	}
+	if credential.Meta.Nickname != "Work laptop" {
+		t.Errorf("RenameCredential() nickname = %v, want %v", credential.Meta.Nickname, "Work laptop")
+	}
+}
+
+const lockUserSql = "(?ms:FROM webauthn_users.*FOR UPDATE)"
+
+func TestCredentialService_RemoveCredential(t *testing.T) {
+	const listActiveSql = "(?ms:FROM webauthn_credentials.*'active')"
+	const listRecoverySql = "(?ms:FROM user_recovery_methods.*)"
+
+	type setup func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder)
+	expectRevoke := func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+		mocker.Commit(gomock.Any()).Return(nil)
+		mocker.QueryRow(
+			gomock.Any(),
+			pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET meta.*)"),
+			[]byte("c1"),
+			[]byte(`{"status":"revoked","nickname":"nickname"}`),
+		).Return(pgxpoolmock.NewRow(mockCredentialRow("c1", false, "nickname")))
+		mocker.Exec(
+			gomock.Any(),
+			pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*)"),
+			"credential.status_changed",
+			gomock.Any(),
+		).Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+	}
+	tests := []struct {
+		name    string
+		setup   setup
+		wantErr error
+	}{
+		{
+			name: "Another active credential",
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(listActiveSql), pgtype.Int8{Int64: 1, Valid: true}).Return(
+					pgxpoolmock.NewRows(credentialRows).
+						AddRow(mockCredentialRow("c1", true, "nickname")).
+						AddRow(mockCredentialRow("c2", true, "nickname")).
+						ToPgxRows(),
+					nil,
+				)
+				expectRevoke(mocker)
+			},
+		},
+		{
+			name: "Last credential with a recovery method",
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(listActiveSql), pgtype.Int8{Int64: 1, Valid: true}).Return(
+					pgxpoolmock.NewRows(credentialRows).AddRow(mockCredentialRow("c1", true, "nickname")).ToPgxRows(),
+					nil,
+				)
+				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(listRecoverySql), int64(1)).Return(
+					pgxpoolmock.NewRows([]string{"method"}).AddRow("email").ToPgxRows(),
+					nil,
+				)
+				expectRevoke(mocker)
+			},
+		},
+		{
+			name: "Last credential without a recovery method",
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(listActiveSql), pgtype.Int8{Int64: 1, Valid: true}).Return(
+					pgxpoolmock.NewRows(credentialRows).AddRow(mockCredentialRow("c1", true, "nickname")).ToPgxRows(),
+					nil,
+				)
+				mocker.Query(gomock.Any(), pgxpoolmock.QueryContains(listRecoverySql), int64(1)).Return(
+					pgxpoolmock.NewRows([]string{"method"}).ToPgxRows(),
+					nil,
+				)
+			},
+			wantErr: ErrLastCredential,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mocker := mockPool.EXPECT()
+			mocker.Begin(gomock.Any()).Return(mockPool, nil)
+			mocker.Rollback(gomock.Any()).Return(nil)
+			mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(lockUserSql), int64(1)).Return(pgxpoolmock.NewRow(int64(1)))
+			mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialWithUserSql), []byte("c1")).Return(credentialWithUserRow("c1", 1))
+			tt.setup(mocker)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			credential, err := s.RemoveCredential(buildUserModel(1, "test-id", "name", "display"), []byte("c1"))
+
+			// Then
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveCredential() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && credential.Meta.Status != CredentialStatusRevoked {
+				t.Errorf("RemoveCredential() status = %v, want %v", credential.Meta.Status, CredentialStatusRevoked)
+			}
+		})
+	}
+}
+
+func TestCredentialService_RemoveCredential_OtherUser(t *testing.T) {
+	// Given a credential of another user
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Begin(gomock.Any()).Return(mockPool, nil)
+	mocker.Rollback(gomock.Any()).Return(nil)
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(lockUserSql), int64(1)).Return(pgxpoolmock.NewRow(int64(1)))
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialWithUserSql), []byte("c1")).Return(credentialWithUserRow("c1", 2))
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	_, err = s.RemoveCredential(buildUserModel(1, "test-id", "name", "display"), []byte("c1"))
+
+	// Then
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("RemoveCredential() error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
+
+func TestCredentialService_SetRecoveryMethods(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Begin(gomock.Any()).Return(mockPool, nil)
+	mocker.Commit(gomock.Any()).Return(nil)
+	mocker.Rollback(gomock.Any()).Return(nil)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:DELETE FROM user_recovery_methods.*)"), int64(1)).Return(pgconn.NewCommandTag("DELETE 1"), nil)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO user_recovery_methods.*)"), int64(1), "email").Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM user_recovery_methods.*)"), int64(1)).Return(
+		pgxpoolmock.NewRows([]string{"method"}).AddRow("email").ToPgxRows(),
+		nil,
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	methods, err := s.SetRecoveryMethods(buildUserModel(1, "test-id", "name", "display"), []string{"email"})
+
+	// Then
+	if err != nil {
+		t.Fatalf("SetRecoveryMethods() error = %v, want nil", err)
+	}
+	if len(methods) != 1 || methods[0] != "email" {
+		t.Errorf("SetRecoveryMethods() = %v, want [email]", methods)
+	}
+}
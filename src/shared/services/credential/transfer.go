@@ -0,0 +1,541 @@
+package credential_service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+// Users are exported a page at a time so the whole table is never held in memory
+const exportPageSize = maxSearchLimit
+
+// A single user can have a few dozen credentials with their attestations, which do not fit the default scanner buffer
+const maxImportLineSize = 4 * 1024 * 1024
+
+// ErrInvalidImport is wrapped by import errors caused by the file itself, a bad version, a malformed user or an unusable public key
+var ErrInvalidImport = errors.New("invalid import")
+
+// ErrImportConflict is wrapped by import errors caused by records that already exist and can not be imported over
+var ErrImportConflict = errors.New("import conflict")
+
+// ExportSummary counts what an export wrote
+type ExportSummary struct {
+	Users       int
+	Credentials int
+}
+
+func exportedCredential(row credentials.WebauthnCredential) (dto.ExportedCredential, error) {
+	credential, err := CredentialModelFromDatabase(row)
+	if err != nil {
+		return dto.ExportedCredential{}, err
+	}
+
+	// The meta is exported as stored so nothing is lost, credentials saved before it existed get the default
+	meta := json.RawMessage(row.Meta)
+	if len(meta) == 0 {
+		if meta, err = json.Marshal(credential.Meta); err != nil {
+			return dto.ExportedCredential{}, fmt.Errorf("failed to marshal Meta: %w", err)
+		}
+	}
+
+	return dto.ExportedCredential{Credential: credential.Credential, Meta: meta}, nil
+}
+
+// Export writes every user with all of their credentials, whatever their status, in the provided format.
+// The users are read in a single repeatable read transaction so the export is a consistent snapshot.
+func (s *CredentialService) Export(w io.Writer, format dto.ExportFormat) (*ExportSummary, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback(s.ctx)
+
+	if _, err := tx.Exec(s.ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	header := dto.ExportHeader{Version: dto.ExportVersion, ExportedAt: time.Now().UTC()}
+
+	// The JSON document is written by hand around the users so it can be streamed like the JSON lines
+	if format == dto.ExportFormatJSONL {
+		if err := encoder.Encode(header); err != nil {
+			return nil, fmt.Errorf("failed to write export: %w", err)
+		}
+	} else {
+		headerJson, err := json.Marshal(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write export: %w", err)
+		}
+		buffered.Write(headerJson[:len(headerJson)-1])
+		buffered.WriteString(`,"users":[`)
+	}
+
+	summary := &ExportSummary{}
+	var afterID int64
+	for {
+		users, err := txn.SearchUsers(s.ctx, credentials.SearchUsersParams{
+			AfterID:  afterID,
+			RowLimit: exportPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			rows, err := txn.ListAllCredentialsByUser(s.ctx, pgtype.Int8{Int64: user.ID, Valid: true})
+			if err != nil {
+				return nil, fmt.Errorf("data access error: %w", err)
+			}
+
+			exported := dto.ExportedUser{
+				UserID:      user.RefID,
+				RawID:       user.RawID,
+				UserName:    user.Name,
+				DisplayName: user.DisplayName,
+				Credentials: make([]dto.ExportedCredential, 0, len(rows)),
+			}
+			if tenant, err := txn.GetUserTenant(s.ctx, user.ID); err == nil {
+				exported.Tenant = &tenant
+			} else if err != pgx.ErrNoRows {
+				return nil, fmt.Errorf("data access error: %w", err)
+			}
+			for _, row := range rows {
+				credential, err := exportedCredential(row)
+				if err != nil {
+					return nil, fmt.Errorf("failed to export credential of user(%v): %w", user.ID, err)
+				}
+				exported.Credentials = append(exported.Credentials, credential)
+			}
+
+			if format != dto.ExportFormatJSONL && summary.Users > 0 {
+				buffered.WriteByte(',')
+			}
+			if err := encoder.Encode(exported); err != nil {
+				return nil, fmt.Errorf("failed to write export: %w", err)
+			}
+			summary.Users++
+			summary.Credentials += len(exported.Credentials)
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	if format != dto.ExportFormatJSONL {
+		buffered.WriteString("]}\n")
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return summary, nil
+}
+
+// exportReader reads the users of an export one at a time, returning io.EOF after the last one
+type exportReader func() (*dto.ExportedUser, error)
+
+func checkExportVersion(header dto.ExportHeader) error {
+	if header.Version < 1 || header.Version > dto.ExportVersion {
+		return fmt.Errorf("%w: unsupported version %v, expected at most %v", ErrInvalidImport, header.Version, dto.ExportVersion)
+	}
+	return nil
+}
+
+func newExportReader(r io.Reader, format dto.ExportFormat) (int, exportReader, error) {
+	if format != dto.ExportFormatJSONL {
+		var document dto.ExportDocument
+		if err := json.NewDecoder(r).Decode(&document); err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if err := checkExportVersion(document.ExportHeader); err != nil {
+			return 0, nil, err
+		}
+
+		next := 0
+		return document.Version, func() (*dto.ExportedUser, error) {
+			if next >= len(document.Users) {
+				return nil, io.EOF
+			}
+			next++
+			return &document.Users[next-1], nil
+		}, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := 0
+	nextLine := func() ([]byte, error) {
+		for scanner.Scan() {
+			line++
+			if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+				return scanner.Bytes(), nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%w: line %v: %v", ErrInvalidImport, line+1, err)
+		}
+		return nil, io.EOF
+	}
+
+	first, err := nextLine()
+	if err == io.EOF {
+		return 0, nil, fmt.Errorf("%w: the export is empty", ErrInvalidImport)
+	} else if err != nil {
+		return 0, nil, err
+	}
+	var header dto.ExportHeader
+	if err := json.Unmarshal(first, &header); err != nil {
+		return 0, nil, fmt.Errorf("%w: line %v: %v", ErrInvalidImport, line, err)
+	}
+	if err := checkExportVersion(header); err != nil {
+		return 0, nil, err
+	}
+
+	return header.Version, func() (*dto.ExportedUser, error) {
+		data, err := nextLine()
+		if err != nil {
+			return nil, err
+		}
+		var user dto.ExportedUser
+		if err := json.Unmarshal(data, &user); err != nil {
+			return nil, fmt.Errorf("%w: line %v: %v", ErrInvalidImport, line, err)
+		}
+		return &user, nil
+	}, nil
+}
+
+// validatePublicKey checks the COSE key can actually be used to verify assertions.
+// The go-webauthn parser ignores decoding errors, so the key material is checked here.
+func validatePublicKey(publicKey []byte) error {
+	key, err := webauthncose.ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	switch key := key.(type) {
+	case webauthncose.EC2PublicKeyData:
+		var curve ecdh.Curve
+		var size int
+		var crv int64
+		switch webauthncose.COSEAlgorithmIdentifier(key.Algorithm) {
+		case webauthncose.AlgES256:
+			curve, size, crv = ecdh.P256(), 32, 1
+		case webauthncose.AlgES384:
+			curve, size, crv = ecdh.P384(), 48, 2
+		case webauthncose.AlgES512:
+			curve, size, crv = ecdh.P521(), 66, 3
+		default:
+			return fmt.Errorf("unsupported algorithm %v", key.Algorithm)
+		}
+		// Verification picks the curve from the algorithm, a key naming another curve is not the key it claims to be
+		if key.Curve != crv {
+			return fmt.Errorf("curve %v does not match algorithm %v", key.Curve, key.Algorithm)
+		}
+		if len(key.XCoord) > size || len(key.YCoord) > size {
+			return fmt.Errorf("invalid coordinates")
+		}
+		// The uncompressed point is only accepted when it is on the curve
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(key.XCoord):1+size], key.XCoord)
+		copy(point[1+2*size-len(key.YCoord):], key.YCoord)
+		if _, err := curve.NewPublicKey(point); err != nil {
+			return fmt.Errorf("invalid point: %w", err)
+		}
+	case webauthncose.OKPPublicKeyData:
+		if len(key.XCoord) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid Ed25519 key size %v", len(key.XCoord))
+		}
+	case webauthncose.RSAPublicKeyData:
+		if len(key.Modulus) < 256 || len(key.Exponent) == 0 {
+			return fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+	}
+	return nil
+}
+
+func validCredentialStatus(status CredentialStatus) bool {
+	_, ok := statusStrictness[status]
+	return ok
+}
+
+// statusStrictness ranks the statuses by how far they keep a credential from signing in
+var statusStrictness = map[CredentialStatus]int{
+	CredentialStatusActive:   0,
+	CredentialStatusPending:  1,
+	CredentialStatusDisabled: 2,
+	CredentialStatusRevoked:  3,
+}
+
+// keepStricterStatus keeps the stored status when it is stricter than the imported one, an older export must not
+// bring back a credential that was disabled or revoked since
+func keepStricterStatus(credential *CredentialModel, stored []byte) error {
+	if len(stored) == 0 {
+		return nil
+	}
+	var meta CredentialMeta
+	if err := json.Unmarshal(stored, &meta); err != nil {
+		return fmt.Errorf("failed to unmarshal Meta: %w", err)
+	}
+	if statusStrictness[meta.Status] > statusStrictness[credential.Meta.Status] {
+		credential.Meta.Status = meta.Status
+		credential.Meta.DisabledReason = meta.DisabledReason
+	}
+	return nil
+}
+
+// importedCredential validates the exported credential and turns it into a model owned by the user
+func importedCredential(user *UserModel, exported dto.ExportedCredential) (*CredentialModel, error) {
+	id := protocol.URLEncodedBase64(exported.ID)
+	if len(exported.ID) == 0 {
+		return nil, fmt.Errorf("%w: credential id is required", ErrInvalidImport)
+	}
+	validate := validatePublicKey
+	if exported.AttestationType == string(protocol.CredentialTypeFIDOU2F) {
+		validate = validateU2FPublicKey
+	}
+	if err := validate(exported.PublicKey); err != nil {
+		return nil, fmt.Errorf("%w: credential %v: invalid public key: %v", ErrInvalidImport, id, err)
+	}
+
+	credential := &CredentialModel{
+		Credential: exported.Credential,
+		Meta:       CredentialMeta{Status: CredentialStatusActive},
+	}
+	if len(exported.Meta) > 0 {
+		if err := json.Unmarshal(exported.Meta, &credential.Meta); err != nil {
+			return nil, fmt.Errorf("%w: credential %v: invalid meta: %v", ErrInvalidImport, id, err)
+		}
+	}
+	if !validCredentialStatus(credential.Meta.Status) {
+		return nil, fmt.Errorf("%w: credential %v: invalid status %q", ErrInvalidImport, id, credential.Meta.Status)
+	}
+
+	credential.SetUser(user)
+	return credential, nil
+}
+
+// importTenant checks an existing user may be imported into the tenant. An import never moves a user to another tenant,
+// a user that is not bound to one yet is bound when the import overwrites them.
+func (s *CredentialService) importTenant(txn *credentials.Queries, userID int64, tenant *string, mode dto.ImportConflictMode) (bool, error) {
+	if tenant == nil {
+		return true, nil
+	}
+	bound, err := txn.GetUserTenant(s.ctx, userID)
+	if err == pgx.ErrNoRows {
+		if mode == dto.ImportConflictOverwrite {
+			if err := txn.SetUserTenant(s.ctx, credentials.SetUserTenantParams{UserID: userID, Tenant: *tenant}); err != nil {
+				return false, fmt.Errorf("data access error: %w", err)
+			}
+		}
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+	if bound == *tenant {
+		return true, nil
+	}
+	if mode == dto.ImportConflictSkip {
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: user belongs to tenant %q", ErrImportConflict, bound)
+}
+
+// importUser creates, overwrites or skips the user and then each of their credentials according to the mode.
+// The user is bound to the tenant, when there is one, instead of the tenant they were exported from.
+func (s *CredentialService) importUser(txn *credentials.Queries, exported *dto.ExportedUser, mode dto.ImportConflictMode, tenant *string, response *dto.ImportResponse) error {
+	if exported.UserID == "" || len(exported.UserID) > 100 {
+		return fmt.Errorf("%w: userId is required and must be at most 100 characters", ErrInvalidImport)
+	}
+	if len(exported.RawID) == 0 || exported.UserName == "" {
+		return fmt.Errorf("%w: rawId and userName are required", ErrInvalidImport)
+	}
+
+	var user *UserModel
+	existing, err := txn.GetUserByRef(s.ctx, exported.UserID)
+	if err == pgx.ErrNoRows {
+		created, err := txn.InsertUser(s.ctx, credentials.InsertUserParams{
+			RefID:       exported.UserID,
+			RawID:       exported.RawID,
+			Name:        exported.UserName,
+			DisplayName: exported.DisplayName,
+		})
+		if err != nil {
+			return fmt.Errorf("data access error: %w", err)
+		}
+		user = UserModelFromDatabase(created)
+		if tenant != nil {
+			if err := txn.SetUserTenant(s.ctx, credentials.SetUserTenantParams{UserID: user.ID, Tenant: *tenant}); err != nil {
+				return fmt.Errorf("data access error: %w", err)
+			}
+		}
+		response.Users.Created++
+	} else if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	} else if !bytes.Equal(existing.RawID, exported.RawID) {
+		// The credentials would not match the user handle, so this can never be imported over
+		if mode == dto.ImportConflictSkip {
+			response.Users.Skipped++
+			response.Credentials.Skipped += len(exported.Credentials)
+			return nil
+		}
+		return fmt.Errorf("%w: user already exists with another raw ID", ErrImportConflict)
+	} else if imported, err := s.importTenant(txn, existing.ID, tenant, mode); err != nil {
+		return err
+	} else if !imported {
+		response.Users.Skipped++
+		response.Credentials.Skipped += len(exported.Credentials)
+		return nil
+	} else {
+		switch mode {
+		case dto.ImportConflictFail:
+			return fmt.Errorf("%w: user already exists", ErrImportConflict)
+		case dto.ImportConflictOverwrite:
+			updated, err := txn.UpdateUser(s.ctx, credentials.UpdateUserParams{
+				RefID:       exported.UserID,
+				Name:        exported.UserName,
+				DisplayName: exported.DisplayName,
+			})
+			if err != nil {
+				return fmt.Errorf("data access error: %w", err)
+			}
+			existing = updated
+			response.Users.Overwritten++
+		default:
+			response.Users.Skipped++
+		}
+		user = UserModelFromDatabase(existing)
+	}
+
+	for _, exportedCredential := range exported.Credentials {
+		credential, err := importedCredential(user, exportedCredential)
+		if err != nil {
+			return err
+		}
+		if err := s.importCredential(txn, user, credential, mode, &response.Credentials); err != nil {
+			return fmt.Errorf("credential %v: %w", protocol.URLEncodedBase64(credential.ID), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *CredentialService) importCredential(txn *credentials.Queries, user *UserModel, credential *CredentialModel, mode dto.ImportConflictMode, counts *dto.ImportCounts) error {
+	existing, err := txn.GetCredential(s.ctx, credential.ID)
+	if err == pgx.ErrNoRows {
+		params, err := credential.ToInsertParams()
+		if err != nil {
+			return fmt.Errorf("failed to convert credential to model: %w", err)
+		}
+		if _, err := txn.InsertCredential(s.ctx, *params); err != nil {
+			return fmt.Errorf("data access error: %w", err)
+		}
+		if credential.Authenticator.SignCount > 0 {
+			if err := txn.SetCredentialUseCounter(s.ctx, credentials.SetCredentialUseCounterParams{
+				CredentialID: credential.ID,
+				UseCounter:   int32(credential.Authenticator.SignCount),
+			}); err != nil {
+				return fmt.Errorf("data access error: %w", err)
+			}
+		}
+		counts.Created++
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	switch {
+	case mode == dto.ImportConflictSkip:
+		counts.Skipped++
+		return nil
+	case mode == dto.ImportConflictFail:
+		return fmt.Errorf("%w: credential already exists", ErrImportConflict)
+	case existing.WebauthnUser.ID != user.ID:
+		return fmt.Errorf("%w: credential belongs to another user", ErrImportConflict)
+	}
+
+	if err := keepStricterStatus(credential, existing.WebauthnCredential.Meta); err != nil {
+		return err
+	}
+	params, err := credential.ToInsertParams()
+	if err != nil {
+		return fmt.Errorf("failed to convert credential to model: %w", err)
+	}
+	// The use counter only moves forward, an older export must not reset the clone detection
+	if _, err := txn.ReplaceCredential(s.ctx, credentials.ReplaceCredentialParams{
+		CredentialID:    params.CredentialID,
+		PublicKey:       params.PublicKey,
+		AttestationType: params.AttestationType,
+		Transport:       params.Transport,
+		Flags:           params.Flags,
+		Authenticator:   params.Authenticator,
+		Attestation:     params.Attestation,
+		Meta:            params.Meta,
+		Aaguid:          params.Aaguid,
+		UseCounter:      int32(credential.Authenticator.SignCount),
+	}); err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	counts.Overwritten++
+	return nil
+}
+
+// Import reads an export and stores its users and credentials, handling existing ones according to the mode.
+// The users are bound to the tenant they were exported from, or to the tenant when one is given.
+// Everything is imported in a single transaction, so on any error, conflicts included, nothing is changed.
+// No webhooks are sent, importing is not a registration.
+func (s *CredentialService) Import(r io.Reader, format dto.ExportFormat, mode dto.ImportConflictMode, tenant *string) (*dto.ImportResponse, error) {
+	version, next, err := newExportReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	response := &dto.ImportResponse{Version: version, Mode: mode}
+	for index := 0; ; index++ {
+		user, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		userTenant := user.Tenant
+		if tenant != nil {
+			userTenant = tenant
+		}
+		if err := s.importUser(txn, user, mode, userTenant, response); err != nil {
+			return nil, fmt.Errorf("user %v (%q): %w", index+1, user.UserID, err)
+		}
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return response, nil
+}
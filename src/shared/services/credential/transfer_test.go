@@ -0,0 +1,479 @@
+package credential_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+const getCredentialSql = "(?ms:SELECT.*FROM webauthn_credentials.*INNER JOIN webauthn_users.*)"
+
+const getUserTenantSql = "(?ms:SELECT tenant FROM user_tenants WHERE user_id = \\$1$)"
+
+func ec2PublicKeyFor(t *testing.T, algorithm webauthncose.COSEAlgorithmIdentifier, curve int64, x []byte, y []byte) []byte {
+	key, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(algorithm),
+		},
+		Curve:  curve,
+		XCoord: x,
+		YCoord: y,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return key
+}
+
+func ec2PublicKey(t *testing.T, x []byte, y []byte) []byte {
+	return ec2PublicKeyFor(t, webauthncose.AlgES256, 1, x, y)
+}
+
+func validPublicKey(t *testing.T) []byte {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	point := key.PublicKey().Bytes()
+	return ec2PublicKey(t, point[1:33], point[33:])
+}
+
+func TestValidatePublicKey(t *testing.T) {
+	point := bytes.Repeat([]byte{1}, 32)
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	valid := key.PublicKey().Bytes()
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr bool
+	}{
+		{name: "Valid P-256 key", key: validPublicKey(t), wantErr: false},
+		{name: "Point not on the curve", key: ec2PublicKey(t, point, point), wantErr: true},
+		{name: "Oversized coordinates", key: ec2PublicKey(t, append(point, 1), point), wantErr: true},
+		{name: "P-256 point with the ES384 algorithm", key: ec2PublicKeyFor(t, webauthncose.AlgES384, 1, valid[1:33], valid[33:]), wantErr: true},
+		{name: "P-256 point with the P-384 curve", key: ec2PublicKeyFor(t, webauthncose.AlgES256, 2, valid[1:33], valid[33:]), wantErr: true},
+		{name: "Unsupported algorithm", key: ec2PublicKeyFor(t, webauthncose.AlgES256K, 1, valid[1:33], valid[33:]), wantErr: true},
+		{name: "Not a COSE key", key: []byte("public-key"), wantErr: true},
+		{name: "Empty", key: []byte{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := validatePublicKey(tt.key)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func exportLines(t *testing.T, header dto.ExportHeader, users ...dto.ExportedUser) *bytes.Buffer {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	if err := encoder.Encode(header); err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	for _, user := range users {
+		if err := encoder.Encode(user); err != nil {
+			t.Fatalf("failed to encode user: %v", err)
+		}
+	}
+	return &buffer
+}
+
+func TestCredentialService_Import(t *testing.T) {
+	header := dto.ExportHeader{Version: dto.ExportVersion}
+	bank, insurer := "bank", "insurer"
+	credential := *buildWebAuthnCredential("credential-id")
+	credential.PublicKey = validPublicKey(t)
+	credential.Authenticator.SignCount = 5
+	user := dto.ExportedUser{
+		UserID:      "u1",
+		RawID:       []byte("raw-u1"),
+		UserName:    "name",
+		DisplayName: "display",
+		Credentials: []dto.ExportedCredential{{Credential: credential, Meta: json.RawMessage(`{"status":"disabled"}`)}},
+	}
+	existingUser := func() *pgxpoolmock.Row {
+		return pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "old name", "old display")
+	}
+	existingCredential := func() *pgxpoolmock.Row {
+		id, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid := mockCredentialRow("credential-id", true, "")
+		return pgxpoolmock.NewRow(
+			id, pgtype.Int8{Int64: 1, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation, meta, aaguid,
+			int64(1), "u1", []byte("raw-u1"), "old name", "old display",
+		)
+	}
+
+	tests := []struct {
+		name    string
+		mode    dto.ImportConflictMode
+		tenant  *string
+		input   func() *bytes.Buffer
+		setup   func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder)
+		want    *dto.ImportResponse
+		wantErr error
+	}{
+		{
+			name:  "New user and credential keep the meta and sign count",
+			mode:  dto.ImportConflictFail,
+			input: func() *bytes.Buffer { return exportLines(t, header, user) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(
+					pgxpoolmock.NewRow(int64(0), "", []byte{}, "", "").WithError(pgx.ErrNoRows),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_users)"), "u1", []byte("raw-u1"), "name", "display").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(
+					pgxpoolmock.NewRow(
+						[]byte{}, pgtype.Int8{}, int32(0), []byte{}, pgtype.Text{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
+						int64(0), "", []byte{}, "", "",
+					).WithError(pgx.ErrNoRows),
+				)
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_credentials)"),
+					[]byte("credential-id"),
+					pgtype.Int8{Int64: 1, Valid: true},
+					credential.PublicKey,
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					[]byte(`{"status":"disabled","nickname":""}`),
+					gomock.Any(),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("credential-id", false, "")))
+				mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SET use_counter = \\$2)"), []byte("credential-id"), int32(5)).Return(
+					pgconn.NewCommandTag("UPDATE 1"), nil,
+				)
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictFail,
+				Users:       dto.ImportCounts{Created: 1},
+				Credentials: dto.ImportCounts{Created: 1},
+			},
+		},
+		{
+			name:  "Skip leaves existing records alone",
+			mode:  dto.ImportConflictSkip,
+			input: func() *bytes.Buffer { return exportLines(t, header, user) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(existingCredential())
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictSkip,
+				Users:       dto.ImportCounts{Skipped: 1},
+				Credentials: dto.ImportCounts{Skipped: 1},
+			},
+		},
+		{
+			name:  "Overwrite replaces existing records",
+			mode:  dto.ImportConflictOverwrite,
+			input: func() *bytes.Buffer { return exportLines(t, header, user) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_users)"), "u1", "name", "display").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(existingCredential())
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET public_key.*use_counter = GREATEST\\(use_counter)"),
+					[]byte("credential-id"),
+					credential.PublicKey,
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					[]byte(`{"status":"disabled","nickname":""}`),
+					gomock.Any(),
+					int32(5),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("credential-id", false, "")))
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictOverwrite,
+				Users:       dto.ImportCounts{Overwritten: 1},
+				Credentials: dto.ImportCounts{Overwritten: 1},
+			},
+		},
+		{
+			name: "Overwrite keeps a stricter stored status",
+			mode: dto.ImportConflictOverwrite,
+			input: func() *bytes.Buffer {
+				active := user
+				active.Credentials = []dto.ExportedCredential{{Credential: credential, Meta: json.RawMessage(`{"status":"active"}`)}}
+				return exportLines(t, header, active)
+			},
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_users)"), "u1", "name", "display").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+				id, _, counter, publicKey, attestationType, transport, flags, authenticator, attestation, _, aaguid := mockCredentialRow("credential-id", true, "")
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("credential-id")).Return(pgxpoolmock.NewRow(
+					id, pgtype.Int8{Int64: 1, Valid: true}, counter, publicKey, attestationType, transport, flags, authenticator, attestation,
+					[]byte(`{"status":"revoked","nickname":""}`), aaguid,
+					int64(1), "u1", []byte("raw-u1"), "old name", "old display",
+				))
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:UPDATE webauthn_credentials.*SET public_key.*use_counter = GREATEST\\(use_counter)"),
+					[]byte("credential-id"),
+					credential.PublicKey,
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					[]byte(`{"status":"revoked","nickname":""}`),
+					gomock.Any(),
+					int32(5),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("credential-id", false, "")))
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictOverwrite,
+				Users:       dto.ImportCounts{Overwritten: 1},
+				Credentials: dto.ImportCounts{Overwritten: 1},
+			},
+		},
+		{
+			name:   "New user is bound to the tenant of the import",
+			mode:   dto.ImportConflictSkip,
+			tenant: &insurer,
+			input: func() *bytes.Buffer {
+				bound := user
+				bound.Tenant = &bank
+				bound.Credentials = nil
+				return exportLines(t, header, bound)
+			},
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(
+					pgxpoolmock.NewRow(int64(0), "", []byte{}, "", "").WithError(pgx.ErrNoRows),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_users)"), "u1", []byte("raw-u1"), "name", "display").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+				mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO user_tenants.*DO UPDATE)"), int64(1), "insurer").Return(
+					pgconn.NewCommandTag("INSERT 0 1"), nil,
+				)
+			},
+			want: &dto.ImportResponse{
+				Version: dto.ExportVersion,
+				Mode:    dto.ImportConflictSkip,
+				Users:   dto.ImportCounts{Created: 1},
+			},
+		},
+		{
+			name: "Skip leaves users of another tenant alone",
+			mode: dto.ImportConflictSkip,
+			input: func() *bytes.Buffer {
+				bound := user
+				bound.Tenant = &insurer
+				return exportLines(t, header, bound)
+			},
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserTenantSql), int64(1)).Return(pgxpoolmock.NewRow("bank"))
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictSkip,
+				Users:       dto.ImportCounts{Skipped: 1},
+				Credentials: dto.ImportCounts{Skipped: 1},
+			},
+		},
+		{
+			name:   "Overwrite never moves a user to another tenant",
+			mode:   dto.ImportConflictOverwrite,
+			tenant: &insurer,
+			input:  func() *bytes.Buffer { return exportLines(t, header, user) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserTenantSql), int64(1)).Return(pgxpoolmock.NewRow("bank"))
+			},
+			wantErr: ErrImportConflict,
+		},
+		{
+			name:  "Fail stops at the first existing record",
+			mode:  dto.ImportConflictFail,
+			input: func() *bytes.Buffer { return exportLines(t, header, user) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+			},
+			wantErr: ErrImportConflict,
+		},
+		{
+			name: "Invalid public key",
+			mode: dto.ImportConflictSkip,
+			input: func() *bytes.Buffer {
+				invalid := user
+				invalid.Credentials = []dto.ExportedCredential{{Credential: *buildWebAuthnCredential("credential-id")}}
+				return exportLines(t, header, invalid)
+			},
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(existingUser())
+			},
+			wantErr: ErrInvalidImport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mocker := mockPool.EXPECT()
+			mocker.Begin(gomock.Any()).Return(mockPool, nil)
+			mocker.Rollback(gomock.Any()).Return(nil)
+			tt.setup(mocker)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			got, err := s.Import(tt.input(), dto.ExportFormatJSONL, tt.mode, tt.tenant)
+
+			// Then
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Import() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error = %v, want nil", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("Import() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_Import_UnsupportedVersion(t *testing.T) {
+	for _, format := range []dto.ExportFormat{dto.ExportFormatJSON, dto.ExportFormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			// Given
+			setupTest(t)
+			input := `{"version":99,"users":[]}`
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			_, err = s.Import(strings.NewReader(input), format, dto.ImportConflictSkip, nil)
+
+			// Then
+			if !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("Import() error = %v, want %v", err, ErrInvalidImport)
+			}
+		})
+	}
+}
+
+func TestCredentialService_Export(t *testing.T) {
+	userRows := []string{"_id", "ref_id", "raw_id", "name", "display_name"}
+	for _, format := range []dto.ExportFormat{dto.ExportFormatJSON, dto.ExportFormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mocker := mockPool.EXPECT()
+			mocker.Begin(gomock.Any()).Return(mockPool, nil)
+			mocker.Rollback(gomock.Any()).Return(nil)
+			mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("REPEATABLE READ")).Return(pgconn.NewCommandTag("SET"), nil)
+			mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM webauthn_users.*ILIKE)"), pgtype.Text{}, int64(0), int32(exportPageSize)).Return(
+				pgxpoolmock.NewRows(userRows).AddRow(int64(1), "u1", []byte("raw-u1"), "name", "display").ToPgxRows(),
+				nil,
+			)
+			mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM webauthn_users.*ILIKE)"), pgtype.Text{}, int64(1), int32(exportPageSize)).Return(
+				pgxpoolmock.NewRows(userRows).ToPgxRows(),
+				nil,
+			)
+			mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:FROM webauthn_credentials.*WHERE user_id = \\$1.*ORDER BY)"), pgtype.Int8{Int64: 1, Valid: true}).Return(
+				pgxpoolmock.NewRows(credentialRows).AddRow(mockCredentialRow("credential-id", false, "laptop")).ToPgxRows(),
+				nil,
+			)
+			mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserTenantSql), int64(1)).Return(pgxpoolmock.NewRow("bank"))
+			var buffer bytes.Buffer
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			summary, err := s.Export(&buffer, format)
+
+			// Then
+			if err != nil {
+				t.Fatalf("Export() error = %v, want nil", err)
+			}
+			if summary.Users != 1 || summary.Credentials != 1 {
+				t.Errorf("Export() = %+v, want 1 user and 1 credential", summary)
+			}
+
+			// The export reads back with the importer
+			version, next, err := newExportReader(&buffer, format)
+			if err != nil {
+				t.Fatalf("newExportReader() error = %v, output %q", err, buffer.String())
+			}
+			if version != dto.ExportVersion {
+				t.Errorf("newExportReader() version = %v, want %v", version, dto.ExportVersion)
+			}
+			user, err := next()
+			if err != nil {
+				t.Fatalf("next() error = %v, want the exported user", err)
+			}
+			if user.UserID != "u1" || string(user.RawID) != "raw-u1" || len(user.Credentials) != 1 {
+				t.Errorf("next() = %+v, want u1 with one credential", user)
+			}
+			if user.Tenant == nil || *user.Tenant != "bank" {
+				t.Errorf("next() tenant = %v, want bank", user.Tenant)
+			}
+			if got := protocol.URLEncodedBase64(user.Credentials[0].ID).String(); got != protocol.URLEncodedBase64("credential-id").String() {
+				t.Errorf("next() credential = %v, want credential-id", got)
+			}
+			if !strings.Contains(string(user.Credentials[0].Meta), `"nickname":"laptop"`) {
+				t.Errorf("next() meta = %s, want the stored meta", user.Credentials[0].Meta)
+			}
+			if _, err := next(); err == nil {
+				t.Errorf("next() error = nil, want io.EOF after the last user")
+			}
+		})
+	}
+}
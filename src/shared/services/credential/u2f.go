@@ -0,0 +1,169 @@
+package credential_service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+// U2F key handles are at most 255 bytes, the length is a single byte of the registration response
+const maxU2FKeyHandleSize = 255
+
+const defaultU2FNickname = "Security key"
+
+// validateU2FPublicKey checks the raw U2F public key is an uncompressed point on P-256.
+// The go-webauthn library verifies AppID assertions with the raw key, so it is stored as is instead of as a COSE key.
+func validateU2FPublicKey(publicKey []byte) error {
+	if len(publicKey) != 65 || publicKey[0] != 4 {
+		return fmt.Errorf("expected a 65 byte uncompressed point")
+	}
+	if _, err := ecdh.P256().NewPublicKey(publicKey); err != nil {
+		return fmt.Errorf("invalid point: %w", err)
+	}
+	return nil
+}
+
+// validateAppID checks the AppID is an https URL, the only kind browsers accept for the appid extension
+func validateAppID(appID string) error {
+	parsed, err := url.Parse(appID)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%w: AppID must be an https URL", ErrInvalidImport)
+	}
+	return nil
+}
+
+// U2FCredential converts a legacy U2F registration to a credential with the fido-u2f attestation type.
+// Transports default to USB, the only transport most U2F keys have.
+func U2FCredential(user *UserModel, registration dto.U2FRegistration, appID string) (*CredentialModel, error) {
+	if len(registration.KeyHandle) == 0 || len(registration.KeyHandle) > maxU2FKeyHandleSize {
+		return nil, fmt.Errorf("%w: keyHandle is required and must be at most %v bytes", ErrInvalidImport, maxU2FKeyHandleSize)
+	}
+	if err := validateU2FPublicKey(registration.PublicKey); err != nil {
+		return nil, fmt.Errorf("%w: key handle %v: invalid public key: %v", ErrInvalidImport, registration.KeyHandle, err)
+	}
+
+	transports := registration.Transports
+	if len(transports) == 0 {
+		transports = []protocol.AuthenticatorTransport{protocol.USB}
+	}
+	nickname := registration.Nickname
+	if nickname == "" {
+		nickname = defaultU2FNickname
+	}
+
+	credential := &CredentialModel{
+		Credential: webauthn.Credential{
+			ID:              registration.KeyHandle,
+			PublicKey:       registration.PublicKey,
+			AttestationType: string(protocol.CredentialTypeFIDOU2F),
+			Transport:       transports,
+			Flags:           webauthn.CredentialFlags{UserPresent: true},
+			Authenticator: webauthn.Authenticator{
+				SignCount:  registration.Counter,
+				Attachment: protocol.CrossPlatform,
+			},
+		},
+		Meta: CredentialMeta{
+			Status:   CredentialStatusActive,
+			Nickname: nickname,
+			AppID:    appID,
+		},
+	}
+	credential.SetUser(user)
+	return credential, nil
+}
+
+// importU2FUser finds the user of the registration or creates them with a new raw ID, U2F has no user handle to keep
+func (s *CredentialService) importU2FUser(txn *credentials.Queries, registration dto.U2FRegistration, counts *dto.ImportCounts) (*UserModel, error) {
+	if registration.UserID == "" || len(registration.UserID) > 100 {
+		return nil, fmt.Errorf("%w: userId is required and must be at most 100 characters", ErrInvalidImport)
+	}
+
+	user, err := txn.GetUserByRef(s.ctx, registration.UserID)
+	if err == pgx.ErrNoRows {
+		if registration.UserName == "" {
+			return nil, fmt.Errorf("%w: userName is required for new users", ErrInvalidImport)
+		}
+		rawId := make([]byte, 32)
+		if _, err := rand.Read(rawId); err != nil {
+			return nil, fmt.Errorf("failed to generate raw ID: %v", err)
+		}
+		user, err = txn.InsertUser(s.ctx, credentials.InsertUserParams{
+			RefID:       registration.UserID,
+			RawID:       rawId,
+			Name:        registration.UserName,
+			DisplayName: registration.DisplayName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+		counts.Created++
+	} else if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	return UserModelFromDatabase(user), nil
+}
+
+// ImportU2F reads legacy U2F registrations, one JSON object per line, and stores them as fido-u2f credentials scoped to the AppID.
+// Users that do not exist yet are created, existing users are left as they are. Credentials that already exist are handled according to the mode.
+// Like Import, everything is imported in a single transaction and no webhooks are sent.
+func (s *CredentialService) ImportU2F(r io.Reader, appID string, mode dto.ImportConflictMode) (*dto.ImportResponse, error) {
+	if err := validateAppID(appID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	response := &dto.ImportResponse{Version: dto.ExportVersion, Mode: mode}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var registration dto.U2FRegistration
+		if err := json.Unmarshal(scanner.Bytes(), &registration); err != nil {
+			return nil, fmt.Errorf("%w: line %v: %v", ErrInvalidImport, line, err)
+		}
+
+		user, err := s.importU2FUser(txn, registration, &response.Users)
+		if err != nil {
+			return nil, fmt.Errorf("line %v (%q): %w", line, registration.UserID, err)
+		}
+		credential, err := U2FCredential(user, registration, appID)
+		if err != nil {
+			return nil, fmt.Errorf("line %v (%q): %w", line, registration.UserID, err)
+		}
+		if err := s.importCredential(txn, user, credential, mode, &response.Credentials); err != nil {
+			return nil, fmt.Errorf("line %v (%q): key handle %v: %w", line, registration.UserID, registration.KeyHandle, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return response, nil
+}
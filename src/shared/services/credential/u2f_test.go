@@ -0,0 +1,276 @@
+package credential_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+const testAppID = "https://example.com/u2f/app-id.json"
+
+func u2fPublicKey(t *testing.T) []byte {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key.PublicKey().Bytes()
+}
+
+func u2fLines(t *testing.T, registrations ...dto.U2FRegistration) *bytes.Buffer {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, registration := range registrations {
+		if err := encoder.Encode(registration); err != nil {
+			t.Fatalf("failed to encode registration: %v", err)
+		}
+	}
+	return &buffer
+}
+
+func TestValidateU2FPublicKey(t *testing.T) {
+	compressed := u2fPublicKey(t)[:33]
+	compressed[0] = 2
+	notOnCurve := append([]byte{4}, bytes.Repeat([]byte{1}, 64)...)
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr bool
+	}{
+		{name: "Valid P-256 point", key: u2fPublicKey(t), wantErr: false},
+		{name: "Point not on the curve", key: notOnCurve, wantErr: true},
+		{name: "Compressed point", key: compressed, wantErr: true},
+		{name: "COSE key", key: validPublicKey(t), wantErr: true},
+		{name: "Empty", key: []byte{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := validateU2FPublicKey(tt.key)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateU2FPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestU2FCredential(t *testing.T) {
+	// Given
+	user := &UserModel{ID: 1, RefID: "u1"}
+	registration := dto.U2FRegistration{
+		UserID:    "u1",
+		KeyHandle: []byte("key-handle"),
+		PublicKey: u2fPublicKey(t),
+		Counter:   42,
+	}
+
+	// When
+	got, err := U2FCredential(user, registration, testAppID)
+
+	// Then
+	if err != nil {
+		t.Fatalf("U2FCredential() error = %v, want nil", err)
+	}
+	if got.AttestationType != string(protocol.CredentialTypeFIDOU2F) {
+		t.Errorf("AttestationType = %v, want %v", got.AttestationType, protocol.CredentialTypeFIDOU2F)
+	}
+	if !bytes.Equal(got.PublicKey, registration.PublicKey) {
+		t.Errorf("PublicKey = %x, want the raw U2F key %x", got.PublicKey, registration.PublicKey)
+	}
+	if got.Authenticator.SignCount != 42 {
+		t.Errorf("SignCount = %v, want 42", got.Authenticator.SignCount)
+	}
+	if len(got.Transport) != 1 || got.Transport[0] != protocol.USB {
+		t.Errorf("Transport = %v, want [usb]", got.Transport)
+	}
+	wantMeta := CredentialMeta{Status: CredentialStatusActive, Nickname: defaultU2FNickname, AppID: testAppID}
+	if got.Meta != wantMeta {
+		t.Errorf("Meta = %+v, want %+v", got.Meta, wantMeta)
+	}
+	if got.User.Value.ID != 1 {
+		t.Errorf("User = %+v, want user 1", got.User.Value)
+	}
+}
+
+func TestUserModel_LegacyAppID(t *testing.T) {
+	u2f := CredentialModel{Meta: CredentialMeta{AppID: testAppID}}
+	u2f.AttestationType = string(protocol.CredentialTypeFIDOU2F)
+	passkey := CredentialModel{Meta: CredentialMeta{AppID: "https://other.example.com"}}
+	passkey.AttestationType = "packed"
+
+	tests := []struct {
+		name string
+		user *UserModel
+		want string
+	}{
+		{name: "Nil user", user: nil, want: ""},
+		{name: "Credentials not loaded", user: &UserModel{}, want: ""},
+		{
+			name: "Only passkeys",
+			user: &UserModel{Credentials: CredentialRelationship{Loaded: true, Value: []CredentialModel{passkey}}},
+			want: "",
+		},
+		{
+			name: "U2F credential",
+			user: &UserModel{Credentials: CredentialRelationship{Loaded: true, Value: []CredentialModel{passkey, u2f}}},
+			want: testAppID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got := tt.user.LegacyAppID()
+
+			// Then
+			if got != tt.want {
+				t.Errorf("LegacyAppID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_ImportU2F(t *testing.T) {
+	registration := dto.U2FRegistration{
+		UserID:      "u1",
+		UserName:    "name",
+		DisplayName: "display",
+		KeyHandle:   []byte("key-handle"),
+		PublicKey:   u2fPublicKey(t),
+		Counter:     7,
+	}
+	noCredential := func() *pgxpoolmock.Row {
+		return pgxpoolmock.NewRow(
+			[]byte{}, pgtype.Int8{}, int32(0), []byte{}, pgtype.Text{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, []byte{},
+			int64(0), "", []byte{}, "", "",
+		).WithError(pgx.ErrNoRows)
+	}
+
+	tests := []struct {
+		name    string
+		input   func() *bytes.Buffer
+		setup   func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder)
+		want    *dto.ImportResponse
+		wantErr error
+	}{
+		{
+			name:  "New user gets a fido-u2f credential scoped to the AppID",
+			input: func() *bytes.Buffer { return u2fLines(t, registration) },
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.Commit(gomock.Any()).Return(nil)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(
+					pgxpoolmock.NewRow(int64(0), "", []byte{}, "", "").WithError(pgx.ErrNoRows),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_users)"), "u1", gomock.Any(), "name", "display").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getCredentialSql), []byte("key-handle")).Return(noCredential())
+				mocker.QueryRow(
+					gomock.Any(),
+					pgxpoolmock.QueryContains("(?ms:INSERT INTO webauthn_credentials)"),
+					[]byte("key-handle"),
+					pgtype.Int8{Int64: 1, Valid: true},
+					[]byte(registration.PublicKey),
+					pgtype.Text{String: "fido-u2f", Valid: true},
+					[]byte(`["usb"]`),
+					gomock.Any(),
+					gomock.Any(),
+					gomock.Any(),
+					[]byte(`{"status":"active","nickname":"Security key","appId":"`+testAppID+`"}`),
+					gomock.Any(),
+				).Return(pgxpoolmock.NewRow(mockCredentialRow("key-handle", false, "")))
+				mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SET use_counter = \\$2)"), []byte("key-handle"), int32(7)).Return(
+					pgconn.NewCommandTag("UPDATE 1"), nil,
+				)
+			},
+			want: &dto.ImportResponse{
+				Version:     dto.ExportVersion,
+				Mode:        dto.ImportConflictSkip,
+				Users:       dto.ImportCounts{Created: 1},
+				Credentials: dto.ImportCounts{Created: 1},
+			},
+		},
+		{
+			name: "Invalid public key",
+			input: func() *bytes.Buffer {
+				invalid := registration
+				invalid.PublicKey = validPublicKey(t)
+				return u2fLines(t, invalid)
+			},
+			setup: func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {
+				mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getUserByRefSql), "u1").Return(
+					pgxpoolmock.NewRow(int64(1), "u1", []byte("raw-u1"), "name", "display"),
+				)
+			},
+			wantErr: ErrInvalidImport,
+		},
+		{
+			name:    "Malformed line",
+			input:   func() *bytes.Buffer { return bytes.NewBufferString("{not json}\n") },
+			setup:   func(mocker *pgxpoolmock.MockPgxIfaceMockRecorder) {},
+			wantErr: ErrInvalidImport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mocker := mockPool.EXPECT()
+			mocker.Begin(gomock.Any()).Return(mockPool, nil)
+			mocker.Rollback(gomock.Any()).Return(nil)
+			tt.setup(mocker)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Errorf("New() error = %v, want nil", err)
+			}
+			got, err := s.ImportU2F(tt.input(), testAppID, dto.ImportConflictSkip)
+
+			// Then
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ImportU2F() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportU2F() error = %v, want nil", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("ImportU2F() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialService_ImportU2F_InvalidAppID(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+	_, err = s.ImportU2F(strings.NewReader(""), "http://example.com", dto.ImportConflictSkip)
+
+	// Then
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("ImportU2F() error = %v, want %v", err, ErrInvalidImport)
+	}
+}
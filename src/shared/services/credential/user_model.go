@@ -1,8 +1,11 @@
 package credential_service
 
 import (
-	"blacksmithlabs.dev/webauthn-k8s/auth/utils"
+	"log/slog"
+
 	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -47,6 +50,33 @@ func (u *UserModel) WebAuthnCredentials() []webauthn.Credential {
 	})
 }
 
+// LegacyAppID returns the U2F AppID of the user's first fido-u2f credential, or an empty string when they have none.
+// Browsers accept a single AppID per ceremony, so keys registered under different AppIDs can not be used together.
+func (u *UserModel) LegacyAppID() string {
+	if u == nil || !u.Credentials.Loaded {
+		return ""
+	}
+
+	for _, credential := range u.Credentials.Value {
+		if credential.AttestationType == string(protocol.CredentialTypeFIDOU2F) && credential.Meta.AppID != "" {
+			return credential.Meta.AppID
+		}
+	}
+	return ""
+}
+
+// LogValue only exposes identifiers so names and key material stay out of the logs
+func (u UserModel) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int64("id", u.ID),
+		slog.String("refId", u.RefID),
+	}
+	if u.Credentials.Loaded {
+		attrs = append(attrs, slog.Int("credentials", len(u.Credentials.Value)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
 func (u *UserModel) linkCredential(credential CredentialModel) {
 	if credential.User.Value.ID == 0 || credential.User.Value.ID == u.ID {
 		u.Credentials.Loaded = true
@@ -0,0 +1,107 @@
+package event_publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+// EventPublisher sends ceremony events to internal consumers
+type EventPublisher interface {
+	Publish(ctx context.Context, event dto.Event, tenant string) error
+}
+
+// RedisStreamPublisher appends events to a capped Redis Stream
+type RedisStreamPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamPublisher creates a publisher appending to the stream, trimmed to about maxLen entries
+func NewRedisStreamPublisher(client *redis.Client, stream string, maxLen int64) *RedisStreamPublisher {
+	return &RedisStreamPublisher{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+// streamValues flattens the envelope into stream entry fields so consumers can filter without decoding the payload
+func streamValues(envelope *dto.EventEnvelope) map[string]any {
+	return map[string]any{
+		"id":         envelope.ID,
+		"type":       string(envelope.Type),
+		"version":    envelope.Version,
+		"occurredAt": envelope.OccurredAt.UnixMilli(),
+		"tenant":     envelope.Tenant,
+		"data":       string(envelope.Data),
+	}
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event dto.Event, tenant string) error {
+	envelope, err := dto.NewEventEnvelope(event, tenant)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: true,
+		Values: streamValues(envelope),
+	}).Err(); err != nil {
+		return fmt.Errorf("failed to publish %v event: %w", envelope.Type, err)
+	}
+
+	return nil
+}
+
+// RedisPubSubPublisher broadcasts events on a Redis pub/sub channel, only subscribers listening at the time receive them
+type RedisPubSubPublisher struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisPubSubPublisher creates a publisher broadcasting on the channel
+func NewRedisPubSubPublisher(client *redis.Client, channel string) *RedisPubSubPublisher {
+	return &RedisPubSubPublisher{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (p *RedisPubSubPublisher) Publish(ctx context.Context, event dto.Event, tenant string) error {
+	envelope, err := dto.NewEventEnvelope(event, tenant)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %v event: %w", envelope.Type, err)
+	}
+
+	if err := p.client.Publish(ctx, p.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to broadcast %v event: %w", envelope.Type, err)
+	}
+
+	return nil
+}
+
+// MultiPublisher publishes every event to each of its publishers, one failing does not stop the others
+type MultiPublisher []EventPublisher
+
+func (p MultiPublisher) Publish(ctx context.Context, event dto.Event, tenant string) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event, tenant); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,68 @@
+package event_publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+func TestStreamValues(t *testing.T) {
+	envelope, err := dto.NewEventEnvelope(dto.AuthenticationFailedEvent{
+		UserID: "user-ref",
+		Reason: "bad signature",
+	}, "tenant")
+	if err != nil {
+		t.Fatalf("NewEventEnvelope() error = %v, want nil", err)
+	}
+
+	values := streamValues(envelope)
+
+	if values["type"] != string(dto.EventTypeAuthenticationFailed) {
+		t.Errorf("streamValues() type = %v, want %v", values["type"], dto.EventTypeAuthenticationFailed)
+	}
+	if values["version"] != dto.EventSchemaVersion {
+		t.Errorf("streamValues() version = %v, want %v", values["version"], dto.EventSchemaVersion)
+	}
+	if values["tenant"] != "tenant" {
+		t.Errorf("streamValues() tenant = %v, want %v", values["tenant"], "tenant")
+	}
+
+	var data dto.AuthenticationFailedEvent
+	if err := json.Unmarshal([]byte(values["data"].(string)), &data); err != nil {
+		t.Fatalf("streamValues() data is not valid JSON: %v", err)
+	}
+	if data.UserID != "user-ref" || data.Reason != "bad signature" {
+		t.Errorf("streamValues() data = %+v, want the original event", data)
+	}
+}
+
+type recordingPublisher struct {
+	events []dto.Event
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event dto.Event, tenant string) error {
+	p.events = append(p.events, event)
+	return p.err
+}
+
+func TestMultiPublisher_Publish(t *testing.T) {
+	// Given
+	failing := &recordingPublisher{err: fmt.Errorf("stream unavailable")}
+	working := &recordingPublisher{}
+	publisher := MultiPublisher{failing, working}
+
+	// When
+	err := publisher.Publish(context.Background(), dto.AuthenticationFailedEvent{UserID: "user-ref"}, "tenant")
+
+	// Then
+	if err == nil {
+		t.Errorf("Publish() error = nil, want the failing publisher's error")
+	}
+	if len(failing.events) != 1 || len(working.events) != 1 {
+		t.Errorf("Publish() reached %v and %v publishers, want both", len(failing.events), len(working.events))
+	}
+}
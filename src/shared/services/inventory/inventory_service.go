@@ -0,0 +1,102 @@
+package inventory_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+// InventoryService keeps the serial numbers of the company-issued authenticators tenants accept with enterprise attestation
+type InventoryService struct {
+	ctx     context.Context
+	conn    database.DBConn
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new InventoryService instance
+func New(ctx context.Context) (*InventoryService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InventoryService{
+		ctx:     ctx,
+		conn:    pool,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+// Serials are compared without surrounding whitespace, which spreadsheet exports tend to add
+func normalizeSerial(serial string) string {
+	return strings.TrimSpace(serial)
+}
+
+// Replace swaps the tenant's inventory for the uploaded devices in one transaction, so registrations never see half a list
+func (s *InventoryService) Replace(tenant string, devices []dto.InventoryDevice) (*dto.InventoryResponse, error) {
+	tx, err := s.conn.Begin(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	txn := s.queries.WithTx(tx)
+
+	defer tx.Rollback(s.ctx)
+
+	if _, err := txn.DeleteTenantInventory(s.ctx, tenant); err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	for _, device := range devices {
+		if err := txn.InsertInventoryDevice(s.ctx, credentials.InsertInventoryDeviceParams{
+			Tenant: tenant,
+			Serial: normalizeSerial(device.Serial),
+			Label:  device.Label,
+		}); err != nil {
+			return nil, fmt.Errorf("data access error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return s.Summary(tenant)
+}
+
+// Summary counts the devices in the tenant's inventory
+func (s *InventoryService) Summary(tenant string) (*dto.InventoryResponse, error) {
+	row, err := s.queries.GetTenantInventorySummary(s.ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	response := &dto.InventoryResponse{Tenant: tenant, Devices: row.DeviceCount}
+	if row.UploadedAt.Valid {
+		response.UploadedAt = &row.UploadedAt.Time
+	}
+	return response, nil
+}
+
+// Contains reports whether the device with the serial number is in the tenant's inventory
+func (s *InventoryService) Contains(tenant string, serial string) (bool, error) {
+	_, err := s.queries.GetInventoryDevice(s.ctx, credentials.GetInventoryDeviceParams{
+		Tenant: tenant,
+		Serial: normalizeSerial(serial),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+	return true, nil
+}
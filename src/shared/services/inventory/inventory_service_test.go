@@ -0,0 +1,106 @@
+package inventory_service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+var uploadedAt = pgtype.Timestamptz{Time: time.Date(2024, 11, 25, 12, 0, 0, 0, time.UTC), Valid: true}
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func TestInventoryService_Replace(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Begin(gomock.Any()).Return(mockPool, nil)
+	mocker.Commit(gomock.Any()).Return(nil)
+	mocker.Rollback(gomock.Any()).Return(nil)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:DELETE FROM device_inventory.*)"), "corp").
+		Return(pgconn.NewCommandTag("DELETE 5"), nil)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO device_inventory.*)"), "corp", "12345678", "Alice's key").
+		Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:INSERT INTO device_inventory.*)"), "corp", "87654321", "").
+		Return(pgconn.NewCommandTag("INSERT 0 1"), nil)
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT COUNT.*FROM device_inventory.*)"), "corp").
+		Return(pgxpoolmock.NewRow(int64(2), uploadedAt))
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	got, err := s.Replace("corp", []dto.InventoryDevice{
+		{Serial: "12345678", Label: "Alice's key"},
+		{Serial: " 87654321\r"},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Replace() error = %v, want nil", err)
+	}
+	if got.Devices != 2 || got.UploadedAt == nil || !got.UploadedAt.Equal(uploadedAt.Time) {
+		t.Errorf("Replace() = %+v, want 2 devices uploaded at %v", got, uploadedAt.Time)
+	}
+}
+
+func TestInventoryService_Contains(t *testing.T) {
+	const getDeviceSql = "(?ms:SELECT.*FROM device_inventory.*WHERE tenant.*)"
+	tests := []struct {
+		name string
+		row  *pgxpoolmock.Row
+		want bool
+	}{
+		{name: "Company-issued device", row: pgxpoolmock.NewRow("corp", "12345678", "", uploadedAt), want: true},
+		{name: "Unknown device", row: pgxpoolmock.NewRow("", "", "", pgtype.Timestamptz{}).WithError(pgx.ErrNoRows), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setupTest(t)
+			mockPool.EXPECT().QueryRow(gomock.Any(), pgxpoolmock.QueryContains(getDeviceSql), "corp", "12345678").Return(tt.row)
+
+			// When
+			s, err := New(context.Background())
+			if err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			got, err := s.Contains("corp", "12345678 ")
+
+			// Then
+			if err != nil {
+				t.Fatalf("Contains() error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package metadata_service
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrNoSerialNumber is returned for attestations without device identifying data, i.e. anything but enterprise attestation
+var ErrNoSerialNumber = errors.New("attestation carries no device serial number")
+
+// Yubico puts the device serial in this extension of enterprise attestation certificates, as an INTEGER
+var yubicoSerialNumberOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 13, 1}
+
+// AttestationSerial returns the device serial number of an enterprise attestation, from the vendor extension of the
+// attestation certificate or its subject serialNumber. Trust in the certificate is established by go-webauthn against
+// the metadata, this only reads it.
+func AttestationSerial(credential *webauthn.Credential) (string, error) {
+	var object protocol.AttestationObject
+	if err := webauthncbor.Unmarshal(credential.Attestation.Object, &object); err != nil {
+		return "", fmt.Errorf("invalid attestation object: %w", err)
+	}
+	x5c, ok := object.AttStatement["x5c"].([]any)
+	if !ok || len(x5c) == 0 {
+		return "", ErrNoSerialNumber
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return "", fmt.Errorf("invalid attestation certificate")
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", fmt.Errorf("invalid attestation certificate: %w", err)
+	}
+
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(yubicoSerialNumberOID) {
+			continue
+		}
+		var serial *big.Int
+		if _, err := asn1.Unmarshal(extension.Value, &serial); err != nil {
+			return "", fmt.Errorf("invalid serial number extension: %w", err)
+		}
+		return serial.String(), nil
+	}
+	if certificate.Subject.SerialNumber != "" {
+		return certificate.Subject.SerialNumber, nil
+	}
+	return "", ErrNoSerialNumber
+}
@@ -0,0 +1,77 @@
+package metadata_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func attestationCertificate(t *testing.T, subject pkix.Name, extensions []pkix.Extension) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         subject,
+		NotBefore:       testNow.AddDate(-1, 0, 0),
+		NotAfter:        testNow.AddDate(1, 0, 0),
+		ExtraExtensions: extensions,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func attestedCredential(t *testing.T, format string, statement map[string]any) *webauthn.Credential {
+	object, err := webauthncbor.Marshal(map[string]any{"fmt": format, "attStmt": statement, "authData": []byte{}})
+	if err != nil {
+		t.Fatalf("failed to encode attestation object: %v", err)
+	}
+	return &webauthn.Credential{AttestationType: format, Attestation: webauthn.CredentialAttestation{Object: object}}
+}
+
+func TestAttestationSerial(t *testing.T) {
+	serial, err := asn1.Marshal(big.NewInt(28471123))
+	if err != nil {
+		t.Fatalf("failed to encode serial: %v", err)
+	}
+	yubico := attestationCertificate(t, pkix.Name{CommonName: "Yubico U2F EE"}, []pkix.Extension{{Id: yubicoSerialNumberOID, Value: serial}})
+	subject := attestationCertificate(t, pkix.Name{CommonName: "Key", SerialNumber: "SN-0042"}, nil)
+	batch := attestationCertificate(t, pkix.Name{CommonName: "Batch attestation"}, nil)
+
+	tests := []struct {
+		name       string
+		credential *webauthn.Credential
+		want       string
+		wantErr    error
+	}{
+		{name: "Vendor serial extension", credential: attestedCredential(t, "packed", map[string]any{"alg": -7, "x5c": []any{yubico}}), want: "28471123"},
+		{name: "Subject serial number", credential: attestedCredential(t, "packed", map[string]any{"alg": -7, "x5c": []any{subject}}), want: "SN-0042"},
+		{name: "Batch attestation", credential: attestedCredential(t, "packed", map[string]any{"alg": -7, "x5c": []any{batch}}), wantErr: ErrNoSerialNumber},
+		{name: "Self attestation", credential: attestedCredential(t, "packed", map[string]any{"alg": -7}), wantErr: ErrNoSerialNumber},
+		{name: "No attestation", credential: attestedCredential(t, "none", map[string]any{}), wantErr: ErrNoSerialNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got, err := AttestationSerial(tt.credential)
+
+			// Then
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("AttestationSerial() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
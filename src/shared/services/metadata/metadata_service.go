@@ -0,0 +1,184 @@
+package metadata_service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/go-webauthn/webauthn/metadata/providers/memory"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+var logger = utils.GetLogger()
+
+// The FIDO Alliance signs production BLOBs with RS256, the others are accepted for test and conformance BLOBs
+var blobSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512"}
+
+// blobClaims is the BLOB payload, the metadata dictionary carries no registered JWT claims
+type blobClaims struct {
+	metadata.PayloadJSON
+	jwt.RegisteredClaims
+}
+
+// LoadRoots reads the PEM certificates BLOB signing chains must lead to.
+// Without a path the FIDO Alliance production root is used.
+func LoadRoots(path string) (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	if path == "" {
+		der, err := base64.StdEncoding.DecodeString(metadata.ProductionMDSRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode production root: %w", err)
+		}
+		root, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse production root: %w", err)
+		}
+		roots.AddCert(root)
+		return roots, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata root: %w", err)
+	}
+	count := 0
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		root, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metadata root: %w", err)
+		}
+		roots.AddCert(root)
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no certificates in %v", path)
+	}
+	return roots, nil
+}
+
+// ParseBlob verifies the BLOB signature and its x5c chain against the roots and parses the metadata entries.
+// Certificate revocation lists are not fetched so BLOBs can be verified offline, a revoked signing certificate is
+// dealt with by mounting a newer BLOB. Entries the library can not parse are logged and left out.
+func ParseBlob(blob []byte, roots *x509.CertPool, now time.Time) (*metadata.Metadata, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(blobSigningMethods), jwt.WithTimeFunc(func() time.Time { return now }))
+
+	var claims blobClaims
+	_, err := parser.ParseWithClaims(string(blob), &claims, func(token *jwt.Token) (any, error) {
+		x5c, ok := token.Header["x5c"].([]any)
+		if !ok || len(x5c) == 0 {
+			return nil, fmt.Errorf("BLOB has no x5c certificate chain")
+		}
+
+		chain := make([]*x509.Certificate, len(x5c))
+		for i, value := range x5c {
+			encoded, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("x5c certificate %v is not a string", i)
+			}
+			der, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("x5c certificate %v: %w", i, err)
+			}
+			if chain[i], err = x509.ParseCertificate(der); err != nil {
+				return nil, fmt.Errorf("x5c certificate %v: %w", i, err)
+			}
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, certificate := range chain[1:] {
+			intermediates.AddCert(certificate)
+		}
+		if _, err := chain[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return nil, fmt.Errorf("BLOB signing certificate is not trusted: %w", err)
+		}
+
+		return chain[0].PublicKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata BLOB: %w", err)
+	}
+
+	decoder, err := metadata.NewDecoder(metadata.WithIgnoreEntryParsingErrors())
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := decoder.Parse(&claims.PayloadJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata BLOB: %w", err)
+	}
+	for _, unparsed := range parsed.Unparsed {
+		logger.Warn("Skipped metadata entry", "aaguid", unparsed.EntryJSON.AaGUID, "error", unparsed.Error)
+	}
+	if parsed.Parsed.NextUpdate.Before(now) {
+		logger.Warn("Metadata BLOB is past its next update, mount a newer one", "number", parsed.Parsed.Number, "nextUpdate", parsed.Parsed.NextUpdate)
+	}
+
+	return parsed, nil
+}
+
+// LoadBlob reads and verifies the BLOB mounted at path
+func LoadBlob(path string, roots *x509.CertPool) (*metadata.Metadata, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata BLOB: %w", err)
+	}
+	return ParseBlob(blob, roots, time.Now())
+}
+
+// NewProvider builds the provider go-webauthn verifies attestations with. Every registration must have an entry
+// for its AAGUID, attestation certificates must chain to the entry's attestation roots and authenticators with
+// an undesired status, REVOKED and USER_KEY_REMOTE_COMPROMISE among them, are rejected.
+func NewProvider(parsed *metadata.Metadata) (metadata.Provider, error) {
+	return memory.New(
+		memory.WithMetadata(parsed.ToMap()),
+		memory.WithValidateEntry(true),
+		memory.WithValidateEntryPermitZeroAAGUID(false),
+		memory.WithValidateTrustAnchor(true),
+		memory.WithValidateStatus(true),
+		memory.WithValidateAttestationTypes(true),
+		memory.WithStatusUndesired(metadata.DefaultUndesiredAuthenticatorStatuses()),
+	)
+}
+
+// CheckCredential re-evaluates a stored credential against the current metadata, e.g. after a newer BLOB marked its
+// authenticator model REVOKED or USER_KEY_REMOTE_COMPROMISE. Models without an entry pass, they were never attested.
+// With verifyAttestation the stored attestation must also still chain to the metadata roots, as at registration.
+func CheckCredential(provider metadata.Provider, credential *webauthn.Credential, verifyAttestation bool) error {
+	if verifyAttestation && credential.AttestationType != string(protocol.AttestationFormatNone) {
+		if len(credential.Attestation.Object) == 0 {
+			return fmt.Errorf("no stored attestation to verify")
+		}
+		if err := credential.Verify(provider); err != nil {
+			return fmt.Errorf("attestation no longer verifies: %w", err)
+		}
+	}
+
+	aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+	if err != nil || aaguid == uuid.Nil {
+		return nil
+	}
+	ctx := context.Background()
+	entry, err := provider.GetEntry(ctx, aaguid)
+	if err != nil || entry == nil {
+		return nil
+	}
+	if err := provider.ValidateStatusReports(ctx, entry.StatusReports); err != nil {
+		return fmt.Errorf("authenticator model %v: %w", aaguid, err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package metadata_service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	certifiedAAGUID = "2fc0579f-8113-47ea-b116-bb5a8db9202a"
+	revokedAAGUID   = "ee882879-721c-4913-9775-3dfcce97072a"
+)
+
+var testNow = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newCertificate(t *testing.T, name string, parent *testCA) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             testNow.AddDate(-1, 0, 0),
+		NotAfter:              testNow.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return &testCA{certificate: certificate, key: key}
+}
+
+func entryJSON(aaguid string, status metadata.AuthenticatorStatus) map[string]any {
+	return map[string]any{
+		"aaguid": aaguid,
+		"metadataStatement": map[string]any{
+			"aaguid":           aaguid,
+			"description":      "Test key " + string(status),
+			"attestationTypes": []string{"basic_full"},
+		},
+		"statusReports":          []map[string]any{{"status": status, "effectiveDate": "2024-01-01"}},
+		"timeOfLastStatusChange": "2024-01-01",
+	}
+}
+
+func signBlob(t *testing.T, signer *testCA) []byte {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"legalHeader": "test",
+		"no":          7,
+		"nextUpdate":  "2026-11-01",
+		"entries": []map[string]any{
+			entryJSON(certifiedAAGUID, metadata.FidoCertified),
+			entryJSON(revokedAAGUID, metadata.Revoked),
+		},
+	})
+	token.Header["x5c"] = []string{base64.StdEncoding.EncodeToString(signer.certificate.Raw)}
+	signed, err := token.SignedString(signer.key)
+	if err != nil {
+		t.Fatalf("failed to sign BLOB: %v", err)
+	}
+	return []byte(signed)
+}
+
+func TestParseBlob(t *testing.T) {
+	root := newCertificate(t, "Metadata root", nil)
+	signer := newCertificate(t, "Metadata signer", root)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.certificate)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(newCertificate(t, "Other root", nil).certificate)
+
+	blob := signBlob(t, signer)
+	parts := strings.Split(string(blob), ".")
+	tampered := []byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"no":8,"nextUpdate":"2026-11-01","entries":[]}`)) + "." + parts[2])
+
+	tests := []struct {
+		name    string
+		blob    []byte
+		roots   *x509.CertPool
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "Signed by a trusted chain", blob: blob, roots: roots, now: testNow, wantErr: false},
+		{name: "Untrusted root", blob: blob, roots: otherRoots, now: testNow, wantErr: true},
+		{name: "Tampered payload", blob: tampered, roots: roots, now: testNow, wantErr: true},
+		{name: "Expired signing certificate", blob: blob, roots: roots, now: testNow.AddDate(2, 0, 0), wantErr: true},
+		{name: "Not a JWT", blob: []byte("blob"), roots: roots, now: testNow, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got, err := ParseBlob(tt.blob, tt.roots, tt.now)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBlob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Parsed.Number != 7 || len(got.Parsed.Entries) != 2 {
+				t.Errorf("ParseBlob() = number %v with %v entries, want number 7 with 2 entries", got.Parsed.Number, len(got.Parsed.Entries))
+			}
+		})
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	// Given
+	root := newCertificate(t, "Metadata root", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.certificate)
+	parsed, err := ParseBlob(signBlob(t, newCertificate(t, "Metadata signer", root)), roots, testNow)
+	if err != nil {
+		t.Fatalf("ParseBlob() error = %v", err)
+	}
+
+	// When
+	provider, err := NewProvider(parsed)
+
+	// Then
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	ctx := context.Background()
+	if !provider.GetValidateEntry(ctx) || !provider.GetValidateTrustAnchor(ctx) || provider.GetValidateEntryPermitZeroAAGUID(ctx) {
+		t.Errorf("NewProvider() must require an entry and a trusted attestation for every AAGUID")
+	}
+
+	tests := []struct {
+		name    string
+		aaguid  string
+		wantErr bool
+	}{
+		{name: "Certified authenticator", aaguid: certifiedAAGUID, wantErr: false},
+		{name: "Revoked authenticator", aaguid: revokedAAGUID, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := provider.GetEntry(ctx, uuid.MustParse(tt.aaguid))
+			if err != nil || entry == nil {
+				t.Fatalf("GetEntry() = %v, %v, want the entry", entry, err)
+			}
+			if err := provider.ValidateStatusReports(ctx, entry.StatusReports); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStatusReports() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCredential(t *testing.T) {
+	// Given
+	root := newCertificate(t, "Metadata root", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.certificate)
+	parsed, err := ParseBlob(signBlob(t, newCertificate(t, "Metadata signer", root)), roots, testNow)
+	if err != nil {
+		t.Fatalf("ParseBlob() error = %v", err)
+	}
+	provider, err := NewProvider(parsed)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		aaguid            string
+		attestationType   string
+		verifyAttestation bool
+		wantErr           bool
+	}{
+		{name: "Certified model", aaguid: certifiedAAGUID, attestationType: "none", wantErr: false},
+		{name: "Revoked model", aaguid: revokedAAGUID, attestationType: "none", wantErr: true},
+		{name: "Model without an entry", aaguid: "00000000-0000-0000-0000-000000000001", attestationType: "none", wantErr: false},
+		{name: "No stored attestation", aaguid: certifiedAAGUID, attestationType: "packed", verifyAttestation: true, wantErr: true},
+		{name: "Attestation not verified", aaguid: certifiedAAGUID, attestationType: "packed", verifyAttestation: false, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aaguid := uuid.MustParse(tt.aaguid)
+			credential := &webauthn.Credential{
+				AttestationType: tt.attestationType,
+				Authenticator:   webauthn.Authenticator{AAGUID: aaguid[:]},
+			}
+
+			// When
+			err := CheckCredential(provider, credential, tt.verifyAttestation)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRoots(t *testing.T) {
+	root := newCertificate(t, "Metadata root", nil)
+	dir := t.TempDir()
+	rootPath := filepath.Join(dir, "root.pem")
+	if err := os.WriteFile(rootPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.certificate.Raw}), 0600); err != nil {
+		t.Fatalf("failed to write root: %v", err)
+	}
+	emptyPath := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyPath, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "Production root", path: "", wantErr: false},
+		{name: "PEM file", path: rootPath, wantErr: false},
+		{name: "No certificates", path: emptyPath, wantErr: true},
+		{name: "Missing file", path: filepath.Join(dir, "missing.pem"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := LoadRoots(tt.path)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package metadata_service
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+// communityAAGUIDs follows the schema of the passkey developer community list, https://github.com/passkeydeveloper/passkey-authenticator-aaguids.
+// Only well known passkey providers are embedded, hardware keys are named by the FIDO metadata.
+//
+//go:embed aaguids.json
+var communityAAGUIDs []byte
+
+// Resolver names authenticator models from their AAGUID
+type Resolver struct {
+	authenticators map[uuid.UUID]dto.AuthenticatorInfo
+}
+
+// NewResolver builds a resolver from the embedded community list and, when given, the FIDO metadata.
+// Community names win since they are the names users know their passkey providers by.
+func NewResolver(parsed *metadata.Metadata) (*Resolver, error) {
+	var community metadata.PasskeyAuthenticator
+	if err := json.Unmarshal(communityAAGUIDs, &community); err != nil {
+		return nil, fmt.Errorf("invalid community AAGUID list: %w", err)
+	}
+
+	resolver := &Resolver{authenticators: make(map[uuid.UUID]dto.AuthenticatorInfo, len(community))}
+	if parsed != nil {
+		for _, entry := range parsed.Parsed.Entries {
+			if entry.AaGUID == uuid.Nil || entry.MetadataStatement.Description == "" {
+				continue
+			}
+			info := dto.AuthenticatorInfo{Name: entry.MetadataStatement.Description}
+			// Metadata statements have a single icon, it is used for both backgrounds
+			if icon := entry.MetadataStatement.Icon; icon != nil {
+				info.IconLight = icon.String()
+				info.IconDark = info.IconLight
+			}
+			resolver.authenticators[entry.AaGUID] = info
+		}
+	}
+	for value, authenticator := range community {
+		aaguid, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid community AAGUID %q: %w", value, err)
+		}
+		info := dto.AuthenticatorInfo{Name: authenticator.Name, IconLight: authenticator.IconLight, IconDark: authenticator.IconDark}
+		if info.IconDark == "" {
+			info.IconDark = info.IconLight
+		}
+		resolver.authenticators[aaguid] = info
+	}
+	return resolver, nil
+}
+
+// Resolve returns the authenticator model of the AAGUID, false when it is unknown or not a valid AAGUID
+func (r *Resolver) Resolve(aaguid []byte) (dto.AuthenticatorInfo, bool) {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil || id == uuid.Nil {
+		return dto.AuthenticatorInfo{}, false
+	}
+	info, ok := r.authenticators[id]
+	return info, ok
+}
+
+var defaultResolver atomic.Pointer[Resolver]
+
+var communityResolver = sync.OnceValue(func() *Resolver {
+	resolver, err := NewResolver(nil)
+	if err != nil {
+		// The list is embedded at build time and covered by the tests
+		panic(err)
+	}
+	return resolver
+})
+
+// DefaultResolver is the resolver used to name credentials, the community list unless SetDefaultResolver was called
+func DefaultResolver() *Resolver {
+	if resolver := defaultResolver.Load(); resolver != nil {
+		return resolver
+	}
+	return communityResolver()
+}
+
+// SetDefaultResolver replaces the default resolver, typically with one that also knows the FIDO metadata
+func SetDefaultResolver(resolver *Resolver) {
+	defaultResolver.Store(resolver)
+}
@@ -0,0 +1,70 @@
+package metadata_service
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const iCloudKeychainAAGUID = "fbfc3007-154e-4ecc-8c0b-6e020557d7bd"
+
+func aaguidBytes(value string) []byte {
+	id := uuid.MustParse(value)
+	return id[:]
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	// Given
+	root := newCertificate(t, "Metadata root", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.certificate)
+	parsed, err := ParseBlob(signBlob(t, newCertificate(t, "Metadata signer", root)), roots, testNow)
+	if err != nil {
+		t.Fatalf("ParseBlob() error = %v", err)
+	}
+	resolver, err := NewResolver(parsed)
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resolver *Resolver
+		aaguid   []byte
+		wantName string
+		wantOk   bool
+	}{
+		{name: "Community passkey provider", resolver: resolver, aaguid: aaguidBytes(iCloudKeychainAAGUID), wantName: "iCloud Keychain", wantOk: true},
+		{name: "Metadata statement", resolver: resolver, aaguid: aaguidBytes(certifiedAAGUID), wantName: "Test key FIDO_CERTIFIED", wantOk: true},
+		{name: "Metadata statement without metadata", resolver: DefaultResolver(), aaguid: aaguidBytes(certifiedAAGUID), wantOk: false},
+		{name: "Zero AAGUID", resolver: resolver, aaguid: make([]byte, 16), wantOk: false},
+		{name: "No AAGUID", resolver: resolver, aaguid: nil, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got, ok := tt.resolver.Resolve(tt.aaguid)
+
+			// Then
+			if ok != tt.wantOk || got.Name != tt.wantName {
+				t.Errorf("Resolve() = %q, %v, want %q, %v", got.Name, ok, tt.wantName, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestDefaultResolver(t *testing.T) {
+	t.Cleanup(func() { defaultResolver.Store(nil) })
+
+	// Given
+	resolver := &Resolver{}
+
+	// When
+	SetDefaultResolver(resolver)
+
+	// Then
+	if DefaultResolver() != resolver {
+		t.Errorf("DefaultResolver() did not return the resolver set with SetDefaultResolver()")
+	}
+}
@@ -0,0 +1,421 @@
+package policy_service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrStepUpRequired is returned when a session is too old, or did not verify the user, for a sensitive operation
+var ErrStepUpRequired = errors.New("step-up required")
+
+// ErrPolicyViolation is wrapped by every Violation so callers can tell a refused authenticator from other failures
+var ErrPolicyViolation = errors.New("policy violation")
+
+// Rules checked by the policy, reported in violations and audit events
+const (
+	RuleAAGUID           = "aaguid"
+	RuleAttestation      = "attestation"
+	RuleUserVerification = "userVerification"
+	RuleAttachment       = "attachment"
+	RuleSyncedPasskeys   = "syncedPasskeys"
+	RuleMaxCredentials   = "maxCredentials"
+	RuleTransports       = "transports"
+	RuleEnterprise       = "enterprise"
+)
+
+// Violation is the rule a credential broke, the message is written by us and safe to show to clients
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+func (v *Violation) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+func violation(rule string, format string, args ...any) error {
+	return &Violation{Rule: rule, Message: fmt.Sprintf(format, args...)}
+}
+
+// AttestationPolicy is the attestation conveyance requested at registration and whether it must be trusted,
+// i.e. verified against the FIDO metadata
+type AttestationPolicy struct {
+	Conveyance protocol.ConveyancePreference `yaml:"conveyance" json:"conveyance"`
+	Trusted    bool                          `yaml:"trusted" json:"trusted"`
+	Enterprise *EnterprisePolicy             `yaml:"enterprise" json:"enterprise,omitempty"`
+}
+
+// EnterprisePolicy requests enterprise attestation when the server runs for one of the RP IDs, browsers only grant it
+// to RP IDs their enterprise policy lists. Registrations must then identify the device by its serial number and, with
+// inventory, the serial must be in the tenant's uploaded device inventory.
+type EnterprisePolicy struct {
+	RPIDs     []string `yaml:"rpIds" json:"rpIds"`
+	Inventory bool     `yaml:"inventory" json:"inventory"`
+}
+
+// DefaultRecentAuthMaxAge is how old a login may be for a sensitive operation when the policy does not say, sensitive
+// operations always need a recent login
+const DefaultRecentAuthMaxAge = 600
+
+// FreshLoginMaxAge is how old a login may be when the request asks for a fresh one with max_age=0. Logins are
+// recorded to the second and the client retries after logging in again, so fresh allows the time that takes.
+const FreshLoginMaxAge = 30
+
+// RecentAuthPolicy requires sensitive operations, such as adding or removing passkeys, to come from a session whose
+// passkey login is at most MaxAge seconds old, like the OpenID Connect max_age parameter. With UserVerification the
+// login must also have verified the user.
+type RecentAuthPolicy struct {
+	MaxAge           int  `yaml:"maxAge" json:"maxAge"`
+	UserVerification bool `yaml:"userVerification" json:"userVerification"`
+}
+
+// Policy is what a tenant accepts from authenticators, the zero value accepts everything.
+type Policy struct {
+	AllowedAAGUIDs      []string                             `yaml:"allowedAaguids" json:"allowedAaguids,omitempty"`
+	DeniedAAGUIDs       []string                             `yaml:"deniedAaguids" json:"deniedAaguids,omitempty"`
+	Attestation         AttestationPolicy                    `yaml:"attestation" json:"attestation"`
+	UserVerification    protocol.UserVerificationRequirement `yaml:"userVerification" json:"userVerification,omitempty"`
+	Attachment          protocol.AuthenticatorAttachment     `yaml:"attachment" json:"attachment,omitempty"`
+	AllowSyncedPasskeys *bool                                `yaml:"allowSyncedPasskeys" json:"allowSyncedPasskeys,omitempty"`
+	MaxCredentials      int                                  `yaml:"maxCredentials" json:"maxCredentials,omitempty"`
+	AllowedTransports   []protocol.AuthenticatorTransport    `yaml:"allowedTransports" json:"allowedTransports,omitempty"`
+	RecentAuth          *RecentAuthPolicy                    `yaml:"recentAuth" json:"recentAuth,omitempty"`
+	// Origins are where the tenant's pages are served from, requests are bound to the tenant by their Origin header
+	Origins []string `yaml:"origins" json:"origins,omitempty"`
+
+	allowed map[uuid.UUID]bool
+	denied  map[uuid.UUID]bool
+}
+
+// Policies is the declarative policy file, tenants without their own policy get the default one.
+// Requests from origins no tenant lists belong to the default tenant.
+//
+//	default:
+//	  userVerification: preferred
+//	tenants:
+//	  bank:
+//	    origins: [https://bank.example.com]
+//	    attestation: {conveyance: direct, trusted: true}
+//	    userVerification: required
+//	    allowSyncedPasskeys: false
+//	    maxCredentials: 5
+//	    recentAuth: {maxAge: 300, userVerification: true}
+type Policies struct {
+	Default Policy            `yaml:"default"`
+	Tenants map[string]Policy `yaml:"tenants"`
+}
+
+func parseAAGUIDs(values []string) (map[uuid.UUID]bool, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	aaguids := make(map[uuid.UUID]bool, len(values))
+	for _, value := range values {
+		aaguid, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AAGUID %q", value)
+		}
+		aaguids[aaguid] = true
+	}
+	return aaguids, nil
+}
+
+// compile validates the policy and parses the AAGUID lists
+func (p *Policy) compile() error {
+	var err error
+	if p.allowed, err = parseAAGUIDs(p.AllowedAAGUIDs); err != nil {
+		return fmt.Errorf("allowedAaguids: %w", err)
+	}
+	if p.denied, err = parseAAGUIDs(p.DeniedAAGUIDs); err != nil {
+		return fmt.Errorf("deniedAaguids: %w", err)
+	}
+
+	switch p.Attestation.Conveyance {
+	case "", protocol.PreferNoAttestation, protocol.PreferIndirectAttestation, protocol.PreferDirectAttestation, protocol.PreferEnterpriseAttestation:
+	default:
+		return fmt.Errorf("attestation.conveyance must be none, indirect, direct or enterprise")
+	}
+	if p.Attestation.Trusted && !p.RequiresAttestation() {
+		return fmt.Errorf("attestation.trusted needs a direct or enterprise conveyance")
+	}
+	if enterprise := p.Attestation.Enterprise; enterprise != nil {
+		// A serial number is only worth checking when the certificate carrying it chains to the vendor
+		if !p.Attestation.Trusted {
+			return fmt.Errorf("attestation.enterprise needs attestation.trusted")
+		}
+		if len(enterprise.RPIDs) == 0 {
+			return fmt.Errorf("attestation.enterprise.rpIds is required")
+		}
+	}
+	switch p.UserVerification {
+	case "", protocol.VerificationRequired, protocol.VerificationPreferred, protocol.VerificationDiscouraged:
+	default:
+		return fmt.Errorf("userVerification must be required, preferred or discouraged")
+	}
+	switch p.Attachment {
+	case "", protocol.Platform, protocol.CrossPlatform:
+	default:
+		return fmt.Errorf("attachment must be platform or cross-platform")
+	}
+	if p.MaxCredentials < 0 {
+		return fmt.Errorf("maxCredentials must not be negative")
+	}
+	if p.RecentAuth != nil && p.RecentAuth.MaxAge < 0 {
+		return fmt.Errorf("recentAuth.maxAge must not be negative")
+	}
+	return nil
+}
+
+// ParsePolicies reads the policy YAML, unknown keys are rejected so typos do not silently loosen a policy
+func ParsePolicies(data []byte) (*Policies, error) {
+	var policies Policies
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policies); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	if err := policies.Default.compile(); err != nil {
+		return nil, fmt.Errorf("invalid default policy: %w", err)
+	}
+	if len(policies.Default.Origins) > 0 {
+		return nil, fmt.Errorf("invalid default policy: origins are only set for tenants")
+	}
+	origins := map[string]string{}
+	for tenant, policy := range policies.Tenants {
+		if err := policy.compile(); err != nil {
+			return nil, fmt.Errorf("invalid policy for tenant %q: %w", tenant, err)
+		}
+		for _, origin := range policy.Origins {
+			if other, ok := origins[origin]; ok {
+				return nil, fmt.Errorf("origin %v is listed by tenants %q and %q", origin, other, tenant)
+			}
+			origins[origin] = tenant
+		}
+		policies.Tenants[tenant] = policy
+	}
+	return &policies, nil
+}
+
+// LoadPolicies reads the policy file, typically mounted from a ConfigMap. Without a path every tenant accepts everything.
+func LoadPolicies(path string) (*Policies, error) {
+	if path == "" {
+		return &Policies{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return ParsePolicies(data)
+}
+
+// ForTenant returns the tenant's policy, the default one when the tenant has none
+func (p *Policies) ForTenant(tenant string) *Policy {
+	if policy, ok := p.Tenants[tenant]; ok {
+		return &policy
+	}
+	policy := p.Default
+	return &policy
+}
+
+// TenantForOrigin returns the tenant whose pages are served from the origin, false when no tenant lists it
+func (p *Policies) TenantForOrigin(origin string) (string, bool) {
+	for tenant, policy := range p.Tenants {
+		if slices.Contains(policy.Origins, origin) {
+			return tenant, true
+		}
+	}
+	return "", false
+}
+
+// RequiresMetadata is true when any tenant needs attestation verified against the FIDO metadata
+func (p *Policies) RequiresMetadata() bool {
+	if p.Default.Attestation.Trusted {
+		return true
+	}
+	for _, policy := range p.Tenants {
+		if policy.Attestation.Trusted {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresAttestation is true when registrations must carry an attestation statement
+func (p *Policy) RequiresAttestation() bool {
+	return p.Attestation.Conveyance == protocol.PreferDirectAttestation || p.Attestation.Conveyance == protocol.PreferEnterpriseAttestation
+}
+
+// EnterpriseAttestation is true when registrations for the RP ID request enterprise attestation and must carry a serial number
+func (p *Policy) EnterpriseAttestation(rpID string) bool {
+	return p.Attestation.Enterprise != nil && slices.Contains(p.Attestation.Enterprise.RPIDs, rpID)
+}
+
+// RegistrationOptions asks the browser for the authenticators the policy accepts, starting from the default selection
+func (p *Policy) RegistrationOptions(rpID string, selection protocol.AuthenticatorSelection) []webauthn.RegistrationOption {
+	var options []webauthn.RegistrationOption
+	if p.EnterpriseAttestation(rpID) {
+		options = append(options, webauthn.WithConveyancePreference(protocol.PreferEnterpriseAttestation))
+	} else if p.Attestation.Conveyance != "" {
+		options = append(options, webauthn.WithConveyancePreference(p.Attestation.Conveyance))
+	}
+	if p.UserVerification != "" || p.Attachment != "" {
+		if p.UserVerification != "" {
+			selection.UserVerification = p.UserVerification
+		}
+		if p.Attachment != "" {
+			selection.AuthenticatorAttachment = p.Attachment
+		}
+		options = append(options, webauthn.WithAuthenticatorSelection(selection))
+	}
+	return options
+}
+
+// LoginOptions asks the browser for user verification when the policy sets it
+func (p *Policy) LoginOptions() []webauthn.LoginOption {
+	if p.UserVerification == "" {
+		return nil
+	}
+	return []webauthn.LoginOption{webauthn.WithUserVerification(p.UserVerification)}
+}
+
+// MaxAge is the oldest a login may be for a sensitive operation, in seconds, DefaultRecentAuthMaxAge unless the policy
+// sets it. A max_age asked for by the request can only tighten it, and like in OpenID Connect a max_age of 0 asks for
+// a fresh login. Nil when the request does not ask.
+func (p *Policy) MaxAge(requested *int) int {
+	maxAge := DefaultRecentAuthMaxAge
+	if p.RecentAuth != nil && p.RecentAuth.MaxAge > 0 {
+		maxAge = p.RecentAuth.MaxAge
+	}
+	if requested != nil {
+		tightened := *requested
+		if tightened == 0 {
+			tightened = FreshLoginMaxAge
+		}
+		maxAge = min(maxAge, tightened)
+	}
+	return maxAge
+}
+
+// CheckRecentAuth refuses a sensitive operation from a session whose login is older than maxAge seconds or, when
+// the policy requires it, did not verify the user
+func (p *Policy) CheckRecentAuth(authTime time.Time, userVerified bool, maxAge int, now time.Time) error {
+	if maxAge > 0 && now.Sub(authTime) > time.Duration(maxAge)*time.Second {
+		return fmt.Errorf("%w: login is older than %v seconds", ErrStepUpRequired, maxAge)
+	}
+	if p.RecentAuth != nil && p.RecentAuth.UserVerification && !userVerified {
+		return fmt.Errorf("%w: login did not verify the user", ErrStepUpRequired)
+	}
+	return nil
+}
+
+// CheckCredentialCount refuses a new registration when the user already has the maximum number of active credentials
+func (p *Policy) CheckCredentialCount(active int) error {
+	if p.MaxCredentials > 0 && active >= p.MaxCredentials {
+		return violation(RuleMaxCredentials, "Users may have at most %v credentials", p.MaxCredentials)
+	}
+	return nil
+}
+
+// checkAuthenticator holds the rules that apply to both registration and authentication
+func (p *Policy) checkAuthenticator(credential *webauthn.Credential) error {
+	if p.UserVerification == protocol.VerificationRequired && !credential.Flags.UserVerified {
+		return violation(RuleUserVerification, "User verification is required")
+	}
+	return p.checkModel(credential)
+}
+
+// checkModel holds the rules about the authenticator model, which do not change after registration
+func (p *Policy) checkModel(credential *webauthn.Credential) error {
+	if len(p.allowed) > 0 || len(p.denied) > 0 {
+		aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+		if err != nil {
+			aaguid = uuid.Nil
+		}
+		if p.denied[aaguid] {
+			return violation(RuleAAGUID, "Authenticator model %v is not allowed", aaguid)
+		}
+		if len(p.allowed) > 0 && !p.allowed[aaguid] {
+			return violation(RuleAAGUID, "Authenticator model %v is not in the allowed list", aaguid)
+		}
+	}
+	// Clients do not always report the attachment, only a reported one can be refused
+	if p.Attachment != "" && credential.Authenticator.Attachment != "" && credential.Authenticator.Attachment != p.Attachment {
+		return violation(RuleAttachment, "Only %v authenticators are allowed", p.Attachment)
+	}
+	if p.AllowSyncedPasskeys != nil && !*p.AllowSyncedPasskeys && credential.Flags.BackupEligible {
+		return violation(RuleSyncedPasskeys, "Synced passkeys are not allowed")
+	}
+	if len(p.AllowedTransports) > 0 && !slices.ContainsFunc(credential.Transport, func(transport protocol.AuthenticatorTransport) bool {
+		return slices.Contains(p.AllowedTransports, transport)
+	}) {
+		return violation(RuleTransports, "Authenticators must support one of the transports %v", joinTransports(p.AllowedTransports))
+	}
+	return nil
+}
+
+// CheckRegistration evaluates a newly created credential. Trust in the attestation is verified by go-webauthn
+// against the metadata, this only checks an attestation statement was sent when the policy asks for one.
+func (p *Policy) CheckRegistration(credential *webauthn.Credential) error {
+	if p.RequiresAttestation() && credential.AttestationType == string(protocol.AttestationFormatNone) {
+		return violation(RuleAttestation, "Authenticators must provide an attestation")
+	}
+	return p.checkAuthenticator(credential)
+}
+
+// CheckSerial evaluates the device serial number of an enterprise registration, empty when the attestation carried none.
+// inInventory looks the serial up in the tenant's device inventory, it is only called when the policy has one.
+func (p *Policy) CheckSerial(serial string, inInventory func(serial string) (bool, error)) error {
+	if serial == "" {
+		return violation(RuleEnterprise, "Authenticators must provide an enterprise attestation with a serial number")
+	}
+	if p.Attestation.Enterprise == nil || !p.Attestation.Enterprise.Inventory {
+		return nil
+	}
+	found, err := inInventory(serial)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return violation(RuleEnterprise, "Authenticator %v is not a company-issued device", serial)
+	}
+	return nil
+}
+
+// CheckAuthentication evaluates the credential after a successful assertion, so policies tightened after
+// registration, such as a newly denied AAGUID, also apply to existing credentials
+func (p *Policy) CheckAuthentication(credential *webauthn.Credential) error {
+	return p.checkAuthenticator(credential)
+}
+
+// CheckStored re-evaluates a stored credential against a policy that may have been tightened since it was registered.
+// User verification is left out, it is asserted again at every login.
+func (p *Policy) CheckStored(credential *webauthn.Credential) error {
+	if p.RequiresAttestation() && credential.AttestationType == string(protocol.AttestationFormatNone) {
+		return violation(RuleAttestation, "Authenticators must provide an attestation")
+	}
+	return p.checkModel(credential)
+}
+
+func joinTransports(transports []protocol.AuthenticatorTransport) string {
+	values := make([]string, len(transports))
+	for i, transport := range transports {
+		values[i] = string(transport)
+	}
+	return strings.Join(values, ", ")
+}
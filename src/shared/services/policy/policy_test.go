@@ -0,0 +1,473 @@
+package policy_service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+const (
+	yubiKeyAAGUID = "ee882879-721c-4913-9775-3dfcce97072a"
+	deniedAAGUID  = "2fc0579f-8113-47ea-b116-bb5a8db9202a"
+)
+
+const testPolicies = `
+default:
+  deniedAaguids: [` + deniedAAGUID + `]
+tenants:
+  bank:
+    origins: [https://bank.example.com]
+    allowedAaguids: [` + yubiKeyAAGUID + `]
+    attestation:
+      conveyance: direct
+      trusted: true
+    userVerification: required
+    attachment: cross-platform
+    allowSyncedPasskeys: false
+    maxCredentials: 2
+    allowedTransports: [usb, nfc]
+`
+
+func testCredential(aaguid string) *webauthn.Credential {
+	id := uuid.MustParse(aaguid)
+	return &webauthn.Credential{
+		AttestationType: "packed",
+		Transport:       []protocol.AuthenticatorTransport{protocol.USB},
+		Flags:           webauthn.CredentialFlags{UserPresent: true, UserVerified: true},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:     id[:],
+			Attachment: protocol.CrossPlatform,
+		},
+	}
+}
+
+func withAAGUID(credential *webauthn.Credential, aaguid string) *webauthn.Credential {
+	id := uuid.MustParse(aaguid)
+	credential.Authenticator.AAGUID = id[:]
+	return credential
+}
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "Valid", input: testPolicies, wantErr: false},
+		{name: "Empty", input: "", wantErr: false},
+		{name: "Unknown key", input: "default:\n  userVerfication: required\n", wantErr: true},
+		{name: "Invalid AAGUID", input: "default:\n  allowedAaguids: [yubikey]\n", wantErr: true},
+		{name: "Invalid conveyance", input: "default:\n  attestation: {conveyance: always}\n", wantErr: true},
+		{name: "Trust without attestation", input: "default:\n  attestation: {trusted: true}\n", wantErr: true},
+		{name: "Invalid attachment", input: "tenants:\n  bank:\n    attachment: usb\n", wantErr: true},
+		{name: "Negative maximum", input: "default:\n  maxCredentials: -1\n", wantErr: true},
+		{name: "Enterprise without trust", input: "default:\n  attestation: {conveyance: enterprise, enterprise: {rpIds: [corp.example.com]}}\n", wantErr: true},
+		{name: "Enterprise without RP IDs", input: "default:\n  attestation: {conveyance: enterprise, trusted: true, enterprise: {}}\n", wantErr: true},
+		{name: "Recent auth", input: "default:\n  recentAuth: {maxAge: 300, userVerification: true}\n", wantErr: false},
+		{name: "Negative max age", input: "default:\n  recentAuth: {maxAge: -1}\n", wantErr: true},
+		{name: "Default origins", input: "default:\n  origins: [https://example.com]\n", wantErr: true},
+		{name: "Origin of two tenants", input: "tenants:\n  bank: {origins: [https://example.com]}\n  shop: {origins: [https://example.com]}\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := ParsePolicies([]byte(tt.input))
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicies_TenantForOrigin(t *testing.T) {
+	policies, err := ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   string
+		listed bool
+	}{
+		{name: "Tenant origin", origin: "https://bank.example.com", want: "bank", listed: true},
+		{name: "Unlisted origin", origin: "https://shop.example.com", want: ""},
+		{name: "No origin", origin: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got, listed := policies.TenantForOrigin(tt.origin)
+
+			// Then
+			if got != tt.want || listed != tt.listed {
+				t.Errorf("TenantForOrigin() = %q, %v, want %q, %v", got, listed, tt.want, tt.listed)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckRegistration(t *testing.T) {
+	policies, err := ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		modify   func(credential *webauthn.Credential)
+		wantRule string
+	}{
+		{name: "Allowed authenticator", tenant: "bank", modify: func(credential *webauthn.Credential) {}},
+		{
+			name:     "AAGUID not allowed",
+			tenant:   "bank",
+			modify:   func(credential *webauthn.Credential) { withAAGUID(credential, deniedAAGUID) },
+			wantRule: RuleAAGUID,
+		},
+		{
+			name:     "No attestation",
+			tenant:   "bank",
+			modify:   func(credential *webauthn.Credential) { credential.AttestationType = "none" },
+			wantRule: RuleAttestation,
+		},
+		{
+			name:     "Not user verified",
+			tenant:   "bank",
+			modify:   func(credential *webauthn.Credential) { credential.Flags.UserVerified = false },
+			wantRule: RuleUserVerification,
+		},
+		{
+			name:     "Platform authenticator",
+			tenant:   "bank",
+			modify:   func(credential *webauthn.Credential) { credential.Authenticator.Attachment = protocol.Platform },
+			wantRule: RuleAttachment,
+		},
+		{
+			name:   "Unreported attachment",
+			tenant: "bank",
+			modify: func(credential *webauthn.Credential) { credential.Authenticator.Attachment = "" },
+		},
+		{
+			name:     "Synced passkey",
+			tenant:   "bank",
+			modify:   func(credential *webauthn.Credential) { credential.Flags.BackupEligible = true },
+			wantRule: RuleSyncedPasskeys,
+		},
+		{
+			name:   "Transport not allowed",
+			tenant: "bank",
+			modify: func(credential *webauthn.Credential) {
+				credential.Transport = []protocol.AuthenticatorTransport{protocol.Internal, protocol.Hybrid}
+			},
+			wantRule: RuleTransports,
+		},
+		{
+			name:     "Denied by the default policy",
+			tenant:   "other",
+			modify:   func(credential *webauthn.Credential) { withAAGUID(credential, deniedAAGUID) },
+			wantRule: RuleAAGUID,
+		},
+		{
+			name:   "Default policy accepts synced passkeys without attestation",
+			tenant: "other",
+			modify: func(credential *webauthn.Credential) {
+				credential.AttestationType = "none"
+				credential.Flags.BackupEligible = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			credential := testCredential(yubiKeyAAGUID)
+			tt.modify(credential)
+
+			// When
+			err := policies.ForTenant(tt.tenant).CheckRegistration(credential)
+
+			// Then
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Errorf("CheckRegistration() error = %v, want nil", err)
+				}
+				return
+			}
+			var violation *Violation
+			if !errors.As(err, &violation) || violation.Rule != tt.wantRule {
+				t.Errorf("CheckRegistration() error = %v, want a %v violation", err, tt.wantRule)
+			}
+			if !errors.Is(err, ErrPolicyViolation) {
+				t.Errorf("CheckRegistration() error = %v, want it to wrap ErrPolicyViolation", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckAuthentication(t *testing.T) {
+	// Given
+	policies, err := ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+	credential := testCredential(yubiKeyAAGUID)
+	credential.AttestationType = "none"
+	credential.Flags.UserVerified = false
+
+	// When
+	err = policies.ForTenant("bank").CheckAuthentication(credential)
+
+	// Then
+	var violation *Violation
+	if !errors.As(err, &violation) || violation.Rule != RuleUserVerification {
+		t.Errorf("CheckAuthentication() error = %v, want a %v violation", err, RuleUserVerification)
+	}
+}
+
+func TestPolicy_CheckCredentialCount(t *testing.T) {
+	policy := &Policy{MaxCredentials: 2}
+	tests := []struct {
+		name    string
+		policy  *Policy
+		active  int
+		wantErr bool
+	}{
+		{name: "Below the maximum", policy: policy, active: 1, wantErr: false},
+		{name: "At the maximum", policy: policy, active: 2, wantErr: true},
+		{name: "No maximum", policy: &Policy{}, active: 100, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := tt.policy.CheckCredentialCount(tt.active)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCredentialCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_MaxAge(t *testing.T) {
+	seconds := func(value int) *int { return &value }
+	tests := []struct {
+		name      string
+		policy    *Policy
+		requested *int
+		want      int
+	}{
+		{name: "Neither", policy: &Policy{}, want: DefaultRecentAuthMaxAge},
+		{name: "Policy without max age", policy: &Policy{RecentAuth: &RecentAuthPolicy{UserVerification: true}}, want: DefaultRecentAuthMaxAge},
+		{name: "Policy only", policy: &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 300}}, want: 300},
+		{name: "Request only", policy: &Policy{}, requested: seconds(60), want: 60},
+		{name: "Request cannot loosen the default", policy: &Policy{}, requested: seconds(3600), want: DefaultRecentAuthMaxAge},
+		{name: "Request tightens", policy: &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 300}}, requested: seconds(60), want: 60},
+		{name: "Request cannot loosen", policy: &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 300}}, requested: seconds(600), want: 300},
+		{name: "Request for a fresh login", policy: &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 300}}, requested: seconds(0), want: FreshLoginMaxAge},
+		{name: "Policy fresher than a fresh login", policy: &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 10}}, requested: seconds(0), want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxAge(tt.requested); got != tt.want {
+				t.Errorf("MaxAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckRecentAuth(t *testing.T) {
+	now := time.Now()
+	verified := &Policy{RecentAuth: &RecentAuthPolicy{MaxAge: 300, UserVerification: true}}
+	tests := []struct {
+		name         string
+		policy       *Policy
+		authTime     time.Time
+		userVerified bool
+		maxAge       int
+		wantErr      bool
+	}{
+		{name: "Recent", policy: verified, authTime: now.Add(-time.Minute), userVerified: true, maxAge: 300, wantErr: false},
+		{name: "Too old", policy: verified, authTime: now.Add(-10 * time.Minute), userVerified: true, maxAge: 300, wantErr: true},
+		{name: "Not verified", policy: verified, authTime: now.Add(-time.Minute), userVerified: false, maxAge: 300, wantErr: true},
+		{name: "No max age", policy: &Policy{}, authTime: now.Add(-24 * time.Hour), userVerified: false, maxAge: 0, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := tt.policy.CheckRecentAuth(tt.authTime, tt.userVerified, tt.maxAge, now)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRecentAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrStepUpRequired) {
+				t.Errorf("CheckRecentAuth() error = %v, want ErrStepUpRequired", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_RegistrationOptions(t *testing.T) {
+	// Given
+	policies, err := ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+	creation := &protocol.PublicKeyCredentialCreationOptions{
+		AuthenticatorSelection: protocol.AuthenticatorSelection{ResidentKey: protocol.ResidentKeyRequirementPreferred},
+	}
+
+	// When
+	for _, option := range policies.ForTenant("bank").RegistrationOptions("example.com", creation.AuthenticatorSelection) {
+		option(creation)
+	}
+
+	// Then
+	if creation.Attestation != protocol.PreferDirectAttestation {
+		t.Errorf("Attestation = %v, want %v", creation.Attestation, protocol.PreferDirectAttestation)
+	}
+	want := protocol.AuthenticatorSelection{
+		ResidentKey:             protocol.ResidentKeyRequirementPreferred,
+		UserVerification:        protocol.VerificationRequired,
+		AuthenticatorAttachment: protocol.CrossPlatform,
+	}
+	if creation.AuthenticatorSelection != want {
+		t.Errorf("AuthenticatorSelection = %+v, want %+v", creation.AuthenticatorSelection, want)
+	}
+	if options := policies.ForTenant("other").RegistrationOptions("example.com", creation.AuthenticatorSelection); len(options) != 0 {
+		t.Errorf("RegistrationOptions() = %v options for the default policy, want none", len(options))
+	}
+}
+
+func TestPolicy_CheckStored(t *testing.T) {
+	policies, err := ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(credential *webauthn.Credential)
+		wantRule string
+	}{
+		{name: "Still allowed", modify: func(credential *webauthn.Credential) {}},
+		{
+			name:   "User verification is asserted at login",
+			modify: func(credential *webauthn.Credential) { credential.Flags.UserVerified = false },
+		},
+		{
+			name:     "No attestation",
+			modify:   func(credential *webauthn.Credential) { credential.AttestationType = "none" },
+			wantRule: RuleAttestation,
+		},
+		{
+			name:     "AAGUID no longer allowed",
+			modify:   func(credential *webauthn.Credential) { withAAGUID(credential, deniedAAGUID) },
+			wantRule: RuleAAGUID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			credential := testCredential(yubiKeyAAGUID)
+			tt.modify(credential)
+
+			// When
+			err := policies.ForTenant("bank").CheckStored(credential)
+
+			// Then
+			var violation *Violation
+			if tt.wantRule == "" && err != nil {
+				t.Errorf("CheckStored() error = %v, want nil", err)
+			} else if tt.wantRule != "" && (!errors.As(err, &violation) || violation.Rule != tt.wantRule) {
+				t.Errorf("CheckStored() error = %v, want a %v violation", err, tt.wantRule)
+			}
+		})
+	}
+}
+
+const enterprisePolicies = `
+tenants:
+  corp:
+    attestation:
+      conveyance: direct
+      trusted: true
+      enterprise:
+        rpIds: [corp.example.com]
+        inventory: true
+`
+
+func TestPolicy_EnterpriseAttestation(t *testing.T) {
+	// Given
+	policies, err := ParsePolicies([]byte(enterprisePolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		rpID           string
+		wantConveyance protocol.ConveyancePreference
+	}{
+		{name: "Listed RP ID", rpID: "corp.example.com", wantConveyance: protocol.PreferEnterpriseAttestation},
+		{name: "Other RP ID", rpID: "example.com", wantConveyance: protocol.PreferDirectAttestation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creation := &protocol.PublicKeyCredentialCreationOptions{}
+
+			// When
+			for _, option := range policies.ForTenant("corp").RegistrationOptions(tt.rpID, creation.AuthenticatorSelection) {
+				option(creation)
+			}
+
+			// Then
+			if creation.Attestation != tt.wantConveyance {
+				t.Errorf("Attestation = %v, want %v", creation.Attestation, tt.wantConveyance)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckSerial(t *testing.T) {
+	policies, err := ParsePolicies([]byte(enterprisePolicies))
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+	inventory := func(serial string) (bool, error) {
+		return serial == "28471123", nil
+	}
+
+	tests := []struct {
+		name     string
+		serial   string
+		wantRule string
+	}{
+		{name: "Company-issued device", serial: "28471123"},
+		{name: "Device not in the inventory", serial: "11111111", wantRule: RuleEnterprise},
+		{name: "No serial number", serial: "", wantRule: RuleEnterprise},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := policies.ForTenant("corp").CheckSerial(tt.serial, inventory)
+
+			// Then
+			var violation *Violation
+			if tt.wantRule == "" && err != nil {
+				t.Errorf("CheckSerial() error = %v, want nil", err)
+			} else if tt.wantRule != "" && (!errors.As(err, &violation) || violation.Rule != tt.wantRule) {
+				t.Errorf("CheckSerial() error = %v, want a %v violation", err, tt.wantRule)
+			}
+		})
+	}
+}
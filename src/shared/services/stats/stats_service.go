@@ -0,0 +1,292 @@
+package stats_service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	audit_service "blacksmithlabs.dev/webauthn-k8s/shared/services/audit"
+)
+
+const day = 24 * time.Hour
+const dayFormat = "2006-01-02"
+
+// Only the most common values are reported, the long tail is not useful for tracking adoption
+const failureReasonLimit = 20
+const aaguidLimit = 50
+
+const (
+	BackupNotEligible = "not-eligible"
+	BackupEligible    = "eligible"
+	BackupBackedUp    = "backed-up"
+)
+
+// StatsService computes the passkey adoption statistics from the credentials and the audit log
+type StatsService struct {
+	ctx     context.Context
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new StatsService instance
+func New(ctx context.Context) (*StatsService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsService{
+		ctx:     ctx,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+func timestamp(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
+
+func rate(failures int64, successes int64) float64 {
+	if failures+successes == 0 {
+		return 0
+	}
+	return float64(failures) / float64(failures+successes)
+}
+
+func aaguidKey(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil {
+		return "unknown"
+	}
+	return id.String()
+}
+
+func backupKey(eligible bool, backedUp bool) string {
+	if backedUp {
+		return BackupBackedUp
+	} else if eligible {
+		return BackupEligible
+	}
+	return BackupNotEligible
+}
+
+// Report computes the statistics for the days from up to but not including to, both are truncated to whole UTC days
+func (s *StatsService) Report(from time.Time, to time.Time) (*dto.StatsReport, error) {
+	from = from.UTC().Truncate(day)
+	to = to.UTC().Truncate(day)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from(%v) must be before to(%v)", from, to)
+	}
+
+	report := &dto.StatsReport{
+		From:        from,
+		To:          to,
+		GeneratedAt: time.Now().UTC(),
+	}
+	if err := s.addDaily(report); err != nil {
+		return nil, err
+	}
+	if err := s.addFailures(report); err != nil {
+		return nil, err
+	}
+	if err := s.addCredentialBreakdowns(report); err != nil {
+		return nil, err
+	}
+
+	activeUsers, err := s.queries.CountActiveUsers(s.ctx, credentials.CountActiveUsersParams{
+		FromTime: timestamp(from),
+		ToTime:   timestamp(to),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+	report.ActiveUsers = activeUsers
+
+	return report, nil
+}
+
+func (s *StatsService) addDaily(report *dto.StatsReport) error {
+	rows, err := s.queries.CountAuditEventsPerDay(s.ctx, credentials.CountAuditEventsPerDayParams{
+		EventTypes: []string{
+			string(audit_service.EventCredentialRegistered),
+			string(audit_service.EventAuthenticationSucceeded),
+			string(audit_service.EventRegistrationFailed),
+			string(audit_service.EventAuthenticationFailed),
+		},
+		FromTime: timestamp(report.From),
+		ToTime:   timestamp(report.To),
+	})
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	// Every day of the window is reported, quiet days included, so the series can be charted as is
+	days := map[string]*dto.DailyStats{}
+	for d := report.From; d.Before(report.To); d = d.Add(day) {
+		report.Daily = append(report.Daily, dto.DailyStats{Day: d.Format(dayFormat)})
+	}
+	for i := range report.Daily {
+		days[report.Daily[i].Day] = &report.Daily[i]
+	}
+
+	var registrations, logins, registrationFailures, loginFailures int64
+	for _, row := range rows {
+		daily, ok := days[row.Day.Time.Format(dayFormat)]
+		if !ok {
+			continue
+		}
+		switch audit_service.EventType(row.EventType) {
+		case audit_service.EventCredentialRegistered:
+			daily.Registrations += row.Total
+			registrations += row.Total
+		case audit_service.EventAuthenticationSucceeded:
+			daily.Logins += row.Total
+			logins += row.Total
+		case audit_service.EventRegistrationFailed:
+			daily.RegistrationFailures += row.Total
+			registrationFailures += row.Total
+		case audit_service.EventAuthenticationFailed:
+			daily.LoginFailures += row.Total
+			loginFailures += row.Total
+		}
+	}
+
+	report.FailureRates = dto.FailureRates{
+		Registration: rate(registrationFailures, registrations),
+		Login:        rate(loginFailures, logins),
+	}
+	return nil
+}
+
+// addFailures counts the failures by error code, the message after the code in the reason is too specific to group by
+func (s *StatsService) addFailures(report *dto.StatsReport) error {
+	rows, err := s.queries.CountFailuresByReason(s.ctx, credentials.CountFailuresByReasonParams{
+		FromTime: timestamp(report.From),
+		ToTime:   timestamp(report.To),
+		RowLimit: failureReasonLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+
+	report.Failures = make([]dto.FailureStat, 0, len(rows))
+	for _, row := range rows {
+		flow := "login"
+		if audit_service.EventType(row.EventType) == audit_service.EventRegistrationFailed {
+			flow = "registration"
+		}
+		report.Failures = append(report.Failures, dto.FailureStat{Flow: flow, Reason: row.Reason, Count: row.Total})
+	}
+	return nil
+}
+
+func (s *StatsService) addCredentialBreakdowns(report *dto.StatsReport) error {
+	perUser, err := s.queries.CountUsersByCredentialCount(s.ctx)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	report.CredentialsPerUser = make([]dto.CountStat, 0, len(perUser))
+	for _, row := range perUser {
+		report.CredentialsPerUser = append(report.CredentialsPerUser, dto.CountStat{Key: strconv.FormatInt(row.CredentialCount, 10), Count: row.Total})
+	}
+
+	attestationTypes, err := s.queries.CountCredentialsByAttestationType(s.ctx)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	report.AttestationTypes = make([]dto.CountStat, 0, len(attestationTypes))
+	for _, row := range attestationTypes {
+		report.AttestationTypes = append(report.AttestationTypes, dto.CountStat{Key: row.AttestationType, Count: row.Total})
+	}
+
+	transports, err := s.queries.CountCredentialsByTransport(s.ctx)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	report.Transports = make([]dto.CountStat, 0, len(transports))
+	for _, row := range transports {
+		report.Transports = append(report.Transports, dto.CountStat{Key: row.Transport, Count: row.Total})
+	}
+
+	aaguids, err := s.queries.CountCredentialsByAaguid(s.ctx, aaguidLimit)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	report.AAGUIDs = make([]dto.CountStat, 0, len(aaguids))
+	for _, row := range aaguids {
+		report.AAGUIDs = append(report.AAGUIDs, dto.CountStat{Key: aaguidKey(row.Aaguid), Count: row.Total})
+	}
+
+	backupStates, err := s.queries.CountCredentialsByBackupState(s.ctx)
+	if err != nil {
+		return fmt.Errorf("data access error: %w", err)
+	}
+	counts := map[string]int64{}
+	for _, row := range backupStates {
+		counts[backupKey(row.BackupEligible, row.BackupState)] += row.Total
+	}
+	report.BackupStates = []dto.CountStat{
+		{Key: BackupNotEligible, Count: counts[BackupNotEligible]},
+		{Key: BackupEligible, Count: counts[BackupEligible]},
+		{Key: BackupBackedUp, Count: counts[BackupBackedUp]},
+	}
+
+	return nil
+}
+
+// WriteCSV writes the report as metric,key,value rows so it can be loaded straight into a spreadsheet
+func WriteCSV(w io.Writer, report *dto.StatsReport) error {
+	writer := csv.NewWriter(w)
+	count := func(value int64) string {
+		return strconv.FormatInt(value, 10)
+	}
+
+	records := [][]string{
+		{"metric", "key", "value"},
+		{"active_users", "", count(report.ActiveUsers)},
+		{"failure_rate", "registration", strconv.FormatFloat(report.FailureRates.Registration, 'f', 4, 64)},
+		{"failure_rate", "login", strconv.FormatFloat(report.FailureRates.Login, 'f', 4, 64)},
+	}
+	for _, daily := range report.Daily {
+		records = append(records,
+			[]string{"registrations", daily.Day, count(daily.Registrations)},
+			[]string{"logins", daily.Day, count(daily.Logins)},
+			[]string{"registration_failures", daily.Day, count(daily.RegistrationFailures)},
+			[]string{"login_failures", daily.Day, count(daily.LoginFailures)},
+		)
+	}
+	breakdowns := []struct {
+		metric string
+		stats  []dto.CountStat
+	}{
+		{"credentials_per_user", report.CredentialsPerUser},
+		{"attestation_type", report.AttestationTypes},
+		{"transport", report.Transports},
+		{"aaguid", report.AAGUIDs},
+		{"backup_state", report.BackupStates},
+	}
+	for _, breakdown := range breakdowns {
+		for _, stat := range breakdown.stats {
+			records = append(records, []string{breakdown.metric, stat.Key, count(stat.Count)})
+		}
+	}
+	for _, failure := range report.Failures {
+		records = append(records, []string{failure.Flow + "_failure_reason", failure.Reason, count(failure.Count)})
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
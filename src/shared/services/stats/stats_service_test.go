@@ -0,0 +1,193 @@
+package stats_service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+var from = time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+var to = time.Date(2024, 11, 4, 0, 0, 0, 0, time.UTC)
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func date(t time.Time) pgtype.Date {
+	return pgtype.Date{Time: t, Valid: true}
+}
+
+func TestStatsService_Report(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	aaguid := uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a")
+	window := []any{pgtype.Timestamptz{Time: from, Valid: true}, pgtype.Timestamptz{Time: to, Valid: true}}
+
+	mocker := mockPool.EXPECT()
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:date_trunc.*FROM audit_events)"), gomock.Any(), window[0], window[1]).Return(
+		pgxpoolmock.NewRows([]string{"day", "event_type", "total"}).
+			AddRow(date(from), "authentication.failed", int64(1)).
+			AddRow(date(from), "authentication.succeeded", int64(3)).
+			AddRow(date(from), "credential.registered", int64(2)).
+			AddRow(date(from.Add(2*day)), "registration.failed", int64(2)).
+			AddRow(date(from.Add(2*day)), "credential.registered", int64(2)).
+			ToPgxRows(),
+		nil,
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:COALESCE\\(reason.*FROM audit_events)"), window[0], window[1], int32(failureReasonLimit)).Return(
+		pgxpoolmock.NewRows([]string{"event_type", "reason", "total"}).
+			AddRow("registration.failed", "timeout", int64(2)).
+			AddRow("authentication.failed", "user not found", int64(1)).
+			ToPgxRows(),
+		nil,
+	)
+	mocker.QueryRow(gomock.Any(), pgxpoolmock.QueryContains("(?ms:COUNT\\(DISTINCT user_ref\\))"), window[0], window[1]).Return(
+		pgxpoolmock.NewRow(int64(3)),
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:AS per_user)")).Return(
+		pgxpoolmock.NewRows([]string{"credential_count", "total"}).
+			AddRow(int64(0), int64(5)).
+			AddRow(int64(1), int64(10)).
+			ToPgxRows(),
+		nil,
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:COALESCE\\(attestation_type)")).Return(
+		pgxpoolmock.NewRows([]string{"attestation_type", "total"}).AddRow("none", int64(9)).AddRow("packed", int64(1)).ToPgxRows(),
+		nil,
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:json_array_elements_text)")).Return(
+		pgxpoolmock.NewRows([]string{"transport", "total"}).AddRow("internal", int64(8)).AddRow("hybrid", int64(4)).ToPgxRows(),
+		nil,
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SELECT aaguid, COUNT)"), int32(aaguidLimit)).Return(
+		pgxpoolmock.NewRows([]string{"aaguid", "total"}).AddRow(aaguid[:], int64(7)).AddRow([]byte(nil), int64(3)).ToPgxRows(),
+		nil,
+	)
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:backupEligible)")).Return(
+		pgxpoolmock.NewRows([]string{"backup_eligible", "backup_state", "total"}).
+			AddRow(false, false, int64(2)).
+			AddRow(true, false, int64(1)).
+			AddRow(true, true, int64(7)).
+			ToPgxRows(),
+		nil,
+	)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+	got, err := s.Report(from.Add(time.Hour), to)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Report() error = %v, want nil", err)
+	}
+	if !got.From.Equal(from) || !got.To.Equal(to) {
+		t.Errorf("Report() window = %v - %v, want %v - %v", got.From, got.To, from, to)
+	}
+	if len(got.Daily) != 3 {
+		t.Fatalf("Report() daily = %+v, want 3 days", got.Daily)
+	}
+	wantFirst := dto.DailyStats{Day: "2024-11-01", Registrations: 2, Logins: 3, LoginFailures: 1}
+	if got.Daily[0] != wantFirst {
+		t.Errorf("Report() daily[0] = %+v, want %+v", got.Daily[0], wantFirst)
+	}
+	if got.Daily[1] != (dto.DailyStats{Day: "2024-11-02"}) {
+		t.Errorf("Report() daily[1] = %+v, want an empty day", got.Daily[1])
+	}
+	if got.FailureRates.Registration != 2.0/6.0 || got.FailureRates.Login != 0.25 {
+		t.Errorf("Report() failure rates = %+v, want 1/3 and 1/4", got.FailureRates)
+	}
+	if got.ActiveUsers != 3 {
+		t.Errorf("Report() active users = %v, want 3", got.ActiveUsers)
+	}
+	if got.Failures[0] != (dto.FailureStat{Flow: "registration", Reason: "timeout", Count: 2}) {
+		t.Errorf("Report() failures[0] = %+v, want registration timeout", got.Failures[0])
+	}
+	if got.AAGUIDs[0].Key != aaguid.String() || got.AAGUIDs[1].Key != "unknown" {
+		t.Errorf("Report() aaguids = %+v, want %v and unknown", got.AAGUIDs, aaguid)
+	}
+	wantBackup := []dto.CountStat{{Key: BackupNotEligible, Count: 2}, {Key: BackupEligible, Count: 1}, {Key: BackupBackedUp, Count: 7}}
+	for i, stat := range wantBackup {
+		if got.BackupStates[i] != stat {
+			t.Errorf("Report() backup states = %+v, want %+v", got.BackupStates, wantBackup)
+			break
+		}
+	}
+}
+
+func TestStatsService_Report_EmptyWindow(t *testing.T) {
+	// Given
+	setupTest(t)
+
+	// When
+	s, err := New(context.Background())
+	if err != nil {
+		t.Errorf("New() error = %v, want nil", err)
+	}
+	_, err = s.Report(to, from)
+
+	// Then
+	if err == nil {
+		t.Errorf("Report() error = nil, want an error when from is not before to")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	// Given
+	report := &dto.StatsReport{
+		ActiveUsers:  3,
+		Daily:        []dto.DailyStats{{Day: "2024-11-01", Registrations: 2, Logins: 3}},
+		Transports:   []dto.CountStat{{Key: "internal", Count: 8}},
+		FailureRates: dto.FailureRates{Registration: 0.5},
+		Failures:     []dto.FailureStat{{Flow: "login", Reason: "bad, signature", Count: 1}},
+	}
+	var buffer bytes.Buffer
+
+	// When
+	err := WriteCSV(&buffer, report)
+
+	// Then
+	if err != nil {
+		t.Fatalf("WriteCSV() error = %v, want nil", err)
+	}
+	got := buffer.String()
+	for _, want := range []string{
+		"metric,key,value\n",
+		"active_users,,3\n",
+		"failure_rate,registration,0.5000\n",
+		"registrations,2024-11-01,2\n",
+		"transport,internal,8\n",
+		"login_failure_reason,\"bad, signature\",1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteCSV() = %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package stepup_service
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+)
+
+const nonceLength = 32
+
+// Transaction is an operation a user confirms with a step-up authentication
+type Transaction struct {
+	Payload json.RawMessage `json:"payload"`
+	Nonce   []byte          `json:"nonce"`
+}
+
+// NewTransaction compacts the payload, so the hash does not depend on how the caller formatted it, and draws the
+// nonce that keeps challenges for the same transaction unique
+func NewTransaction(payload json.RawMessage) (*Transaction, error) {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, payload); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	nonce := make([]byte, nonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return &Transaction{Payload: compacted.Bytes(), Nonce: nonce}, nil
+}
+
+// Hash is the SHA-256 of the compacted payload
+func (t *Transaction) Hash() []byte {
+	hash := sha256.Sum256(t.Payload)
+	return hash[:]
+}
+
+// Challenge is the SHA-256 of the compacted payload followed by the nonce, the authenticator's signature over it
+// binds the assertion to this transaction
+func (t *Transaction) Challenge() protocol.URLEncodedBase64 {
+	hash := sha256.New()
+	hash.Write(t.Payload)
+	hash.Write(t.Nonce)
+	return hash.Sum(nil)
+}
+
+// Confirm signs the confirmation of the transaction by the user's credential, for the audience that asked for it.
+// The confirmation is valid for ttl, the relying party is meant to run the transaction right away.
+func (t *Transaction) Confirm(key *ecdsa.PrivateKey, audience string, tenant string, requestID string, userRef string, credentialID []byte, userVerified bool, now time.Time, ttl time.Duration) (*dto.FinishStepUpResponse, error) {
+	claims := dto.TransactionConfirmation{
+		TokenClaims: dto.TokenClaims{
+			Audience:  audience,
+			Tenant:    tenant,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		RequestID:       requestID,
+		Subject:         userRef,
+		CredentialID:    credentialID,
+		UserVerified:    userVerified,
+		Transaction:     t.Payload,
+		TransactionHash: t.Hash(),
+		Nonce:           t.Nonce,
+	}
+	confirmation, err := token_service.Sign(key, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign confirmation: %w", err)
+	}
+	return &dto.FinishStepUpResponse{RequestID: requestID, Confirmation: confirmation, Claims: claims}, nil
+}
+
+// VerifyConfirmation checks a confirmation was signed by the auth server for the audience and tenant and has not
+// expired, the way relying parties are expected to before they run the transaction it names
+func VerifyConfirmation(key *ecdsa.PublicKey, confirmation string, audience string, tenant string, now time.Time) (*dto.TransactionConfirmation, error) {
+	var claims dto.TransactionConfirmation
+	if err := token_service.Parse(key, confirmation, &claims, audience, tenant, now); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -0,0 +1,116 @@
+package stepup_service
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	token_service "blacksmithlabs.dev/webauthn-k8s/shared/services/token"
+)
+
+func TestNewTransaction(t *testing.T) {
+	// Given the same transaction formatted two ways
+	first, err := NewTransaction(json.RawMessage(`{"amount": 100, "to": "alice"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := NewTransaction(json.RawMessage(`{"amount":100,"to":"alice"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Then both hash the same, but their challenges differ by the nonce
+	if !bytes.Equal(first.Hash(), second.Hash()) {
+		t.Errorf("Expected the same hash, got %x and %x", first.Hash(), second.Hash())
+	}
+	if bytes.Equal(first.Challenge(), second.Challenge()) {
+		t.Errorf("Expected different challenges, got %x", first.Challenge())
+	}
+
+	// And the challenge is the hash of the payload and the nonce
+	expected := sha256.Sum256(append([]byte(`{"amount":100,"to":"alice"}`), first.Nonce...))
+	if !bytes.Equal(first.Challenge(), expected[:]) {
+		t.Errorf("Expected challenge %x, got %x", expected, first.Challenge())
+	}
+
+	// And invalid JSON is rejected
+	if _, err := NewTransaction(json.RawMessage(`{"amount":`)); err == nil {
+		t.Errorf("Expected an error for invalid JSON")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	transaction, err := NewTransaction(json.RawMessage(`{"amount":100}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	// When the transaction is confirmed
+	response, err := transaction.Confirm(key, "https://bank.example.com", "bank", "request", "user", []byte{1, 2, 3}, true, now, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Then the signed confirmation names the transaction
+	claims, err := VerifyConfirmation(&key.PublicKey, response.Confirmation, "https://bank.example.com", "bank", now)
+	if err != nil {
+		t.Fatalf("Expected a valid confirmation, got %v", err)
+	}
+	if string(claims.Transaction) != `{"amount":100}` || !bytes.Equal(claims.TransactionHash, transaction.Hash()) {
+		t.Errorf("Expected the confirmed transaction, got %s", claims.Transaction)
+	}
+	if claims.Subject != "user" || claims.RequestID != "request" || !claims.UserVerified || claims.IssuedAt != 1700000000 || claims.ExpiresAt != 1700000060 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	// And nothing is signed without a key
+	if _, err := transaction.Confirm(nil, "", "", "request", "user", nil, true, now, time.Minute); err == nil {
+		t.Errorf("Expected an error without a key")
+	}
+}
+
+func TestVerifyConfirmationErrors(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	transaction, err := NewTransaction(json.RawMessage(`{"amount":100}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	response, err := transaction.Confirm(key, "https://bank.example.com", "bank", "request", "user", nil, true, now, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		audience string
+		tenant   string
+		now      time.Time
+		expected error
+	}{
+		{name: "Expired", audience: "https://bank.example.com", tenant: "bank", now: now.Add(time.Minute), expected: token_service.ErrTokenExpired},
+		{name: "Other audience", audience: "https://insurer.example.com", tenant: "bank", now: now, expected: token_service.ErrWrongAudience},
+		{name: "Other tenant", audience: "https://bank.example.com", tenant: "insurer", now: now, expected: token_service.ErrWrongTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyConfirmation(&key.PublicKey, response.Confirmation, tt.audience, tt.tenant, tt.now); !errors.Is(err, tt.expected) {
+				t.Errorf("VerifyConfirmation() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package token_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+// Tokens are compact ES256 JWTs so relying parties can verify them with any JOSE library and the public key the
+// auth server publishes, without holding anything that could sign a token
+var signingMethod = jwt.SigningMethodES256
+
+// ES256 signs with P-256, smaller keys are refused
+const minKeyBits = 256
+
+// A P-256 coordinate is 32 bytes
+const coordinateLength = 32
+
+var (
+	ErrMissingKey       = errors.New("token signing key is not configured")
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrWrongAudience    = errors.New("token was issued for another audience")
+	ErrWrongTenant      = errors.New("token was issued for another tenant")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Claims are the claims of a token the auth server signs, every one of them is issued for a tenant
+type Claims interface {
+	jwt.Claims
+	GetTenant() string
+}
+
+// LoadSigningKey reads the PEM encoded EC private key, SEC 1 or PKCS #8, that tokens are signed with.
+// It is meant to be mounted from a Kubernetes Secret.
+func LoadSigningKey(path string) (*ecdsa.PrivateKey, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token signing key: %w", err)
+	}
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, fmt.Errorf("token signing key is not PEM encoded")
+	}
+
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid token signing key: %w", err)
+		}
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token signing key: %w", err)
+		}
+		var ok bool
+		if key, ok = parsed.(*ecdsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("token signing key must be an EC key, got %T", parsed)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token signing key PEM block %q", block.Type)
+	}
+
+	if bits := key.Curve.Params().BitSize; bits < minKeyBits {
+		return nil, fmt.Errorf("token signing key has %v bits, at least %v are required", bits, minKeyBits)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("token signing key must be on the P-256 curve for %v, got %v", signingMethod.Alg(), key.Curve.Params().Name)
+	}
+	return key, nil
+}
+
+// KeyID is the RFC 7638 thumbprint of the public key, relying parties pick the key to verify a token with by it
+func KeyID(key *ecdsa.PublicKey) string {
+	jwk := PublicJWK(key)
+	// The members in lexicographic order, as the thumbprint requires
+	thumbprint := sha256.Sum256([]byte(`{"crv":"` + jwk.Curve + `","kty":"` + jwk.KeyType + `","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`))
+	return encoding.EncodeToString(thumbprint[:])
+}
+
+// PublicJWK is the public key as published in the JWK set
+func PublicJWK(key *ecdsa.PublicKey) dto.JSONWebKey {
+	return dto.JSONWebKey{
+		KeyType:   "EC",
+		Curve:     key.Curve.Params().Name,
+		X:         encoding.EncodeToString(key.X.FillBytes(make([]byte, coordinateLength))),
+		Y:         encoding.EncodeToString(key.Y.FillBytes(make([]byte, coordinateLength))),
+		Use:       "sig",
+		Algorithm: signingMethod.Alg(),
+	}
+}
+
+// JWKSet is the JWK set relying parties fetch to verify tokens, empty when no signing key is configured
+func JWKSet(key *ecdsa.PublicKey) dto.JSONWebKeySet {
+	set := dto.JSONWebKeySet{Keys: []dto.JSONWebKey{}}
+	if key != nil {
+		jwk := PublicJWK(key)
+		jwk.KeyID = KeyID(key)
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Sign encodes the claims as a token signed with the key
+func Sign(key *ecdsa.PrivateKey, claims Claims) (string, error) {
+	if key == nil {
+		return "", ErrMissingKey
+	}
+	token := jwt.NewWithClaims(signingMethod, claims)
+	token.Header["kid"] = KeyID(&key.PublicKey)
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse checks the token was signed with the key, has not expired and was issued to the audience for the tenant, and
+// decodes its claims. Only ES256 is accepted, whatever algorithm the token claims.
+func Parse(key *ecdsa.PublicKey, token string, claims Claims, audience string, tenant string, now time.Time) error {
+	if key == nil {
+		return ErrMissingKey
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if _, err := parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return key, nil }); err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return ErrTokenExpired
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return ErrWrongAudience
+		case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+			return ErrInvalidSignature
+		default:
+			return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+		}
+	}
+	if claims.GetTenant() != tenant {
+		return ErrWrongTenant
+	}
+	return nil
+}
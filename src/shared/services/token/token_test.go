@@ -0,0 +1,152 @@
+package token_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+func generateKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return key
+}
+
+func writeKey(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return path
+}
+
+func TestSignAndParse(t *testing.T) {
+	key := generateKey(t, elliptic.P256())
+	other := generateKey(t, elliptic.P256())
+	now := time.Unix(1700000000, 0)
+	claims := dto.TokenClaims{Audience: "https://bank.example.com", Tenant: "bank", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+
+	sign := func(key *ecdsa.PrivateKey, claims dto.TokenClaims) string {
+		token, err := Sign(key, claims)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return token
+	}
+	token := sign(key, claims)
+	withoutExpiry := claims
+	withoutExpiry.ExpiresAt = 0
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("bsm-webauthn-secret"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      *ecdsa.PublicKey
+		token    string
+		audience string
+		tenant   string
+		now      time.Time
+		expected error
+	}{
+		{name: "Valid", key: &key.PublicKey, token: token, audience: "https://bank.example.com", tenant: "bank", now: now},
+		{name: "Expired", key: &key.PublicKey, token: token, audience: "https://bank.example.com", tenant: "bank", now: now.Add(time.Hour), expected: ErrTokenExpired},
+		{name: "Other audience", key: &key.PublicKey, token: token, audience: "https://insurer.example.com", tenant: "bank", now: now, expected: ErrWrongAudience},
+		{name: "Other tenant", key: &key.PublicKey, token: token, audience: "https://bank.example.com", tenant: "insurer", now: now, expected: ErrWrongTenant},
+		{name: "Other key", key: &other.PublicKey, token: token, audience: "https://bank.example.com", tenant: "bank", now: now, expected: ErrInvalidSignature},
+		{name: "Other algorithm", key: &key.PublicKey, token: hmac, audience: "https://bank.example.com", tenant: "bank", now: now, expected: ErrInvalidSignature},
+		{name: "Without expiry", key: &key.PublicKey, token: sign(key, withoutExpiry), audience: "https://bank.example.com", tenant: "bank", now: now, expected: ErrMalformedToken},
+		{name: "Not a token", key: &key.PublicKey, token: "token", audience: "https://bank.example.com", tenant: "bank", now: now, expected: ErrMalformedToken},
+		{name: "Missing key", token: token, audience: "https://bank.example.com", tenant: "bank", now: now, expected: ErrMissingKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parsed dto.TokenClaims
+			if err := Parse(tt.key, tt.token, &parsed, tt.audience, tt.tenant, tt.now); !errors.Is(err, tt.expected) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.expected)
+			}
+			if tt.expected == nil && parsed != claims {
+				t.Errorf("Parse() claims = %+v, want %+v", parsed, claims)
+			}
+		})
+	}
+
+	// And signing needs a key as well
+	if _, err := Sign(nil, claims); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("Expected error %v, got %v", ErrMissingKey, err)
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	key := generateKey(t, elliptic.P256())
+	sec1, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	short, err := x509.MarshalECPrivateKey(generateKey(t, elliptic.P224()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	long, err := x509.MarshalECPrivateKey(generateKey(t, elliptic.P384()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "SEC 1 key", path: writeKey(t, "EC PRIVATE KEY", sec1)},
+		{name: "PKCS #8 key", path: writeKey(t, "PRIVATE KEY", pkcs8)},
+		{name: "Key shorter than 256 bits", path: writeKey(t, "EC PRIVATE KEY", short), wantErr: true},
+		{name: "Key of another curve", path: writeKey(t, "EC PRIVATE KEY", long), wantErr: true},
+		{name: "Shared secret", path: writeKey(t, "SECRET", []byte("bsm-webauthn-secret")), wantErr: true},
+		{name: "Missing file", path: filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadSigningKey(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !loaded.Equal(key) {
+				t.Errorf("LoadSigningKey() returned another key")
+			}
+		})
+	}
+}
+
+func TestJWKSet(t *testing.T) {
+	key := generateKey(t, elliptic.P256())
+
+	// When the public key is published
+	set := JWKSet(&key.PublicKey)
+
+	// Then it carries the key ID the tokens name
+	if len(set.Keys) != 1 || set.Keys[0].KeyID != KeyID(&key.PublicKey) || set.Keys[0].Curve != "P-256" || set.Keys[0].Algorithm != "ES256" {
+		t.Errorf("Unexpected JWK set %+v", set)
+	}
+	// And nothing is published without a key
+	if set := JWKSet(nil); len(set.Keys) != 0 {
+		t.Errorf("Expected an empty JWK set, got %+v", set)
+	}
+}
@@ -0,0 +1,160 @@
+package webhook_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	"blacksmithlabs.dev/webauthn-k8s/shared/utils"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventTypeHeader = "X-Webhook-Event-Type"
+)
+
+const deliveryBatchSize = 20
+const deliveryTimeout = 10 * time.Second
+const baseBackoff = 30 * time.Second
+const maxBackoff = time.Hour
+
+var logger = utils.GetLogger()
+
+// Sign computes the signature header value for a payload: t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<payload>">
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Backoff returns how long to wait before retrying a delivery that has failed the given number of times
+func Backoff(attempts int32) time.Duration {
+	delay := baseBackoff
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Worker polls the outbox and delivers pending events to their subscribers
+type Worker struct {
+	queries     *credentials.Queries
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int32
+}
+
+// NewWorker creates a delivery worker, the queries should be bound to the connection pool rather than a transaction
+func NewWorker(queries *credentials.Queries, interval time.Duration, maxAttempts int) *Worker {
+	return &Worker{
+		queries:     queries,
+		client:      newGuardedClient(),
+		interval:    interval,
+		maxAttempts: int32(maxAttempts),
+	}
+}
+
+// Run delivers batches of events until the context is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.DeliverBatch(ctx); err != nil {
+				logger.Error("Failed to deliver webhook batch", "error", err)
+			}
+		}
+	}
+}
+
+// DeliverBatch claims the next batch of due events and attempts to deliver each of them once
+func (w *Worker) DeliverBatch(ctx context.Context) error {
+	// Lease the batch so other pods skip it while we are delivering
+	leaseUntil := time.Now().Add(deliveryTimeout * deliveryBatchSize)
+	deliveries, err := w.queries.ClaimWebhookDeliveries(ctx, credentials.ClaimWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: leaseUntil, Valid: true},
+		BatchSize:  deliveryBatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		if err := w.deliver(ctx, delivery); err != nil {
+			w.markFailed(ctx, delivery, err)
+			continue
+		}
+		if err := w.queries.MarkWebhookDelivered(ctx, delivery.ID); err != nil {
+			logger.Error("Failed to mark webhook delivered", "error", err, "deliveryId", delivery.ID)
+		}
+	}
+
+	return nil
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery credentials.ClaimWebhookDeliveriesRow) error {
+	// Subscriptions created before URLs were checked may still point anywhere
+	if parsed, err := url.Parse(delivery.Url); err != nil || parsed.Scheme != "https" {
+		return fmt.Errorf("%w: must be an https url", ErrBlockedURL)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventTypeHeader, delivery.EventType)
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func (w *Worker) markFailed(ctx context.Context, delivery credentials.ClaimWebhookDeliveriesRow, deliveryErr error) {
+	attempts := delivery.Attempts + 1
+	status := DeliveryStatusPending
+	if attempts >= w.maxAttempts {
+		status = DeliveryStatusDead
+	}
+
+	logger.Warn("Webhook delivery failed", "error", deliveryErr, "deliveryId", delivery.ID, "attempts", attempts, "status", status)
+
+	if err := w.queries.MarkWebhookFailed(ctx, credentials.MarkWebhookFailedParams{
+		ID:            delivery.ID,
+		Status:        status,
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(Backoff(attempts)), Valid: true},
+		LastError:     pgtype.Text{String: deliveryErr.Error(), Valid: true},
+	}); err != nil {
+		logger.Error("Failed to mark webhook failed", "error", err, "deliveryId", delivery.ID)
+	}
+}
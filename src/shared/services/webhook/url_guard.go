@@ -0,0 +1,108 @@
+package webhook_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrBlockedURL is returned for subscriber URLs that are not https or that point into a private network
+var ErrBlockedURL = errors.New("webhook url is not allowed")
+
+// Ranges that are not covered by the net/netip helpers but must not be reachable from the cluster either
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+var lookupIP = net.DefaultResolver.LookupNetIP
+
+// blockedAddr reports whether the address is loopback, private, link-local or otherwise internal
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL makes sure a subscriber URL is https and that its host only resolves to public addresses.
+// The addresses are checked again on every delivery, since the name may resolve differently by then.
+func CheckURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBlockedURL, err)
+	}
+	if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return fmt.Errorf("%w: must be an https url", ErrBlockedURL)
+	}
+
+	if addr, err := netip.ParseAddr(parsed.Hostname()); err == nil {
+		if blockedAddr(addr) {
+			return fmt.Errorf("%w: %v is not a public address", ErrBlockedURL, addr)
+		}
+		return nil
+	}
+	addrs, err := lookupIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve %v: %v", ErrBlockedURL, parsed.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if blockedAddr(addr) {
+			return fmt.Errorf("%w: %v resolves to %v, which is not a public address", ErrBlockedURL, parsed.Hostname(), addr)
+		}
+	}
+	return nil
+}
+
+// guardedDialControl refuses connections to internal addresses, after the name was resolved for this very connection
+func guardedDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if blockedAddr(addr) {
+		return fmt.Errorf("%w: %v is not a public address", ErrBlockedURL, addr)
+	}
+	return nil
+}
+
+// newGuardedClient creates the client deliveries are sent with, it only connects to public addresses over https
+func newGuardedClient() *http.Client {
+	dialer := &net.Dialer{Timeout: deliveryTimeout, Control: guardedDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if request.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirected to %v", ErrBlockedURL, request.URL.Scheme)
+			}
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return nil
+		},
+	}
+}
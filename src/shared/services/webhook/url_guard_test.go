@@ -0,0 +1,64 @@
+package webhook_service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	oldLookupIP := lookupIP
+	lookupIP = func(ctx context.Context, network string, host string) ([]netip.Addr, error) {
+		switch host {
+		case "hooks.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		case "internal.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.12")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() { lookupIP = oldLookupIP })
+
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://hooks.example.com/webauthn", false},
+		{"https://93.184.216.34/webauthn", false},
+		{"http://hooks.example.com/webauthn", true},
+		{"https://internal.example.com/webauthn", true},
+		{"https://unknown.example.com/webauthn", true},
+		{"https://127.0.0.1/webauthn", true},
+		{"https://169.254.169.254/latest/meta-data", true},
+		{"https://[::1]/webauthn", true},
+		{"https://[::ffff:10.0.0.1]/webauthn", true},
+		{"https://100.64.0.1/webauthn", true},
+		{"https:///webauthn", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrBlockedURL) {
+				t.Errorf("CheckURL() error = %v, want ErrBlockedURL", err)
+			}
+		})
+	}
+}
+
+func TestGuardedClient_RefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("guarded client reached %v", r.URL)
+	}))
+	defer server.Close()
+
+	_, err := newGuardedClient().Get(server.URL)
+	if !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Get() error = %v, want ErrBlockedURL", err)
+	}
+}
@@ -0,0 +1,240 @@
+package webhook_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+)
+
+type EventType string
+
+const (
+	EventCredentialRegistered    EventType = "credential.registered"
+	EventCredentialStatusChanged EventType = "credential.status_changed"
+	EventNewAuthenticatorLogin   EventType = "authentication.new_authenticator"
+	// EventCredentialsRevokedNotice asks subscribers to tell the user their credentials were revoked by an administrator
+	EventCredentialsRevokedNotice EventType = "user.credentials_revoked"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+const deadLetterLimit = 100
+
+// Event is the payload delivered to webhook subscribers
+type Event struct {
+	ID        string         `json:"id"`
+	Type      EventType      `json:"type"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Data      map[string]any `json:"data"`
+}
+
+// NewEvent creates a new event with a unique ID
+func NewEvent(eventType EventType, data map[string]any) Event {
+	return Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// EnqueueEvent writes the event to the outbox for every matching subscription.
+// Pass transaction bound queries so the event is only delivered if the change it describes is committed.
+func EnqueueEvent(ctx context.Context, queries *credentials.Queries, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	if err := queries.EnqueueWebhookEvent(ctx, credentials.EnqueueWebhookEventParams{
+		EventType: string(event.Type),
+		Payload:   payload,
+	}); err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+
+	return nil
+}
+
+// WebhookService manages webhook subscriptions and their deliveries
+type WebhookService struct {
+	ctx     context.Context
+	queries *credentials.Queries
+}
+
+var getDbConn func(context.Context) (database.DBConn, error) = func(ctx context.Context) (database.DBConn, error) {
+	return database.ConnectDb(ctx)
+}
+
+// New creates a new WebhookService instance
+func New(ctx context.Context) (*WebhookService, error) {
+	pool, err := getDbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookService{
+		ctx:     ctx,
+		queries: credentials.New(pool),
+	}, nil
+}
+
+func subscriptionFromDatabase(row credentials.WebhookSubscription) (*dto.WebhookSubscriptionResponse, error) {
+	eventTypes := []string{}
+	if err := json.Unmarshal(row.EventTypes, &eventTypes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal EventTypes: %w", err)
+	}
+
+	return &dto.WebhookSubscriptionResponse{
+		ID:         row.ID,
+		URL:        row.Url,
+		EventTypes: eventTypes,
+		Active:     row.Active,
+		CreatedAt:  row.CreatedAt.Time,
+	}, nil
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateSubscription subscribes the URL to the requested event types, generating a signing secret if none was provided
+func (s *WebhookService) CreateSubscription(request dto.CreateWebhookSubscriptionRequest) (*dto.WebhookSubscriptionResponse, error) {
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	}
+
+	eventTypes := request.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	eventTypesJson, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal EventTypes: %w", err)
+	}
+
+	row, err := s.queries.InsertWebhookSubscription(s.ctx, credentials.InsertWebhookSubscriptionParams{
+		Url:        request.URL,
+		Secret:     secret,
+		EventTypes: eventTypesJson,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	subscription, err := subscriptionFromDatabase(row)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+
+	return subscription, nil
+}
+
+// RotateSecret replaces the signing secret of a subscription with a newly generated one and returns it, deliveries are
+// signed with the new secret from then on so the receiver has to be updated at the same time
+func (s *WebhookService) RotateSecret(id int64) (*dto.WebhookSubscriptionResponse, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	row, err := s.queries.UpdateWebhookSubscriptionSecret(s.ctx, credentials.UpdateWebhookSubscriptionSecretParams{
+		ID:     id,
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := subscriptionFromDatabase(row)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+
+	return subscription, nil
+}
+
+// ListSubscriptions returns all webhook subscriptions without their secrets
+func (s *WebhookService) ListSubscriptions() ([]dto.WebhookSubscriptionResponse, error) {
+	rows, err := s.queries.ListWebhookSubscriptions(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	subscriptions := make([]dto.WebhookSubscriptionResponse, 0, len(rows))
+	for _, row := range rows {
+		subscription, err := subscriptionFromDatabase(row)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a subscription and any deliveries still in its outbox
+func (s *WebhookService) DeleteSubscription(id int64) (bool, error) {
+	count, err := s.queries.DeleteWebhookSubscription(s.ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// ListDeadLetters returns the deliveries that ran out of retry attempts
+func (s *WebhookService) ListDeadLetters() ([]dto.WebhookDeliveryResponse, error) {
+	rows, err := s.queries.ListDeadWebhookDeliveries(s.ctx, deadLetterLimit)
+	if err != nil {
+		return nil, fmt.Errorf("data access error: %w", err)
+	}
+
+	deliveries := make([]dto.WebhookDeliveryResponse, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, dto.WebhookDeliveryResponse{
+			ID:             row.ID,
+			SubscriptionID: row.SubscriptionID,
+			EventType:      row.EventType,
+			Status:         row.Status,
+			Attempts:       row.Attempts,
+			LastError:      row.LastError.String,
+			CreatedAt:      row.CreatedAt.Time,
+		})
+	}
+
+	return deliveries, nil
+}
+
+// Replay puts a dead delivery back in the outbox with a fresh set of attempts
+func (s *WebhookService) Replay(id int64) (bool, error) {
+	count, err := s.queries.ReplayWebhookDelivery(s.ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("data access error: %w", err)
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,201 @@
+package webhook_service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/database"
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+	"blacksmithlabs.dev/webauthn-k8s/shared/models/credentials"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/milqa/pgxpoolmock"
+)
+
+var mockPool *pgxpoolmock.MockPgxIface
+
+func setupTest(t *testing.T) {
+	oldGetDbConn := getDbConn
+
+	ctrl := gomock.NewController(t)
+
+	mockPool = pgxpoolmock.NewMockPgxIface(ctrl)
+	getDbConn = func(ctx context.Context) (database.DBConn, error) {
+		return mockPool, nil
+	}
+
+	t.Cleanup(func() {
+		getDbConn = oldGetDbConn
+		ctrl.Finish()
+	})
+}
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	expected := "t=1700000000,v1=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+
+	if got := Sign("secret", timestamp, []byte(`{"id":"1"}`)); got != expected {
+		t.Errorf("Sign() = %v, want %v", got, expected)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 30 * time.Second},
+		{attempts: 2, expected: time.Minute},
+		{attempts: 3, expected: 2 * time.Minute},
+		{attempts: 20, expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.expected {
+			t.Errorf("Backoff(%v) = %v, want %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestEnqueueEvent(t *testing.T) {
+	setupTest(t)
+
+	mockPool.EXPECT().Exec(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_outbox.*FROM webhook_subscriptions)"),
+		"credential.registered",
+		gomock.Any(),
+	).Return(pgconn.NewCommandTag("INSERT 0 2"), nil)
+
+	event := NewEvent(EventCredentialRegistered, map[string]any{"userId": "user"})
+	if err := EnqueueEvent(context.Background(), credentials.New(mockPool), event); err != nil {
+		t.Errorf("EnqueueEvent() error = %v, want nil", err)
+	}
+}
+
+func TestWebhookService_CreateSubscription(t *testing.T) {
+	setupTest(t)
+
+	mockPool.EXPECT().QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:INSERT INTO webhook_subscriptions.*)"),
+		"https://example.com/hook",
+		gomock.Any(),
+		[]byte(`[]`),
+	).Return(pgxpoolmock.NewRow(
+		int64(3), "https://example.com/hook", "generated", []byte(`[]`), true, pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	))
+
+	service, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	subscription, err := service.CreateSubscription(dto.CreateWebhookSubscriptionRequest{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v, want nil", err)
+	}
+	if subscription.ID != 3 || len(subscription.Secret) != 64 {
+		t.Errorf("CreateSubscription() = %+v, want ID 3 with a generated secret", subscription)
+	}
+}
+
+func TestWebhookService_RotateSecret(t *testing.T) {
+	setupTest(t)
+
+	mockPool.EXPECT().QueryRow(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:UPDATE webhook_subscriptions.*SET secret.*)"),
+		int64(3),
+		gomock.Any(),
+	).Return(pgxpoolmock.NewRow(
+		int64(3), "https://example.com/hook", "rotated", []byte(`[]`), true, pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	))
+
+	service, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	subscription, err := service.RotateSecret(3)
+	if err != nil {
+		t.Fatalf("RotateSecret() error = %v, want nil", err)
+	}
+	if subscription.ID != 3 || len(subscription.Secret) != 64 {
+		t.Errorf("RotateSecret() = %+v, want ID 3 with a new generated secret", subscription)
+	}
+}
+
+func TestWebhookService_Replay(t *testing.T) {
+	setupTest(t)
+
+	mockPool.EXPECT().Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE webhook_outbox.*status = 'dead')"), int64(7)).Return(pgconn.NewCommandTag("UPDATE 0"), nil)
+
+	service, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	found, err := service.Replay(7)
+	if err != nil {
+		t.Errorf("Replay() error = %v, want nil", err)
+	}
+	if found {
+		t.Errorf("Replay() = true, want false when no dead delivery matched")
+	}
+}
+
+func TestWorker_DeliverBatch(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	setupTest(t)
+
+	mocker := mockPool.EXPECT()
+	mocker.Query(gomock.Any(), pgxpoolmock.QueryContains("(?ms:UPDATE webhook_outbox o.*FOR UPDATE SKIP LOCKED)"), gomock.Any(), int32(deliveryBatchSize)).Return(
+		pgxpoolmock.NewRows([]string{"_id", "event_type", "payload", "attempts", "url", "secret"}).AddRow(
+			int64(1), "credential.registered", []byte(`{"id":"1"}`), int32(0), server.URL+"/ok", "secret",
+		).AddRow(
+			int64(2), "credential.registered", []byte(`{"id":"2"}`), int32(1), server.URL+"/fail", "secret",
+		).ToPgxRows(),
+		nil,
+	)
+	mocker.Exec(gomock.Any(), pgxpoolmock.QueryContains("(?ms:SET status = 'delivered')"), int64(1)).Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+	mocker.Exec(
+		gomock.Any(),
+		pgxpoolmock.QueryContains("(?ms:SET status = \\$2)"),
+		int64(2),
+		DeliveryStatusDead,
+		gomock.Any(),
+		gomock.Any(),
+	).Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+
+	worker := NewWorker(credentials.New(mockPool), time.Second, 2)
+	// The test server listens on loopback, which the guarded client refuses
+	worker.client = server.Client()
+	if err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("DeliverBatch() error = %v, want nil", err)
+	}
+
+	if received == nil {
+		t.Fatalf("DeliverBatch() did not call the subscriber")
+	}
+	if received.Header.Get(SignatureHeader) == "" {
+		t.Errorf("DeliverBatch() did not sign the payload")
+	}
+	if string(body) != `{"id":"2"}` {
+		t.Errorf("DeliverBatch() body = %s, want %s", body, `{"id":"2"}`)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+// AppError pairs the detailed cause of a failure, which is only logged, with a message that is safe to show clients
+type AppError struct {
+	Status  int
+	Code    dto.ErrorCode
+	Message string
+	Err     error
+}
+
+func NewError(status int, code dto.ErrorCode, message string, err error) *AppError {
+	return &AppError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// NewWebauthnError uses the code for the go-webauthn failure when there is one, falling back to the given code
+func NewWebauthnError(status int, fallback dto.ErrorCode, message string, err error) *AppError {
+	code, ok := dto.ErrorCodeFromWebauthn(err)
+	if !ok || code == "" {
+		code = fallback
+	}
+	return NewError(status, code, message, err)
+}
+
+func (e *AppError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Problem converts the error into the RFC 7807 response body
+func (e *AppError) Problem() dto.Problem {
+	return dto.NewProblem(e.Status, e.Code, e.Message)
+}
+
+// AsAppError finds the AppError in the chain, any other error is treated as an internal error
+func AsAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return NewError(http.StatusInternalServerError, dto.ErrorInternal, "Internal server error", err)
+}
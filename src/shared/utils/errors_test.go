@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"blacksmithlabs.dev/webauthn-k8s/shared/dto"
+)
+
+func TestAppError_Error(t *testing.T) {
+	withCause := NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", fmt.Errorf("no rows"))
+	if withCause.Error() != "User not found: no rows" {
+		t.Errorf("Error() = %v, want %v", withCause.Error(), "User not found: no rows")
+	}
+
+	withoutCause := NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", nil)
+	if withoutCause.Error() != "User not found" {
+		t.Errorf("Error() = %v, want %v", withoutCause.Error(), "User not found")
+	}
+}
+
+func TestAsAppError(t *testing.T) {
+	appErr := NewError(http.StatusBadRequest, dto.ErrorInvalidRequest, "Invalid request", nil)
+
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode dto.ErrorCode
+	}{
+		{
+			name:         "AppError",
+			err:          appErr,
+			expectedCode: dto.ErrorInvalidRequest,
+		},
+		{
+			name:         "Wrapped AppError",
+			err:          fmt.Errorf("wrapped: %w", appErr),
+			expectedCode: dto.ErrorInvalidRequest,
+		},
+		{
+			name:         "Plain error",
+			err:          fmt.Errorf("database exploded"),
+			expectedCode: dto.ErrorInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := AsAppError(tt.err); v.Code != tt.expectedCode {
+				t.Errorf("AsAppError() code = %v, want %v", v.Code, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestNewWebauthnError(t *testing.T) {
+	expired := NewWebauthnError(http.StatusUnauthorized, dto.ErrorAuthenticationFailed, "Failed to validate login", protocol.ErrBadRequest.WithDetails("Session has Expired"))
+	if expired.Code != dto.ErrorChallengeExpired {
+		t.Errorf("NewWebauthnError() code = %v, want %v", expired.Code, dto.ErrorChallengeExpired)
+	}
+
+	other := NewWebauthnError(http.StatusUnauthorized, dto.ErrorAuthenticationFailed, "Failed to validate login", fmt.Errorf("unknown"))
+	if other.Code != dto.ErrorAuthenticationFailed {
+		t.Errorf("NewWebauthnError() code = %v, want %v", other.Code, dto.ErrorAuthenticationFailed)
+	}
+}
+
+func TestAppError_Problem(t *testing.T) {
+	problem := NewError(http.StatusNotFound, dto.ErrorUserNotFound, "User not found", fmt.Errorf("no rows")).Problem()
+	if problem.Detail != "User not found" || problem.Code != dto.ErrorUserNotFound || problem.Status != http.StatusNotFound {
+		t.Errorf("Problem() = %+v, detail must not include the cause", problem)
+	}
+}
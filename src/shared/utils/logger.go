@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Attribute keys that may carry key material, secrets or raw ceremony data and must never reach the logs
+var redactedKeys = map[string]bool{
+	"publickey":         true,
+	"public_key":        true,
+	"secret":            true,
+	"password":          true,
+	"token":             true,
+	"authorization":     true,
+	"cookie":            true,
+	"assertion":         true,
+	"attestation":       true,
+	"attestationobject": true,
+	"authenticatordata": true,
+	"clientdatajson":    true,
+	"signature":         true,
+	"challenge":         true,
+	"sessiondata":       true,
+}
+
+var logger *slog.Logger
+
+// RedactAttr replaces the value of sensitive attributes, including ones nested in groups
+func RedactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redactedValue)
+	}
+	return attr
+}
+
+func GetLogger() *slog.Logger {
+	if logger == nil {
+		logger = sync.OnceValue(func() *slog.Logger {
+			return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+				ReplaceAttr: RedactAttr,
+			}))
+		})()
+	}
+
+	return logger
+}
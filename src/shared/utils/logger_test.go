@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestGetLogger(t *testing.T) {
+	// Test that GetLogger returns a non-nil logger
+	logger1 := GetLogger()
+	if logger1 == nil {
+		t.Errorf("GetLogger() returned nil, expected non-nil logger")
+	}
+
+	// Test that GetLogger returns the same logger instance on multiple calls
+	logger2 := GetLogger()
+	if logger1 != logger2 {
+		t.Errorf("GetLogger() returned different instances, expected the same instance")
+	}
+}
+
+func TestRedactAttr(t *testing.T) {
+	tests := []struct {
+		name     string
+		attr     slog.Attr
+		expected string
+	}{
+		{
+			name:     "Plain attribute",
+			attr:     slog.String("userId", "123"),
+			expected: "123",
+		},
+		{
+			name:     "Public key",
+			attr:     slog.Any("publicKey", []byte("key")),
+			expected: redactedValue,
+		},
+		{
+			name:     "Case insensitive",
+			attr:     slog.String("Assertion", "raw"),
+			expected: redactedValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := RedactAttr(nil, tt.attr); v.Value.String() != tt.expected {
+				t.Errorf("RedactAttr() = %v, want %v", v.Value.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetLogger_RedactsNestedGroups(t *testing.T) {
+	var buffer bytes.Buffer
+	testLogger := slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{ReplaceAttr: RedactAttr}))
+
+	testLogger.Info("test", slog.Group("credential", slog.String("id", "abc"), slog.String("publicKey", "secret-key")))
+
+	if strings.Contains(buffer.String(), "secret-key") {
+		t.Errorf("Logger output contains redacted value: %s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), `"id":"abc"`) {
+		t.Errorf("Logger output is missing plain value: %s", buffer.String())
+	}
+}